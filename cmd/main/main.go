@@ -15,6 +15,19 @@ func main() {
 		actions.Set(root),
 		actions.Create(root),
 		actions.Delete(root),
+		actions.Export(root),
+		actions.Import(root),
+		actions.Copy(root),
+		actions.Move(root),
+		actions.Transfer(root),
+		actions.Diff(root),
+		actions.Backup(root),
+		actions.Restore(root),
+		actions.Tx(root),
+		actions.Watch(root),
+		actions.Shell(root),
+		actions.WhoAmI(root),
+		actions.Profile(root),
 	)
 
 	if err := root.Execute(); err != nil {
@@ -3,6 +3,11 @@ package actions
 import (
 	"fmt"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/backup"
+	"os"
+	"strings"
 	"time"
 )
 
@@ -52,32 +57,16 @@ func (a *action) printOutput(value any) {
 			}
 			values = append(values, v)
 		}
-		json, err := a.toJSON(values)
-		if err != nil {
-			fmt.Printf("Error: %s\n", err)
-			return
-		}
-		if len(json) == 0 || json == "[]" {
-			return
-		}
-		fmt.Println(json)
+		a.printValues(values)
 	case []map[string]any:
-		values := make([]map[string]any, 0)
+		values := make([]any, 0)
 		for _, v := range value.([]map[string]any) {
 			if len(v) == 0 {
 				continue
 			}
 			values = append(values, v)
 		}
-		json, err := a.toJSON(values)
-		if err != nil {
-			fmt.Printf("Error: %s\n", err)
-			return
-		}
-		if len(json) == 0 || json == "[]" {
-			return
-		}
-		fmt.Println(json)
+		a.printValues(values)
 	case map[string]any:
 		json, err := a.toJSON(value)
 		if err != nil {
@@ -93,22 +82,153 @@ func (a *action) printOutput(value any) {
 	}
 }
 
+func (a *action) printValues(values []any) {
+	if a.initializer.Config().Format == formatNDJSON {
+		writer := a.newDocumentWriter(false)
+		for _, v := range values {
+			if err := writer.write(v); err != nil {
+				fmt.Printf("Error: %s\n", err)
+				return
+			}
+		}
+		return
+	}
+
+	json, err := a.toJSON(values)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		return
+	}
+	if len(json) == 0 || json == "[]" {
+		return
+	}
+	fmt.Println(json)
+}
+
+// dryRun reports whether the command only shows what it would write.
+func (a *action) dryRun() bool {
+	flag := a.command.Flag(flagDryRun)
+	return flag != nil && flag.Value.String() == "true"
+}
+
+// printWritten prints the outcome of a write, unless it's a dry run, where the planned write is printed instead.
+func (a *action) printWritten(format string, args ...any) {
+	if !a.dryRun() {
+		fmt.Printf(format, args...)
+	}
+}
+
+// confirm asks a yes/no question on stdout and reports whether it was answered yes. A dry run writes nothing, so it
+// goes ahead without asking. Piped input holds a command's data, or the shell's next commands, so rather than reading
+// an answer from it, confirm fails and --yes is needed instead.
+func (a *action) confirm(question string) (bool, error) {
+	if a.dryRun() {
+		return true, nil
+	}
+
+	in := a.command.InOrStdin()
+	if file, ok := in.(*os.File); ok && !term.IsTerminal(int(file.Fd())) {
+		return false, fmt.Errorf("can't ask %q, because stdin isn't a terminal; use --%s to go ahead", question, flagYes)
+	}
+
+	fmt.Printf("%s (y/N): ", question)
+	var response string
+	_, _ = fmt.Fscanln(in, &response)
+	return strings.HasPrefix(strings.TrimSpace(strings.ToUpper(response)), "Y"), nil
+}
+
+func (a *action) backupCollection() string {
+	if bc := a.initializer.Config().Backup.Collection; len(bc) > 0 {
+		return bc
+	}
+	return defaultBackupCollection
+}
+
+// backupSink opens the sink backups are kept in, chosen with backup.sink in the config. The caller closes it.
+func (a *action) backupSink() (backup.Sink, error) {
+	cfg := a.initializer.Config()
+	store := a.initializer.Firestore()
+
+	directory := cfg.Backup.Directory
+	if len(directory) == 0 {
+		directory = defaultBackupDirectory
+	}
+	directory = expandPath(directory)
+
+	switch cfg.Backup.Sink {
+	case "", backup.SinkFirestore:
+		return backup.NewFirestoreSink(store, a.backupCollection()), nil
+	case backup.SinkProject:
+		target := cfg.Derived()
+		if len(cfg.Backup.Profile) > 0 {
+			var err error
+			if target, err = cfg.WithProfile(cfg.Backup.Profile); err != nil {
+				return nil, fmt.Errorf("%s; the backup profile must be defined in the config file", err)
+			}
+		}
+		if len(cfg.Backup.ProjectID) > 0 {
+			target.ProjectID = cfg.Backup.ProjectID
+		}
+		if len(cfg.Backup.DatabaseID) > 0 {
+			target.DatabaseID = cfg.Backup.DatabaseID
+		}
+		if client.Backend(target) == client.Backend(cfg) {
+			return nil, fmt.Errorf("the %s backup sink needs another project or database; set backup.profile, backup.project-id or backup.database-id", backup.SinkProject)
+		}
+		backups, err := a.initializer.Open(target)
+		if err != nil {
+			return nil, err
+		}
+		return backup.NewProjectSink(backups, a.backupCollection(), store.Decode), nil
+	case backup.SinkDirectory:
+		return backup.NewDirectorySink(directory, store.Decode), nil
+	case backup.SinkArchive:
+		maxSize := cfg.Backup.MaxSizeMB
+		if maxSize <= 0 {
+			maxSize = defaultBackupMaxSizeMB
+		}
+		maxFiles := cfg.Backup.MaxFiles
+		if maxFiles == 0 {
+			maxFiles = defaultBackupMaxFiles
+		}
+		return backup.NewArchiveSink(directory, int64(maxSize)<<20, max(maxFiles, 0), store.Decode), nil
+	default:
+		return nil, fmt.Errorf("unknown backup sink %s; must be one of %s", cfg.Backup.Sink, strings.Join(backup.Sinks, ", "))
+	}
+}
+
 func (a *action) backup(path string, before map[string]any, after map[string]any) {
-	bc := a.initializer.Config().Backup.Collection
-	if len(bc) == 0 {
-		bc = defaultBackupCollection
+	if a.dryRun() {
+		return
 	}
 
-	b := map[string]any{
-		"created_at": time.Now(),
-		"path":       path,
-		"before":     before,
-		"after":      after,
+	sink, err := a.backupSink()
+	if err != nil {
+		fmt.Printf("Failed to create backup: %s\n", err)
+		return
 	}
+	defer func() { _ = sink.Close() }()
 
-	bi := fmt.Sprintf("%d", time.Now().UnixMilli())
+	a.writeBackup(sink, path, before, after)
+}
+
+// writeBackup backs up a change to an open sink, for commands that change many documents.
+func (a *action) writeBackup(sink backup.Sink, path string, before map[string]any, after map[string]any) {
+	if before == nil && after == nil {
+		// there's no document state to restore, as when a whole collection is deleted
+		return
+	}
 
-	err := a.initializer.Firestore().Create(fmt.Sprintf("%s/%s", bc, bi), b)
+	now := time.Now()
+	err := sink.Write(backup.Record{
+		ID:        backup.NewID(now),
+		CreatedAt: now,
+		Path:      path,
+		Project:   a.initializer.Config().ProjectID,
+		Database:  client.DatabaseID(a.initializer.Config()),
+		Before:    before,
+		After:     after,
+	})
 	if err != nil {
 		fmt.Printf("Failed to create backup: %s\n", err)
 	}
@@ -0,0 +1,280 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/backup"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"jhight.com/firestore-cli/pkg/api/client/diff"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	flagPath  = "path"
+	flagSince = "since"
+	flagUntil = "until"
+	flagYes   = "yes"
+)
+
+const defaultBackupListLimit = 20
+
+func Backup(root Action) Action {
+	a := &action{
+		initializer: root.Initializer(),
+	}
+
+	a.command = &cobra.Command{
+		Use:   "backup",
+		Short: "Browse the backups taken before changes",
+		Long:  "Browse the backup records written by the commands listed under backup.commands in the configuration file. Records are kept wherever backup.sink says: a collection of the database being changed (firestore, the default), a collection in another project or database (project), JSON files in a local directory (directory), or a rotating compressed NDJSON archive (archive). Each record holds a document's path and its state before and after the change. Use restore to put a document back the way it was.",
+		Example: strings.ReplaceAll(`%E backup list
+%E backup list --path users/user-1234
+%E backup list --since 24h`, "%E", os.Args[0]),
+	}
+
+	a.addHelpFlag()
+	a.Add(backupList(a))
+
+	return a
+}
+
+func backupList(root Action) Action {
+	a := &action{
+		initializer: root.Initializer(),
+	}
+
+	a.command = &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List backups, newest first",
+		Long:    "List backup records, newest first, with their ID, time, document path and the kind of change they recorded (create, update or delete). Pass an ID to restore to put that document back the way it was before the change.",
+		Example: strings.ReplaceAll(`- the 20 most recent backups
+	%E backup list
+
+- every backup of a document in the last week
+	%E backup list --path users/user-1234 --since 168h --limit 0
+
+- backups taken on a given day
+	%E backup list --since 2024-06-01T00:00:00Z --until 2024-06-02T00:00:00Z`, "%E", os.Args[0]),
+		Args:    cobra.NoArgs,
+		PreRunE: a.initializer.Initialize,
+		RunE:    a.runBackupList,
+	}
+
+	a.addHelpFlag()
+	a.command.Flags().String(flagPath, "", "Only list backups of this document")
+	a.command.Flags().String(flagSince, "", "Only list backups taken at or after this time (RFC 3339, or a duration ago such as 24h)")
+	a.command.Flags().String(flagUntil, "", "Only list backups taken before this time (RFC 3339, or a duration ago such as 24h)")
+	a.command.Flags().IntP(flagLimit, "l", defaultBackupListLimit, "List at most this many backups (0 for no limit)")
+
+	return a
+}
+
+func (a *action) runBackupList(_ *cobra.Command, _ []string) error {
+	a.handleHelpFlag()
+
+	since, err := parseTimeFlag(a.command.Flag(flagSince).Value.String())
+	if err != nil {
+		return err
+	}
+	until, err := parseTimeFlag(a.command.Flag(flagUntil).Value.String())
+	if err != nil {
+		return err
+	}
+	limit, err := strconv.Atoi(a.command.Flag(flagLimit).Value.String())
+	if err != nil {
+		return err
+	}
+
+	sink, err := a.backupSink()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = sink.Close() }()
+
+	filter := backup.Filter{
+		Path:  strings.Trim(a.command.Flag(flagPath).Value.String(), "/"),
+		Since: since,
+		Until: until,
+		Limit: limit,
+	}
+
+	entries := make([]map[string]any, 0)
+	err = sink.List(filter, func(r backup.Record) error {
+		entries = append(entries, map[string]any{
+			"id":         r.ID,
+			"created_at": r.CreatedAt,
+			"path":       r.Path,
+			"project":    r.Project,
+			"database":   r.Database,
+			"change":     r.Change(),
+		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error reading backups, %s", err)
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No backups found")
+		return nil
+	}
+
+	a.printOutput(entries)
+	return nil
+}
+
+// parseTimeFlag parses a time given as RFC 3339 or as a duration before now. An empty value is the zero time.
+func parseTimeFlag(value string) (time.Time, error) {
+	if len(value) == 0 {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return t, fmt.Errorf("invalid time %s; must be RFC 3339 (e.g., 2024-06-01T00:00:00Z) or a duration (e.g., 24h)", value)
+	}
+	return t, nil
+}
+
+func Restore(root Action) Action {
+	a := &action{
+		initializer: root.Initializer(),
+	}
+
+	a.command = &cobra.Command{
+		Use:   "restore <backup-id>",
+		Short: "Put a document back the way it was before a backed up change",
+		Long:  "Write the state a document had before the change recorded in a backup, re-creating the document if it was deleted, or deleting it if it was created. The differences between the document as it is now and as it will be are shown first, and nothing is written until confirmed. Subcollections are left alone. Find backup IDs with backup list.",
+		Example: strings.ReplaceAll(`- find the latest backup of a document, then restore it
+	%E backup list --path users/user-1234 --limit 1
+	%E restore 1718035200000`, "%E", os.Args[0]),
+		Args:    cobra.ExactArgs(1),
+		PreRunE: a.initializer.Initialize,
+		RunE:    a.runRestore,
+	}
+
+	a.addHelpFlag()
+	a.command.Flags().BoolP(flagYes, "y", false, "Restore without asking for confirmation")
+
+	return a
+}
+
+func (a *action) runRestore(_ *cobra.Command, args []string) error {
+	a.handleHelpFlag()
+
+	id := strings.TrimPrefix(strings.Trim(args[0], "/"), a.backupCollection()+"/")
+	store := a.initializer.Firestore()
+
+	sink, err := a.backupSink()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = sink.Close() }()
+
+	record, err := sink.Get(id)
+	if errors.Is(err, backup.ErrNotFound) {
+		return fmt.Errorf("backup %s not found", id)
+	}
+	if err != nil {
+		return fmt.Errorf("error reading backup %s, %s", id, err)
+	}
+
+	path := record.Path
+	if len(path) == 0 || store.IsPathToCollection(path) {
+		return fmt.Errorf("backup %s is not of a document, so it can't be restored", id)
+	}
+	if record.Before == nil && record.After == nil {
+		// such as a backup of a collection delete, or of a document that couldn't be read
+		return fmt.Errorf("backup %s holds no state of %s, so it can't be restored", id, path)
+	}
+
+	cfg := a.initializer.Config()
+	if (len(record.Project) > 0 && record.Project != cfg.ProjectID) || (len(record.Database) > 0 && record.Database != client.DatabaseID(cfg)) {
+		return fmt.Errorf("backup %s was taken in project %s, database %s; use --project-id and --database to restore it there", id, record.Project, record.Database)
+	}
+
+	before, after := record.Before, record.After
+
+	current, err := store.Get(query.Input{Path: path})
+	if err != nil && !client.IsNotFound(err) {
+		return fmt.Errorf("error reading %s, %s", path, err)
+	}
+	exists := err == nil
+
+	changes := diff.Compare(current, before)
+	if exists == (before != nil) && len(changes) == 0 {
+		fmt.Printf("%s already matches backup %s\n", path, id)
+		return nil
+	}
+
+	if exists != (after != nil) || (exists && !diff.Equal(current, after)) {
+		_, _ = fmt.Fprintf(os.Stderr, "Note: %s has changed since backup %s was taken\n", path, id)
+	}
+
+	switch {
+	case before == nil:
+		printColored(colorRed, fmt.Sprintf("- %s", path))
+	case !exists:
+		printColored(colorGreen, fmt.Sprintf("+ %s", path))
+		printDiff(changes, "    ")
+	default:
+		printColored(colorYellow, fmt.Sprintf("~ %s", path))
+		printDiff(changes, "    ")
+	}
+
+	if a.command.Flag(flagYes).Value.String() != "true" {
+		confirmed, err := a.confirm(fmt.Sprintf("Restore %s from backup %s?", path, id))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Restore cancelled")
+			return nil
+		}
+	}
+
+	// only the document is deleted; any subcollections it has are left alone
+	w := client.Write{Mode: client.WriteModeDelete, Path: path}
+	if before != nil {
+		// the document is written exactly as it was, so strings that look like functions aren't evaluated
+		w = client.Write{Mode: client.WriteModeSet, Path: path, Fields: codec.Encode(before).(map[string]any), Raw: true}
+	}
+
+	var writeErr error
+	err = store.BulkWrite(single(w), func(_ client.Write, e error) { writeErr = e })
+	if err == nil {
+		err = writeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error restoring %s, %s", path, err)
+	}
+
+	if slices.Contains(cfg.Backup.Commands, "restore") {
+		a.backup(path, current, before)
+	}
+
+	a.printWritten("%s restored from backup %s\n", path, id)
+	return nil
+}
+
+// single returns a BulkWrite source that yields one write.
+func single(w client.Write) func() (client.Write, bool) {
+	pending := true
+	return func() (client.Write, bool) {
+		if !pending {
+			return client.Write{}, false
+		}
+		pending = false
+		return w, true
+	}
+}
@@ -0,0 +1,148 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const flagAll = "all"
+
+// addMatchFlags adds the flags that select the documents of a collection for a command that changes many at once.
+func (a *action) addMatchFlags(verb string) {
+	a.command.Flags().StringP(flagFilter, "f", "", fmt.Sprintf("%s the documents of a collection matching this filter expression (see get --help for syntax)", verb))
+	a.command.Flags().StringP(flagWhere, "w", "", fmt.Sprintf("Alias for filter by expression (--%s)", flagFilter))
+	a.command.Flags().IntP(flagLimit, "l", 0, fmt.Sprintf("%s at most this many documents", verb))
+	a.command.Flags().Bool(flagAll, false, fmt.Sprintf("%s every document of the collection, without a filter or limit", verb))
+	a.command.Flags().BoolP(flagYes, "y", false, "Don't ask for confirmation")
+}
+
+// matchInput builds the query for the documents of a collection that a command changes. A path starting with **/
+// selects a collection group.
+func (a *action) matchInput(path string) (query.Input, error) {
+	input := query.Input{Path: path}
+
+	if strings.HasPrefix(path, query.CollectionGroupPrefix) {
+		input.Path = strings.TrimPrefix(path, query.CollectionGroupPrefix)
+		input.CollectionGroup = true
+	}
+
+	filter := a.command.Flag(flagFilter).Value.String()
+	if !a.command.Flag(flagFilter).Changed {
+		filter = a.command.Flag(flagWhere).Value.String()
+	}
+	if len(filter) > 0 {
+		if err := json.Unmarshal([]byte(filter), &input.Filter); err != nil {
+			return input, fmt.Errorf("query parse failure, %s; see help for more information on query syntax", err)
+		}
+	}
+
+	if a.command.Flag(flagLimit).Changed {
+		limit, err := strconv.Atoi(a.command.Flag(flagLimit).Value.String())
+		if err != nil {
+			return input, err
+		}
+		input.Limit = limit
+	}
+
+	return input, nil
+}
+
+// isMatchPath reports whether a path names the documents a query selects (a collection or collection group) rather
+// than a single document.
+func (a *action) isMatchPath(path string) bool {
+	return strings.HasPrefix(path, query.CollectionGroupPrefix) || a.initializer.Firestore().IsPathToCollection(path)
+}
+
+// hasMatchFilter reports whether the documents to change were narrowed down with a filter or limit.
+func (a *action) hasMatchFilter() bool {
+	return a.command.Flag(flagFilter).Changed || a.command.Flag(flagWhere).Changed || a.command.Flag(flagLimit).Changed
+}
+
+// requireMatchFilter returns an error unless the documents to change were narrowed down with a filter or limit, or
+// --all was given, so a collection path given in place of a document path doesn't change every document in it.
+func (a *action) requireMatchFilter(path string, verb string) error {
+	if a.hasMatchFilter() || a.command.Flag(flagAll).Value.String() == "true" {
+		return nil
+	}
+	return fmt.Errorf("%s is a collection; use --%s or --%s to choose documents, or --%s to %s every document in it", path, flagFilter, flagLimit, flagAll, strings.ToLower(verb))
+}
+
+// confirmMatches counts the documents a query matches and, unless --yes is given, asks whether to go ahead, e.g.,
+// "Update 12 documents in users?". It returns the count and whether to go ahead.
+func (a *action) confirmMatches(input query.Input, verb string) (int, bool, error) {
+	counted := input
+	counted.Count = true
+
+	result, err := a.initializer.Firestore().Aggregate(counted)
+	if err != nil {
+		return 0, false, fmt.Errorf("error counting documents, %s", err)
+	}
+	count, _ := result[query.AggregateCount].(int64)
+
+	if count == 0 {
+		fmt.Println("No documents match")
+		return 0, false, nil
+	}
+
+	if a.command.Flag(flagYes).Value.String() == "true" {
+		return int(count), true, nil
+	}
+	confirmed, err := a.confirm(fmt.Sprintf("%s %d documents in %s?", verb, count, input.Path))
+	if err != nil {
+		return int(count), false, err
+	}
+	if !confirmed {
+		fmt.Printf("%s cancelled\n", verb)
+	}
+	return int(count), confirmed, nil
+}
+
+// writeMatches sends a write for each document a query matches through the bulk writer, as write describes it, and
+// calls done with each outcome. With recursive set, the documents in subcollections of each match are written too.
+// Documents are read while earlier ones are written, so memory use stays bounded. write runs on the goroutine reading
+// documents, while done runs on the caller's.
+func (a *action) writeMatches(input query.Input, recursive bool, write func(record map[string]any) client.Write, done func(w client.Write, err error)) error {
+	store := a.initializer.Firestore()
+
+	return client.Stream(func(emit func(w client.Write) error) error {
+		err := store.Export(input, recursive, func(record map[string]any) error {
+			return emit(write(record))
+		})
+		if err != nil {
+			return fmt.Errorf("error reading %s, %s", input.Path, err)
+		}
+		return nil
+	}, func(next func() (client.Write, bool)) error {
+		return store.BulkWrite(next, done)
+	})
+}
+
+// beforeStates keeps the state of each document before it's written, for backups: it's put on the goroutine reading
+// documents and taken once the write is done, on another.
+type beforeStates struct {
+	mu     sync.Mutex
+	states map[string]map[string]any
+}
+
+func newBeforeStates() *beforeStates {
+	return &beforeStates{states: make(map[string]map[string]any)}
+}
+
+func (b *beforeStates) put(path string, state map[string]any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.states[path] = state
+}
+
+func (b *beforeStates) take(path string) map[string]any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state := b.states[path]
+	delete(b.states, path)
+	return state
+}
@@ -0,0 +1,101 @@
+package actions
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const defaultCheckpointDirectory = "~/.firestore-cli/checkpoints"
+
+// checkpointInterval is how often a checkpoint is saved while a delete runs.
+const checkpointInterval = time.Second
+
+// checkpointTTL is how long a checkpoint can be resumed from; documents added before it since then would be missed.
+const checkpointTTL = 24 * time.Hour
+
+// checkpoint records how far an interrupted delete of a collection got: every document up to and including After is
+// gone, along with its subcollections.
+type checkpoint struct {
+	Project   string    `json:"project"`
+	Database  string    `json:"database"`
+	Path      string    `json:"path"`
+	After     string    `json:"after"`
+	Deleted   int       `json:"deleted"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	file  string
+	saved time.Time
+}
+
+// loadCheckpoint reads the checkpoint left by an interrupted delete of path, or starts a new one if there isn't one.
+func (a *action) loadCheckpoint(path string) (*checkpoint, error) {
+	cfg := a.initializer.Config()
+	c := &checkpoint{
+		Project:  cfg.ProjectID,
+		Database: client.DatabaseID(cfg),
+		Path:     strings.Trim(path, "/"),
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join([]string{c.Project, c.Database, c.Path}, "/")))
+	c.file = filepath.Join(expandPath(defaultCheckpointDirectory), hex.EncodeToString(sum[:8])+".json")
+
+	data, err := os.ReadFile(c.file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading checkpoint, %s", err)
+	}
+	if err = json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("error reading checkpoint %s, %s", c.file, err)
+	}
+
+	if age := time.Since(c.UpdatedAt); age > checkpointTTL {
+		_, _ = fmt.Fprintf(os.Stderr, "Ignoring the checkpoint of an earlier delete of %s, left %s ago\n", c.Path, age.Round(time.Minute))
+		c.After, c.Deleted = "", 0
+	}
+
+	return c, nil
+}
+
+// update moves the checkpoint on, saving it if it hasn't been saved in a while.
+func (c *checkpoint) update(after string, deleted int) {
+	c.After = after
+	c.Deleted = deleted
+	if time.Since(c.saved) >= checkpointInterval {
+		c.save()
+	}
+}
+
+// save writes the checkpoint to disk. A checkpoint that can't be saved only means a resumed delete starts over, so
+// the error is printed rather than returned.
+func (c *checkpoint) save() {
+	c.saved = time.Now()
+	c.UpdatedAt = c.saved
+
+	data, err := json.Marshal(c)
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(c.file), 0700); err == nil {
+			err = os.WriteFile(c.file, data, 0600)
+		}
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Failed to save checkpoint: %s\n", err)
+	}
+}
+
+// remove deletes the checkpoint once the delete is complete.
+func (c *checkpoint) remove() {
+	if err := os.Remove(c.file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		_, _ = fmt.Fprintf(os.Stderr, "Failed to remove checkpoint: %s\n", err)
+	}
+}
@@ -0,0 +1,156 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"os"
+	"slices"
+	"strings"
+)
+
+const flagConflict = "conflict"
+
+func Copy(root Action) Action {
+	a := &action{
+		initializer: root.Initializer(),
+	}
+
+	a.command = &cobra.Command{
+		Use:     "copy <source-path> <destination-path>",
+		Aliases: []string{"cp"},
+		Short:   "Copy a document or collection",
+		Long:    "Copy a Firestore document, or every document in a collection, to another path. Documents are copied exactly, keeping every Firestore type, and written in batches. Use --recursive to copy subcollections too.",
+		Example: strings.ReplaceAll(`- copy a document
+	%E copy users/user-1234 users/user-5678
+
+- copy a user and all their subcollections, replacing any documents already there
+	%E copy users/user-1234 archive/user-1234 --recursive --conflict overwrite
+
+- copy a collection, leaving documents that already exist at the destination alone
+	%E copy users users-staging --conflict skip`, "%E", os.Args[0]),
+		Args:    cobra.ExactArgs(2),
+		PreRunE: a.initializer.Initialize,
+		RunE:    a.runCopy,
+	}
+
+	a.addCopyFlags()
+
+	return a
+}
+
+func Move(root Action) Action {
+	a := &action{
+		initializer: root.Initializer(),
+	}
+
+	a.command = &cobra.Command{
+		Use:     "move <source-path> <destination-path>",
+		Aliases: []string{"mv"},
+		Short:   "Move a document or collection",
+		Long:    "Move a Firestore document, or every document in a collection, to another path. Documents are copied exactly, keeping every Firestore type, and the source documents are only deleted once every document has been written. Use --recursive to move subcollections too.",
+		Example: strings.ReplaceAll(`- rename a document
+	%E move users/user-1234 users/john-doe
+
+- move a collection and all its subcollections
+	%E move users archive/2024/users --recursive`, "%E", os.Args[0]),
+		Args:    cobra.ExactArgs(2),
+		PreRunE: a.initializer.Initialize,
+		RunE:    a.runMove,
+	}
+
+	a.addCopyFlags()
+
+	return a
+}
+
+func (a *action) addCopyFlags() {
+	a.addHelpFlag()
+	a.command.Flags().BoolP(flagRecursive, "r", false, "Include subcollections")
+	a.command.Flags().String(flagConflict, string(client.ConflictFail), "What to do when a destination document already exists: overwrite it, skip it, or fail (nothing is written if any exists)")
+}
+
+func (a *action) runCopy(_ *cobra.Command, args []string) error {
+	a.handleHelpFlag()
+	return a.copy(args, false)
+}
+
+func (a *action) runMove(_ *cobra.Command, args []string) error {
+	a.handleHelpFlag()
+	return a.copy(args, true)
+}
+
+func (a *action) copy(args []string, move bool) error {
+	source := strings.Trim(args[0], "/")
+	destination := strings.Trim(args[1], "/")
+	recursive := a.command.Flag(flagRecursive).Value.String() == "true"
+
+	conflict := client.ConflictPolicy(a.command.Flag(flagConflict).Value.String())
+	if !slices.Contains(client.ConflictPolicies, conflict) {
+		return fmt.Errorf("invalid conflict policy %s; must be one of overwrite, skip or fail", conflict)
+	}
+
+	copied, skipped, failed := make([]string, 0), 0, 0
+	done := func(w client.Write, err error) {
+		switch {
+		case errors.Is(err, client.ErrExists):
+			skipped++
+		case err != nil:
+			failed++
+			_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", w.Path, err)
+		default:
+			// keep the source path, so a move can delete exactly what was copied
+			copied = append(copied, source+strings.TrimPrefix(w.Path, destination))
+		}
+	}
+
+	if err := a.initializer.Firestore().Copy(source, destination, recursive, conflict, done); err != nil {
+		return fmt.Errorf("error copying %s, %s", source, err)
+	}
+
+	fmt.Printf("%d documents copied, %d skipped, %d failed\n", len(copied), skipped, failed)
+	if !move {
+		return nil
+	}
+
+	if skipped > 0 || failed > 0 {
+		return fmt.Errorf("%s was not deleted, because not every document was copied", source)
+	}
+
+	return a.deleteSources(copied)
+}
+
+// deleteSources deletes the source documents of a move, once they have all been copied.
+func (a *action) deleteSources(paths []string) error {
+	deleted, failed := 0, 0
+
+	next := func() (client.Write, bool) {
+		if len(paths) == 0 {
+			return client.Write{}, false
+		}
+		w := client.Write{Mode: client.WriteModeDelete, Path: paths[0]}
+		paths = paths[1:]
+		return w, true
+	}
+
+	done := func(w client.Write, err error) {
+		if err != nil {
+			failed++
+			_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", w.Path, err)
+			return
+		}
+		deleted++
+	}
+
+	if err := a.initializer.Firestore().BulkWrite(next, done); err != nil {
+		return err
+	}
+
+	fmt.Printf("%d source documents deleted, %d failed\n", deleted, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d source documents could not be deleted", failed)
+	}
+
+	return nil
+}
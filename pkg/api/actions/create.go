@@ -1,10 +1,9 @@
 package actions
 
 import (
-	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/spf13/cobra"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
 	"os"
 	"strings"
 )
@@ -49,7 +48,7 @@ func (a *action) runCreate(_ *cobra.Command, args []string) error {
 	}
 
 	var u any
-	if err := json.Unmarshal([]byte(jsonValue), &u); err != nil {
+	if err := codec.Unmarshal([]byte(jsonValue), &u); err != nil {
 		return err
 	}
 
@@ -62,6 +61,6 @@ func (a *action) runCreate(_ *cobra.Command, args []string) error {
 		return errors.New("invalid JSON format")
 	}
 
-	fmt.Printf("%s successfully created\n", path)
+	a.printWritten("%s successfully created\n", path)
 	return nil
 }
@@ -3,10 +3,18 @@ package actions
 import (
 	"fmt"
 	"github.com/spf13/cobra"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/backup"
 	"jhight.com/firestore-cli/pkg/api/client/query"
 	"os"
 	"slices"
 	"strings"
+	"time"
+)
+
+const (
+	flagSubcollections = "subcollections"
+	flagRestart        = "restart"
 )
 
 func Delete(root Action) Action {
@@ -16,17 +24,25 @@ func Delete(root Action) Action {
 
 	a.command = &cobra.Command{
 		Use:   "delete <path> [<field>]",
-		Short: "Delete a collection, document, or field",
-		Long:  "Delete a Firestore collection, document, or field.",
+		Short: "Delete a collection, document, or field, or the documents matching a filter",
+		Long:  "Delete a Firestore collection, document, or field. Given a collection path and --filter (or --limit), only the documents of the collection that match are deleted, along with their subcollections with --subcollections. Given a collection path and one or more fields, those fields are deleted from every matching document instead. Without --filter or --limit, --all is needed to delete fields from every document of a collection, or every document of a collection group. Either way, the number of matching documents is confirmed first.\n\nA collection or document is deleted along with all of its subcollections, listing and deleting many documents at once, with progress shown as it goes. An interrupted collection delete leaves a checkpoint, and running the same delete again within a day offers to resume where it left off (--restart starts over).",
 		Example: strings.ReplaceAll(`%E delete users/1234
 %E delete users
-%E delete users/1234 field_to_remove`, "%E", os.Args[0]),
+%E delete users/1234 field_to_remove
+%E delete users --filter '{"active":false}'
+%E delete users --filter '{"active":false}' --subcollections --limit 100
+%E delete users --filter '{"address.city":"Chicago"}' nickname,age
+%E delete '**/orders' --filter '{"status":"cancelled"}' --yes
+%E delete users nickname --all`, "%E", os.Args[0]),
 		Args:    cobra.MinimumNArgs(1),
 		PreRunE: a.initializer.Initialize,
 		RunE:    a.runDelete,
 	}
 
 	a.addHelpFlag()
+	a.addMatchFlags("Delete")
+	a.command.Flags().Bool(flagSubcollections, false, "Also delete the subcollections of matching documents")
+	a.command.Flags().Bool(flagRestart, false, "Start a collection delete over, ignoring where an interrupted one left off")
 
 	return a
 }
@@ -41,13 +57,29 @@ func (a *action) runDelete(_ *cobra.Command, args []string) error {
 		fields = strings.Split(args[1], ",")
 	}
 
+	// a filter, a limit, fields or a collection group select documents to delete from, rather than the whole collection
+	if (a.hasMatchFilter() || len(fields) > 0 || strings.HasPrefix(path, query.CollectionGroupPrefix)) && a.isMatchPath(path) {
+		verb := "Delete"
+		if len(fields) > 0 {
+			verb = "Delete fields from"
+		}
+		if err := a.requireMatchFilter(path, verb); err != nil {
+			return err
+		}
+		return a.deleteMatches(path, fields)
+	}
+	if a.hasMatchFilter() {
+		return fmt.Errorf("a filter can only be applied to a collection")
+	}
+
 	// if the path is a collection, confirm the deletion
 	components := strings.Split(path, "/")
-	if len(components)%2 == 1 {
-		fmt.Printf("Delete collection %s? (y/N): ", path)
-		var response string
-		_, _ = fmt.Scanln(&response)
-		if !strings.HasPrefix(strings.TrimSpace(strings.ToUpper(response)), "Y") {
+	if len(components)%2 == 1 && a.command.Flag(flagYes).Value.String() != "true" {
+		confirmed, err := a.confirm(fmt.Sprintf("Delete collection %s?", path))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
 			fmt.Println("Deletion cancelled")
 			return nil
 		}
@@ -59,20 +91,167 @@ func (a *action) runDelete(_ *cobra.Command, args []string) error {
 	}
 
 	if len(fields) == 0 {
-		if err := a.initializer.Firestore().Delete(path); err != nil {
+		return a.deleteTree(path)
+	}
+
+	for _, field := range fields {
+		if err := a.initializer.Firestore().DeleteField(path, field); err != nil {
+			return err
+		}
+		a.printWritten("%s %s successfully deleted\n", path, field)
+	}
+
+	return nil
+}
+
+// deleteTree deletes a collection or document and everything under it, resuming an interrupted collection delete from
+// its checkpoint.
+func (a *action) deleteTree(path string) error {
+	var cp *checkpoint
+	resume := ""
+	deleted := 0
+
+	// a dry run deletes nothing, so it neither resumes nor leaves a checkpoint
+	if !a.dryRun() && a.initializer.Firestore().IsPathToCollection(path) {
+		var err error
+		if cp, err = a.loadCheckpoint(path); err != nil {
 			return err
+		}
+		if a.command.Flag(flagRestart).Value.String() == "true" {
+			cp.After, cp.Deleted = "", 0
+		}
+		// the checkpoint is only keyed by path, so it may be from a delete the user has forgotten about
+		question := fmt.Sprintf("Resume the delete of %s after %s, where an earlier delete left off %s ago?", path, cp.After, time.Since(cp.UpdatedAt).Round(time.Second))
+		if len(cp.After) > 0 && a.command.Flag(flagYes).Value.String() != "true" {
+			resumed, err := a.confirm(question)
+			if err != nil {
+				return err
+			}
+			if !resumed {
+				cp.After, cp.Deleted = "", 0
+			}
+		}
+		if len(cp.After) > 0 {
+			resume, deleted = cp.After, cp.Deleted
+			_, _ = fmt.Fprintf(os.Stderr, "Resuming the delete of %s after %s, where an earlier delete left off\n", path, cp.After)
+		}
+	}
+
+	start := time.Now()
+	p := a.newProgress("deleted", 0)
 
+	var checkpointed func(resume string)
+	if cp != nil {
+		checkpointed = func(resume string) {
+			cp.update(resume, deleted+p.done-p.failed)
 		}
-		fmt.Printf("%s successfully deleted\n", path)
+	}
+
+	done := func(w client.Write, err error) {
+		p.add(w.Path, err)
+	}
+
+	err := a.initializer.Firestore().Delete(path, resume, done, checkpointed)
+	p.finish()
+
+	if cp != nil {
+		// a checkpoint is kept while there's something left to resume after
+		if (err == nil && p.failed == 0) || len(cp.After) == 0 {
+			cp.remove()
+		} else {
+			cp.save()
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error deleting %s, %s", path, err)
+	}
+
+	// the documents an interrupted delete got through count too
+	total := deleted + p.done - p.failed
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if p.failed > 0 {
+		a.printWritten("%s partially deleted: %d documents deleted, %d failed in %s; run the delete again to retry\n", path, total, p.failed, elapsed)
 		return nil
 	}
+	a.printWritten("%s successfully deleted: %d documents in %s\n", path, total, elapsed)
+	return nil
+}
 
-	for _, field := range fields {
-		if err := a.initializer.Firestore().DeleteField(path, field); err != nil {
+// deleteMatches deletes every document of a collection matching the filter, or the given fields from each of them.
+func (a *action) deleteMatches(path string, fields []string) error {
+	subcollections := a.command.Flag(flagSubcollections).Value.String() == "true"
+	if subcollections && len(fields) > 0 {
+		return fmt.Errorf("--%s can't be used when deleting fields", flagSubcollections)
+	}
+
+	input, err := a.matchInput(path)
+	if err != nil {
+		return err
+	}
+
+	count, ok, err := a.confirmMatches(input, "Delete")
+	if err != nil || !ok {
+		return err
+	}
+
+	// the state of each document before it's changed, kept until the write is done, when backing up
+	var sink backup.Sink
+	before := newBeforeStates()
+	if slices.Contains(a.initializer.Config().Backup.Commands, "delete") && !a.dryRun() {
+		if sink, err = a.backupSink(); err != nil {
 			return err
 		}
-		fmt.Printf("%s %s successfully deleted\n", path, field)
+		defer func() { _ = sink.Close() }()
+	}
+
+	updates := make(map[string]any, len(fields))
+	for _, field := range fields {
+		updates[field] = query.FunctionDelete + "()"
+	}
+
+	write := func(record map[string]any) client.Write {
+		_, documentPath, data := query.SplitRecord(record)
+		if sink != nil {
+			before.put(documentPath, data)
+		}
+		if len(fields) > 0 {
+			return client.Write{Mode: client.WriteModeUpdate, Path: documentPath, Fields: updates}
+		}
+		return client.Write{Mode: client.WriteModeDelete, Path: documentPath}
+	}
+
+	// documents in subcollections aren't counted up front, so the total isn't known
+	total := count
+	if subcollections {
+		total = 0
+	}
+
+	verb := "deleted"
+	if len(fields) > 0 {
+		verb = "updated"
+	}
+
+	p := a.newProgress(verb, total)
+	done := func(w client.Write, err error) {
+		p.add(w.Path, err)
+		if sink != nil {
+			state := before.take(w.Path)
+			if err == nil {
+				var after map[string]any
+				if w.Mode != client.WriteModeDelete {
+					after, _ = a.initializer.Firestore().Get(query.Input{Path: w.Path})
+				}
+				a.writeBackup(sink, w.Path, state, after)
+			}
+		}
+	}
+
+	err = a.writeMatches(input, subcollections, write, done)
+	p.finish()
+	if err != nil {
+		return err
 	}
 
+	a.printWritten("%d documents %s, %d failed\n", p.done-p.failed, verb, p.failed)
 	return nil
 }
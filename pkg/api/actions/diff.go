@@ -0,0 +1,318 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"io"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"jhight.com/firestore-cli/pkg/api/client/diff"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const flagPatch = "patch"
+
+const (
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorReset  = "\033[0m"
+)
+
+func Diff(root Action) Action {
+	a := &action{
+		initializer: root.Initializer(),
+	}
+
+	a.command = &cobra.Command{
+		Use:   "diff <path-a> <path-b>",
+		Short: "Show the differences between documents or collections",
+		Long:  "Compare two documents, two collections, or a document or collection with a local JSON file, and print the field paths that were added, removed or changed to get from the first to the second. Values are compared as Firestore types, so an integer and a double with the same value differ, and timestamps, references and other types are compared by value rather than by their JSON form. Collections are compared document by document, matching on ID. A file holds a single JSON object to compare with a document; to compare with a collection, it holds NDJSON or a JSON array of records with $id or $path (such as the output of export), or an object keyed by document ID.",
+		Example: strings.ReplaceAll(`- compare two documents
+	%E diff users/user-1234 users/user-5678
+
+- see what setting a document from a file would change
+	%E diff users/user-1234 user.json
+
+- compare a collection with an export of it, as JSON Patch
+	%E diff users users.ndjson --patch`, "%E", os.Args[0]),
+		Args:    cobra.ExactArgs(2),
+		PreRunE: a.initializer.Initialize,
+		RunE:    a.runDiff,
+	}
+
+	a.addHelpFlag()
+	a.command.Flags().Bool(flagPatch, false, "Print the differences as JSON Patch (RFC 6902) operations")
+
+	return a
+}
+
+func (a *action) runDiff(_ *cobra.Command, args []string) error {
+	a.handleHelpFlag()
+
+	// a file takes the shape of the Firestore path it is compared with
+	collection := false
+	for _, arg := range args {
+		if !isLocalFile(arg) {
+			collection = a.initializer.Firestore().IsPathToCollection(strings.Trim(arg, "/"))
+			break
+		}
+	}
+
+	sides := make([]map[string]any, 0, 2)
+	for _, arg := range args {
+		side, err := a.diffSide(arg, collection)
+		if err != nil {
+			return err
+		}
+		sides = append(sides, side)
+	}
+
+	changes := diff.Compare(sides[0], sides[1])
+
+	if a.command.Flag(flagPatch).Value.String() == "true" {
+		if len(changes) == 0 {
+			fmt.Println("[]")
+			return nil
+		}
+		a.printOutput(diff.Patch(changes))
+		return nil
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("No differences")
+		return nil
+	}
+
+	if collection {
+		printCollectionDiff(changes, strings.Trim(args[0], "/"), strings.Trim(args[1], "/"))
+	} else {
+		printDiff(changes, "")
+	}
+
+	return nil
+}
+
+// isLocalFile reports whether an argument names a local JSON file rather than a Firestore path. A file that doesn't
+// exist is only recognised by its extension when the argument is a path, since a bare name such as users.json is also a
+// valid collection ID.
+func isLocalFile(arg string) bool {
+	if stat, err := os.Stat(arg); err == nil {
+		return stat.Mode().IsRegular()
+	}
+	if !strings.ContainsAny(arg, "/"+string(os.PathSeparator)) {
+		return false
+	}
+
+	switch strings.ToLower(filepath.Ext(arg)) {
+	case ".json", ".ndjson":
+		return true
+	}
+	return false
+}
+
+// diffSide reads one side of a diff: a document, or a collection as a map of document ID to document.
+func (a *action) diffSide(arg string, collection bool) (map[string]any, error) {
+	if isLocalFile(arg) {
+		return a.readDiffFile(arg, collection)
+	}
+
+	path := strings.Trim(arg, "/")
+	store := a.initializer.Firestore()
+
+	if store.IsPathToCollection(path) != collection {
+		return nil, fmt.Errorf("can't compare a document with a collection, %s", path)
+	}
+
+	if !collection {
+		document, err := store.Get(query.Input{Path: path})
+		if err != nil && !client.IsNotFound(err) {
+			return nil, fmt.Errorf("error reading %s, %s", path, err)
+		}
+		return document, nil
+	}
+
+	documents := make(map[string]any)
+	err := store.Export(query.Input{Path: path}, false, func(record map[string]any) error {
+		id, _, data := query.SplitRecord(record)
+		documents[id] = data
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s, %s", path, err)
+	}
+
+	return documents, nil
+}
+
+func (a *action) readDiffFile(path string, collection bool) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s, %s", path, err)
+	}
+
+	var side map[string]any
+	trimmed := bytes.TrimSpace(data)
+
+	if collection && !bytes.HasPrefix(trimmed, []byte("[")) && codec.Unmarshal(trimmed, &side) == nil && !isRecord(side) {
+		// an object keyed by document ID
+		for id, document := range side {
+			if _, ok := document.(map[string]any); !ok {
+				return nil, fmt.Errorf("%s: document %s is not a JSON object", path, id)
+			}
+		}
+	} else if collection {
+		side = make(map[string]any)
+		records, err := newRecordReader(bytes.NewReader(trimmed))
+		if err != nil {
+			return nil, err
+		}
+		for {
+			_, record, err := records.next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: record %d: %s", path, records.count, err)
+			}
+			id, p, data := query.SplitRecord(record)
+			if len(id) == 0 && len(p) > 0 {
+				id = p[strings.LastIndex(p, "/")+1:]
+			}
+			if len(id) == 0 {
+				return nil, fmt.Errorf("%s: record %d has neither %s nor %s", path, records.count, query.SelectionDocumentPath, query.SelectionDocumentID)
+			}
+			side[id] = data
+		}
+	} else {
+		if err = codec.Unmarshal(trimmed, &side); err != nil || side == nil {
+			return nil, fmt.Errorf("%s must hold a single JSON object to compare with a document", path)
+		}
+		delete(side, query.SelectionDocumentID)
+		delete(side, query.SelectionDocumentPath)
+	}
+
+	decoded, err := a.initializer.Firestore().Decode(side)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	// a single tagged value, such as {"$ref":"users/1"}, decodes to something other than a document
+	side, ok := decoded.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s holds a single tagged value rather than a JSON object; put another $ in front of a field named like a tag", path)
+	}
+	return side, nil
+}
+
+func isRecord(m map[string]any) bool {
+	_, id := m[query.SelectionDocumentID]
+	_, path := m[query.SelectionDocumentPath]
+	return id || path
+}
+
+// printDiff prints changes one per line: removals in red, additions in green and changes in yellow.
+func printDiff(changes []diff.Change, indent string) {
+	for _, c := range changes {
+		field := diff.FieldPath(c.Path)
+		switch c.Op {
+		case diff.OpRemove:
+			printColored(colorRed, fmt.Sprintf("%s- %s: %s", indent, field, diffValue(c.From)))
+		case diff.OpAdd:
+			printColored(colorGreen, fmt.Sprintf("%s+ %s: %s", indent, field, diffValue(c.To)))
+		case diff.OpReplace:
+			printColored(colorYellow, fmt.Sprintf("%s~ %s: %s → %s", indent, field, diffValue(c.From), diffValue(c.To)))
+		}
+	}
+}
+
+// printCollectionDiff prints the changes between two collections, grouped by document.
+func printCollectionDiff(changes []diff.Change, left string, right string) {
+	for len(changes) > 0 {
+		id, _ := changes[0].Path[0].(string)
+
+		end := 1
+		for end < len(changes) && changes[end].Path[0] == id {
+			end++
+		}
+		document := changes[:end]
+		changes = changes[end:]
+
+		if len(document) == 1 && len(document[0].Path) == 1 {
+			switch document[0].Op {
+			case diff.OpAdd:
+				printColored(colorGreen, fmt.Sprintf("+ %s", documentLabel(right, id)))
+				continue
+			case diff.OpRemove:
+				printColored(colorRed, fmt.Sprintf("- %s", documentLabel(left, id)))
+				continue
+			}
+		}
+
+		printColored(colorYellow, fmt.Sprintf("~ %s", documentLabel(left, id)))
+		for i := range document {
+			document[i].Path = document[i].Path[1:]
+		}
+		printDiff(document, "    ")
+	}
+}
+
+func documentLabel(side string, id string) string {
+	if isLocalFile(side) {
+		return fmt.Sprintf("%s (%s)", id, side)
+	}
+	return side + "/" + id
+}
+
+func printColored(color string, line string) {
+	if colorize() {
+		line = color + line + colorReset
+	}
+	fmt.Println(line)
+}
+
+// colorize reports whether output should be colored: only on a terminal, and never when NO_COLOR is set.
+func colorize() bool {
+	return len(os.Getenv("NO_COLOR")) == 0 && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+func diffValue(value any) string {
+	encoded, err := json.Marshal(codec.Encode(value))
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(encoded)
+}
+
+// printPlan prints a write planned in a dry run: the path, marked as it would be created (+), changed (~) or deleted
+// (-), followed by the fields that would change.
+func printPlan(p client.Plan) {
+	path := p.Write.Path
+
+	if p.Write.Mode == client.WriteModeDelete {
+		if len(strings.Split(path, "/"))%2 == 1 {
+			printColored(colorRed, fmt.Sprintf("- %s (collection)", path))
+			return
+		}
+		printColored(colorRed, fmt.Sprintf("- %s", path))
+		printDiff(diff.Compare(p.Before, nil), "    ")
+		return
+	}
+
+	changes := diff.Compare(p.Before, p.After)
+	switch {
+	case p.Before == nil:
+		printColored(colorGreen, fmt.Sprintf("+ %s", path))
+	case len(changes) == 0:
+		fmt.Printf("= %s (unchanged)\n", path)
+	default:
+		printColored(colorYellow, fmt.Sprintf("~ %s", path))
+	}
+	printDiff(changes, "    ")
+}
@@ -0,0 +1,90 @@
+package actions
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/cobra"
+	"io"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"os"
+	"strings"
+)
+
+const (
+	flagRecursive = "recursive"
+	flagOutput    = "output"
+)
+
+func Export(root Action) Action {
+	a := &action{
+		initializer: root.Initializer(),
+	}
+
+	a.command = &cobra.Command{
+		Use:   "export <path>",
+		Short: "Stream a collection or document subtree as NDJSON",
+		Long:  "Export documents at the specified collection or document path as newline-delimited JSON, one document per line. Each line holds the document fields along with its $id and $path. Documents are streamed one at a time, so memory use stays constant regardless of collection size.",
+		Example: strings.ReplaceAll(`- export a collection to stdout
+	%E export users
+
+- export a collection and all of its subcollections to a file
+	%E export users --recursive --output users.ndjson
+
+- export a single document and its subcollections
+	%E export users/user-1234 -r | gzip > user-1234.ndjson.gz`, "%E", os.Args[0]),
+		Args:    cobra.ExactArgs(1),
+		PreRunE: a.initializer.Initialize,
+		RunE:    a.runExport,
+	}
+
+	a.addHelpFlag()
+	a.command.Flags().BoolP(flagRecursive, "r", false, "Recurse into subcollections")
+	a.command.Flags().String(flagOutput, "", "Write to this file instead of stdout")
+
+	return a
+}
+
+func (a *action) runExport(_ *cobra.Command, args []string) error {
+	a.handleHelpFlag()
+
+	path := strings.TrimSuffix(args[0], "/")
+	recursive := a.command.Flag(flagRecursive).Value.String() == "true"
+
+	var out io.Writer = a.command.OutOrStdout()
+	output := a.command.Flag(flagOutput).Value.String()
+	if len(output) > 0 {
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("error creating output file, %s", err)
+		}
+		defer func() { _ = file.Close() }()
+		out = file
+	}
+
+	writer := bufio.NewWriter(out)
+	encoder := json.NewEncoder(writer)
+
+	exported := 0
+	err := a.initializer.Firestore().Export(query.Input{Path: path}, recursive, func(document map[string]any) error {
+		if err := encoder.Encode(codec.Encode(document)); err != nil {
+			return fmt.Errorf("error writing document, %s", err)
+		}
+		exported++
+		return nil
+	})
+
+	if flushErr := writer.Flush(); err == nil && flushErr != nil {
+		err = fmt.Errorf("error writing output, %s", flushErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(output) > 0 {
+		fmt.Printf("%d documents exported to %s\n", exported, output)
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/spf13/cobra"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
 	"jhight.com/firestore-cli/pkg/api/client/query"
 	"os"
 	"regexp"
@@ -11,6 +12,11 @@ import (
 	"strings"
 )
 
+const (
+	pageDocuments = "$documents"
+	pageNextToken = "$nextPageToken"
+)
+
 const (
 	flagFilter  = "filter"
 	flagWhere   = "where"
@@ -18,6 +24,15 @@ const (
 	flagLimit   = "limit"
 	flagOffset  = "offset"
 	flagCount   = "count"
+	flagGroup   = "group"
+	flagSum     = "sum"
+	flagAvg     = "avg"
+
+	flagStartAt    = "start-at"
+	flagStartAfter = "start-after"
+	flagEndAt      = "end-at"
+	flagEndBefore  = "end-before"
+	flagPageToken  = "page-token"
 )
 
 func Get(root Action) Action {
@@ -67,6 +82,24 @@ func Get(root Action) Action {
 - get the count of all users with address.city of "New York"
 	%E get users --filter '{"address.city":"New York"}' --count
 
+- get the count, total and average order price of all orders over $100 (computed server-side)
+	%E get orders --filter '{"price":{">":100}}' --count --sum price --avg price
+
+- get every orders subcollection, under all users, with the path of each order (so its parent user is visible)
+	%E get orders '$path,price' --group --filter '{"price":{">":100}}'
+
+- same as above, using the **/ path syntax instead of --group
+	%E get '**/orders' '$path,price' --filter '{"price":{">":100}}'
+
+- get users ordered by age, starting after age 30 (cursors take order by field values, or a document path)
+	%E get users --order age --start-after 30
+
+- get users ordered by last and first name, from "Doe", "John" up to but excluding users/user-5678
+	%E get users --order lastName,firstName --start-at '["Doe","John"]' --end-before users/user-5678
+
+- page through users 50 at a time; pass '' to get the first page, then the $nextPageToken of each page to get the next
+	%E get users --filter '{"active":true}' --limit 50 --page-token ''
+
 - get all the id of all users, ordered by name and limited to 10
 	%E get users --order name --limit 10`, "%E", os.Args[0]),
 		PreRunE: a.initializer.Initialize,
@@ -80,6 +113,14 @@ func Get(root Action) Action {
 	a.command.Flags().IntP(flagLimit, "l", 0, "Limit integer value.")
 	a.command.Flags().Int(flagOffset, 0, "Offset integer value.")
 	a.command.Flags().Bool(flagCount, false, "Return only the count of documents matching query.")
+	a.command.Flags().String(flagSum, "", "Return the sum of these comma-separated numeric fields over documents matching query.")
+	a.command.Flags().String(flagAvg, "", "Return the average of these comma-separated numeric fields over documents matching query.")
+	a.command.Flags().String(flagStartAt, "", "Start at this cursor: order by field value(s) as JSON (e.g., 30 or '[\"Doe\",30]'), or a document path.")
+	a.command.Flags().String(flagStartAfter, "", "Start after this cursor: order by field value(s) as JSON, or a document path.")
+	a.command.Flags().String(flagEndAt, "", "End at this cursor: order by field value(s) as JSON, or a document path.")
+	a.command.Flags().String(flagEndBefore, "", "End before this cursor: order by field value(s) as JSON, or a document path.")
+	a.command.Flags().String(flagPageToken, "", "Return a page of --limit documents (default 100) with a $nextPageToken for the next page. Pass '' for the first page.")
+	a.command.Flags().Bool(flagGroup, false, fmt.Sprintf("Query every collection with the given ID, wherever it is nested (a collection group query). Same as prefixing the path with %s.", query.CollectionGroupPrefix))
 
 	return a
 }
@@ -102,6 +143,13 @@ func (a *action) runGet(_ *cobra.Command, args []string) error {
 		Fields: fields,
 	}
 
+	if strings.HasPrefix(path, query.CollectionGroupPrefix) {
+		input.Path = strings.TrimPrefix(path, query.CollectionGroupPrefix)
+		input.CollectionGroup = true
+	} else if a.command.Flag(flagGroup).Changed {
+		input.CollectionGroup = a.command.Flag(flagGroup).Value.String() == "true"
+	}
+
 	filterString := ""
 	if a.command.Flag(flagFilter).Changed {
 		filterString = a.command.Flag(flagFilter).Value.String()
@@ -160,19 +208,58 @@ func (a *action) runGet(_ *cobra.Command, args []string) error {
 		input.Offset = offset
 	}
 
+	cursors := map[string]**query.Cursor{
+		flagStartAt:    &input.StartAt,
+		flagStartAfter: &input.StartAfter,
+		flagEndAt:      &input.EndAt,
+		flagEndBefore:  &input.EndBefore,
+	}
+	for flag, cursor := range cursors {
+		if a.command.Flag(flag).Changed {
+			*cursor = parseCursor(a.command.Flag(flag).Value.String())
+		}
+	}
+
+	paged := a.command.Flag(flagPageToken).Changed
+	if paged {
+		input.PageToken = a.command.Flag(flagPageToken).Value.String()
+	}
+
 	if a.command.Flag(flagCount).Changed {
-		input.Count = true
+		input.Count = a.command.Flag(flagCount).Value.String() == "true"
+	}
+
+	if a.command.Flag(flagSum).Changed {
+		input.Sum = splitFields(a.command.Flag(flagSum).Value.String())
+	}
+
+	if a.command.Flag(flagAvg).Changed {
+		input.Avg = splitFields(a.command.Flag(flagAvg).Value.String())
 	}
 
 	var err error
-	if a.initializer.Firestore().IsPathToCollection(path) {
+	if (input.CollectionGroup || a.initializer.Firestore().IsPathToCollection(path)) && input.Aggregates() {
+		var aggregates map[string]any
+		aggregates, err = a.initializer.Firestore().Aggregate(input)
+		a.handleAggregateOutput(input, aggregates)
+	} else if (input.CollectionGroup || a.initializer.Firestore().IsPathToCollection(path)) && paged {
 		var docs []map[string]any
-		docs, err = a.initializer.Firestore().Query(input)
-		a.handleOutput(false, docs, fields)
+		var next string
+		docs, next, err = a.initializer.Firestore().QueryPage(input)
+		if err == nil {
+			a.printOutput(map[string]any{pageDocuments: docs, pageNextToken: next})
+		}
+	} else if input.CollectionGroup || a.initializer.Firestore().IsPathToCollection(path) {
+		err = a.streamOutput(input, fields)
 	} else if a.initializer.Firestore().IsPathToDocument(path) {
+		if input.Aggregates() {
+			return fmt.Errorf("--%s, --%s and --%s aggregate the documents of a collection, and %s is a document", flagCount, flagSum, flagAvg, path)
+		}
 		var doc map[string]any
 		doc, err = a.initializer.Firestore().Get(input)
-		a.handleOutput(true, []map[string]any{doc}, fields)
+		if err == nil {
+			a.printOutput(doc)
+		}
 	}
 
 	if err != nil && strings.Contains(fmt.Sprintf("%s", err), "The query requires an index. You can create it here") {
@@ -188,30 +275,73 @@ func (a *action) runGet(_ *cobra.Command, args []string) error {
 	return err
 }
 
-func (a *action) handleOutput(isDocument bool, docs []map[string]any, fields []string) {
-	if a.command.Flag(flagCount).Value.String() == "true" {
-		if a.initializer.Config().Flatten {
-			a.printOutput(len(docs))
-		} else {
-			a.printOutput(map[string]any{"$count": len(docs)})
+func (a *action) handleAggregateOutput(input query.Input, aggregates map[string]any) {
+	if len(aggregates) == 0 {
+		return
+	}
+
+	// with a single aggregation, flatten prints just its value
+	if a.initializer.Config().Flatten {
+		switch {
+		case input.Count && len(input.Sum)+len(input.Avg) == 0:
+			a.printOutput(aggregates[query.AggregateCount])
+			return
+		case !input.Count && len(input.Sum) == 1 && len(input.Avg) == 0:
+			a.printOutput(aggregates[query.AggregateSum].(map[string]any)[input.Sum[0]])
+			return
+		case !input.Count && len(input.Sum) == 0 && len(input.Avg) == 1:
+			a.printOutput(aggregates[query.AggregateAvg].(map[string]any)[input.Avg[0]])
+			return
 		}
-	} else if isDocument && len(docs) == 1 {
-		a.printOutput(docs[0])
-	} else if a.initializer.Config().Flatten && len(fields) == 1 {
-		flattened := make([]any, 0)
-		for _, doc := range docs {
-			for _, v := range doc {
-				flattened = append(flattened, v)
-				break
-			}
+	}
+
+	a.printOutput(aggregates)
+}
+
+// parseCursor reads a cursor flag as JSON field value(s), falling back to a document path, then a plain string.
+func parseCursor(value string) *query.Cursor {
+	var parsed any
+	if err := codec.Unmarshal([]byte(value), &parsed); err == nil {
+		if values, ok := parsed.([]any); ok {
+			return &query.Cursor{Values: values}
+		}
+		return &query.Cursor{Values: []any{parsed}}
+	}
+
+	if segments := strings.Split(value, "/"); len(segments) >= 2 && len(segments)%2 == 0 {
+		return &query.Cursor{DocumentPath: value}
+	}
+
+	return &query.Cursor{Values: []any{value}}
+}
+
+func splitFields(fields string) []string {
+	split := make([]string, 0)
+	for _, field := range strings.Split(fields, ",") {
+		if field = strings.TrimSpace(field); len(field) > 0 {
+			split = append(split, field)
 		}
+	}
+	return split
+}
+
+// streamOutput prints query results as they are read, flattening them to values when a single field is selected.
+func (a *action) streamOutput(input query.Input, fields []string) error {
+	flatten := a.initializer.Config().Flatten && len(fields) == 1
+	writer := a.newDocumentWriter(flatten)
 
-		if len(flattened) == 1 {
-			a.printOutput(flattened[0])
-		} else {
-			a.printOutput(flattened)
+	err := a.initializer.Firestore().Query(input, func(doc map[string]any) error {
+		if !flatten {
+			return writer.write(doc)
+		}
+		for _, v := range doc {
+			return writer.write(v)
 		}
-	} else {
-		a.printOutput(docs)
+		return nil
+	})
+
+	if closeErr := writer.close(); err == nil {
+		err = closeErr
 	}
+	return err
 }
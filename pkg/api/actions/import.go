@@ -0,0 +1,276 @@
+package actions
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"io"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"os"
+	"slices"
+	"strings"
+)
+
+const (
+	flagInput   = "input"
+	flagMode    = "mode"
+	flagRejects = "rejects"
+)
+
+const defaultRejectsFile = "import.rejects.ndjson"
+
+func Import(root Action) Action {
+	a := &action{
+		initializer: root.Initializer(),
+	}
+
+	a.command = &cobra.Command{
+		Use:   "import [<collection>]",
+		Short: "Bulk import documents from NDJSON or a JSON array",
+		Long:  "Import documents from newline-delimited JSON (such as the output of export) or a JSON array of objects. Each record must carry a $path, or a $id when a collection is given. Writes are batched, and a failed record doesn't stop the import; failed records are written to a rejects file so they can be fixed and imported again.",
+		Example: strings.ReplaceAll(`- import an export back into the same paths
+	%E import --input users.ndjson
+
+- import a JSON array of fixtures keyed by $id into a collection, failing on existing documents
+	%E import users --input fixtures.json --mode create
+
+- merge fields from stdin into existing documents
+	cat patch.ndjson | %E import --mode merge`, "%E", os.Args[0]),
+		Args:    cobra.MaximumNArgs(1),
+		PreRunE: a.initializer.Initialize,
+		RunE:    a.runImport,
+	}
+
+	a.addHelpFlag()
+	a.command.Flags().StringP(flagInput, "i", "", "Read records from this file instead of stdin")
+	a.command.Flags().String(flagMode, string(client.WriteModeSet), "Write semantics: create (fail if the document exists), set (replace), or merge (update the given fields, creating if needed)")
+	a.command.Flags().String(flagRejects, "", fmt.Sprintf("Write failed records to this file (defaults to <input>.rejects, or %s when reading stdin)", defaultRejectsFile))
+
+	return a
+}
+
+func (a *action) runImport(_ *cobra.Command, args []string) error {
+	a.handleHelpFlag()
+
+	collection := ""
+	if len(args) > 0 {
+		collection = strings.TrimSuffix(args[0], "/")
+	}
+
+	mode := client.WriteMode(a.command.Flag(flagMode).Value.String())
+	if !slices.Contains([]client.WriteMode{client.WriteModeCreate, client.WriteModeSet, client.WriteModeMerge}, mode) {
+		return fmt.Errorf("invalid mode %s; must be one of create, set or merge", mode)
+	}
+
+	var in io.Reader
+	rejectsPath := a.command.Flag(flagRejects).Value.String()
+	if input := a.command.Flag(flagInput).Value.String(); len(input) > 0 {
+		file, err := os.Open(input)
+		if err != nil {
+			return fmt.Errorf("error opening input file, %s", err)
+		}
+		defer func() { _ = file.Close() }()
+		in = file
+		if len(rejectsPath) == 0 {
+			rejectsPath = input + ".rejects"
+		}
+	} else if a.shouldReadFromStdin() {
+		in = a.command.InOrStdin()
+	} else {
+		return errors.New("records must be piped to stdin or given with --input")
+	}
+	if len(rejectsPath) == 0 {
+		rejectsPath = defaultRejectsFile
+	}
+
+	records, err := newRecordReader(in)
+	if err != nil {
+		return err
+	}
+
+	rejects := &rejectWriter{path: rejectsPath}
+	defer rejects.close()
+
+	written, failed := 0, 0
+	pending := make([][]byte, 0)
+	var readErr error
+
+	next := func() (client.Write, bool) {
+		for {
+			raw, fields, err := records.next()
+			if err == io.EOF {
+				return client.Write{}, false
+			}
+			if err != nil && raw == nil {
+				// the stream itself is unreadable, so there is nothing more to pull
+				failed++
+				readErr = fmt.Errorf("import stopped at record %d, %s", records.count+1, err)
+				return client.Write{}, false
+			}
+
+			var path string
+			if err == nil {
+				path, fields, err = importPath(collection, fields)
+			}
+			if err != nil {
+				failed++
+				_, _ = fmt.Fprintf(os.Stderr, "record %d: %s\n", records.count, err)
+				rejects.write(raw)
+				continue
+			}
+
+			pending = append(pending, raw)
+			return client.Write{Mode: mode, Path: path, Fields: fields}, true
+		}
+	}
+
+	done := func(w client.Write, err error) {
+		raw := pending[0]
+		pending = pending[1:]
+
+		if err != nil {
+			failed++
+			_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", w.Path, err)
+			rejects.write(raw)
+			return
+		}
+		written++
+	}
+
+	if err = a.initializer.Firestore().BulkWrite(next, done); err != nil {
+		return err
+	}
+
+	fmt.Printf("%d documents written, %d failed\n", written, failed)
+	if rejects.err != nil {
+		return fmt.Errorf("error writing rejects file, %s", rejects.err)
+	}
+	if rejects.file != nil {
+		fmt.Printf("Failed records written to %s\n", rejects.path)
+	}
+
+	return readErr
+}
+
+// importPath determines the document path of a record from its $path, or its $id within the collection, and returns
+// it along with the fields of the document.
+func importPath(collection string, record map[string]any) (string, map[string]any, error) {
+	id, path, fields := query.SplitRecord(record)
+
+	if len(path) > 0 {
+		// accept full resource names as well as paths relative to the database root
+		if _, relative, ok := strings.Cut(path, "/documents/"); ok {
+			path = relative
+		}
+		return path, fields, nil
+	}
+
+	if len(id) > 0 {
+		if len(collection) == 0 {
+			return "", nil, fmt.Errorf("record has %s but no collection was given", query.SelectionDocumentID)
+		}
+		return collection + "/" + id, fields, nil
+	}
+
+	return "", nil, fmt.Errorf("record has neither %s nor %s", query.SelectionDocumentPath, query.SelectionDocumentID)
+}
+
+// recordReader streams JSON objects from either NDJSON or a JSON array, one at a time.
+type recordReader struct {
+	reader  *bufio.Reader
+	decoder *json.Decoder
+	count   int
+}
+
+func newRecordReader(r io.Reader) (*recordReader, error) {
+	rr := &recordReader{reader: bufio.NewReader(r)}
+
+	// a leading '[' means a JSON array; anything else is treated as NDJSON
+	for {
+		b, err := rr.reader.Peek(1)
+		if err == io.EOF {
+			return rr, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading input, %s", err)
+		}
+		if b[0] == ' ' || b[0] == '\t' || b[0] == '\r' || b[0] == '\n' {
+			_, _ = rr.reader.ReadByte()
+			continue
+		}
+		if b[0] == '[' {
+			rr.decoder = json.NewDecoder(rr.reader)
+			if _, err = rr.decoder.Token(); err != nil {
+				return nil, fmt.Errorf("error reading JSON array, %s", err)
+			}
+		}
+		return rr, nil
+	}
+}
+
+// next returns the raw bytes and decoded object of the next record. A record that is readable but not a valid object
+// is returned with its raw bytes and an error, so the caller can reject it and continue.
+func (r *recordReader) next() ([]byte, map[string]any, error) {
+	var raw []byte
+
+	if r.decoder != nil {
+		if !r.decoder.More() {
+			return nil, nil, io.EOF
+		}
+		var message json.RawMessage
+		if err := r.decoder.Decode(&message); err != nil {
+			return nil, nil, fmt.Errorf("error reading JSON array, %s", err)
+		}
+		raw = message
+	} else {
+		for len(raw) == 0 {
+			line, err := r.reader.ReadBytes('\n')
+			if err == io.EOF && len(bytes.TrimSpace(line)) == 0 {
+				return nil, nil, io.EOF
+			}
+			if err != nil && err != io.EOF {
+				return nil, nil, fmt.Errorf("error reading input, %s", err)
+			}
+			raw = bytes.TrimSpace(line)
+		}
+	}
+
+	r.count++
+
+	var fields map[string]any
+	if err := codec.Unmarshal(raw, &fields); err != nil || fields == nil {
+		return raw, nil, fmt.Errorf("record is not a JSON object")
+	}
+
+	return raw, fields, nil
+}
+
+// rejectWriter lazily creates the rejects file on the first rejected record.
+type rejectWriter struct {
+	path string
+	file *os.File
+	err  error
+}
+
+func (w *rejectWriter) write(raw []byte) {
+	if w.err != nil {
+		return
+	}
+	if w.file == nil {
+		if w.file, w.err = os.Create(w.path); w.err != nil {
+			return
+		}
+	}
+	_, w.err = w.file.Write(append(raw, '\n'))
+}
+
+func (w *rejectWriter) close() {
+	if w.file != nil {
+		_ = w.file.Close()
+	}
+}
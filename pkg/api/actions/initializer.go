@@ -13,46 +13,95 @@ import (
 )
 
 type Initializer interface {
+	Configure(cmd *cobra.Command, _ []string) error
 	Initialize(cmd *cobra.Command, _ []string) error
 	Firestore() client.Store
 	Config() config.Config
+	Open(cfg config.Config) (client.Store, error)
 }
 
 type initializer struct {
+	configured  bool
 	initialized bool
 	cfg         config.Config
+	base        config.Config
 	firestore   client.Store
+	dryRun      bool
+	open        func(cfg config.Config) (client.Store, error)
 }
 
 func DefaultsInitializer(cfg config.Config, firestore client.Store) Initializer {
 	return &initializer{
+		configured:  true,
 		initialized: true,
 		cfg:         cfg,
+		base:        cfg,
 		firestore:   firestore,
+		open: func(config.Config) (client.Store, error) {
+			return firestore, nil
+		},
 	}
 }
 
-func (i *initializer) Initialize(cmd *cobra.Command, _ []string) error {
-	if i.initialized {
+// Configure loads the config file, active profile and flags without connecting to Firestore.
+func (i *initializer) Configure(cmd *cobra.Command, _ []string) error {
+	if i.configured {
 		return nil
 	}
 
 	var err error
-
 	if i.cfg, err = i.loadConfig(cmd); err != nil {
 		return err
 	}
 
+	i.configured = true
+
+	return nil
+}
+
+func (i *initializer) Initialize(cmd *cobra.Command, args []string) error {
+	// set for each command, since the shell runs many with one initializer
+	i.dryRun = cmd.Flag(flagDryRun) != nil && cmd.Flag(flagDryRun).Value.String() == "true"
+	if i.dryRun {
+		_, _ = fmt.Fprintln(cmd.ErrOrStderr(), "Dry run: nothing will be written")
+	}
+
+	if i.initialized {
+		// the output flags of each command apply to that command alone
+		var err error
+		i.cfg, err = withOutputFlags(i.base, cmd)
+		return err
+	}
+
+	err := i.Configure(cmd, args)
+	if err != nil {
+		return err
+	}
+
 	if i.firestore, err = client.New(context.Background(), i.cfg); err != nil {
 		return err
 	}
 
+	// the project may have been detected from the credentials
+	i.cfg.ProjectID = i.firestore.Credentials().ProjectID
+
+	// make the active backend visible whenever a command fails, or always when using the emulator
+	cmd.Root().SetErrPrefix(fmt.Sprintf("Error (%s):", client.Backend(i.cfg)))
+	if len(i.cfg.EmulatorHost) > 0 {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Using Firestore %s\n", client.Backend(i.cfg))
+	}
+
+	i.base = i.cfg
 	i.initialized = true
 
 	return nil
 }
 
+// Firestore returns the store commands read and write through. In a dry run, writes are printed as plans instead.
 func (i *initializer) Firestore() client.Store {
+	if i.dryRun && i.firestore != nil {
+		return client.DryRun(i.firestore, printPlan)
+	}
 	return i.firestore
 }
 
@@ -60,6 +109,23 @@ func (i *initializer) Config() config.Config {
 	return i.cfg
 }
 
+// Open connects to Firestore with a config other than the command's own, such as the destination of a transfer. The
+// caller closes the store.
+func (i *initializer) Open(cfg config.Config) (client.Store, error) {
+	open := i.open
+	if open == nil {
+		open = func(cfg config.Config) (client.Store, error) {
+			return client.New(context.Background(), cfg)
+		}
+	}
+
+	store, err := open(cfg)
+	if err == nil && i.dryRun {
+		store = client.DryRun(store, printPlan)
+	}
+	return store, err
+}
+
 func (i *initializer) loadConfig(cmd *cobra.Command) (config.Config, error) {
 	// try to read config file
 	var err error
@@ -71,6 +137,17 @@ func (i *initializer) loadConfig(cmd *cobra.Command) (config.Config, error) {
 		i.cfg = config.Config{}
 	}
 
+	// apply the selected profile, falling back to the default profile
+	profile := i.cfg.DefaultProfile
+	if cmd.Flag(flagProfile).Changed && len(cmd.Flag(flagProfile).Value.String()) > 0 {
+		profile = cmd.Flag(flagProfile).Value.String()
+	}
+	if len(profile) > 0 {
+		if i.cfg, err = i.cfg.WithProfile(profile); err != nil {
+			return config.Config{}, fmt.Errorf("%s; profiles must be defined in config file (%s)", err, path)
+		}
+	}
+
 	// override config file data with command-line flags
 	if cmd.Flag(flagServiceAccount).Changed && len(cmd.Flag(flagServiceAccount).Value.String()) > 0 {
 		i.cfg.ServiceAccount = cmd.Flag(flagServiceAccount).Value.String()
@@ -78,44 +155,72 @@ func (i *initializer) loadConfig(cmd *cobra.Command) (config.Config, error) {
 	if cmd.Flag(flagProjectID).Changed && len(cmd.Flag(flagProjectID).Value.String()) > 0 {
 		i.cfg.ProjectID = cmd.Flag(flagProjectID).Value.String()
 	}
+	if cmd.Flag(flagDatabaseID).Changed && len(cmd.Flag(flagDatabaseID).Value.String()) > 0 {
+		i.cfg.DatabaseID = cmd.Flag(flagDatabaseID).Value.String()
+	}
+	if cmd.Flag(flagAccessTokenFile).Changed && len(cmd.Flag(flagAccessTokenFile).Value.String()) > 0 {
+		i.cfg.AccessTokenFile = cmd.Flag(flagAccessTokenFile).Value.String()
+	}
+	if cmd.Flag(flagEmulator).Changed && len(cmd.Flag(flagEmulator).Value.String()) > 0 {
+		i.cfg.EmulatorHost = cmd.Flag(flagEmulator).Value.String()
+	}
+	i.cfg = client.WithEmulatorEnv(i.cfg)
+	return withOutputFlags(i.cfg, cmd)
+}
+
+// withOutputFlags overrides the output settings of the config with those given as flags.
+func withOutputFlags(cfg config.Config, cmd *cobra.Command) (config.Config, error) {
 	if cmd.Flag(flagPrettyPrint).Changed && len(cmd.Flag(flagPrettyPrint).Value.String()) > 0 {
-		i.cfg.PrettyPrint = cmd.Flag(flagPrettyPrint).Value.String() == "true"
+		cfg.PrettyPrint = cmd.Flag(flagPrettyPrint).Value.String() == "true"
 	}
 	if cmd.Flag(flagRawPrint).Changed && len(cmd.Flag(flagRawPrint).Value.String()) > 0 {
-		i.cfg.RawPrint = cmd.Flag(flagRawPrint).Value.String() == "true"
+		cfg.RawPrint = cmd.Flag(flagRawPrint).Value.String() == "true"
 	}
 	if cmd.Flag(flagSpacing).Changed && len(cmd.Flag(flagSpacing).Value.String()) > 0 {
 		amt, _ := strconv.Atoi(cmd.Flag(flagSpacing).Value.String())
-		i.cfg.PrettySpacing = amt
+		cfg.PrettySpacing = amt
+	}
+	if cmd.Flag(flagFormat).Changed && len(cmd.Flag(flagFormat).Value.String()) > 0 {
+		cfg.Format = cmd.Flag(flagFormat).Value.String()
 	}
 	if cmd.Flag(flagFlatten).Changed && len(cmd.Flag(flagFlatten).Value.String()) > 0 {
-		i.cfg.Flatten = cmd.Flag(flagFlatten).Value.String() == "true"
+		cfg.Flatten = cmd.Flag(flagFlatten).Value.String() == "true"
 	}
 
-	// make sure required fields are set
-	if len(i.cfg.ServiceAccount) == 0 {
-		return config.Config{}, fmt.Errorf("service account file path must either be defined in config file (%s) or provided as flag (--%s=...)", path, flagServiceAccount)
-	}
-	if len(i.cfg.ProjectID) == 0 {
-		return config.Config{}, fmt.Errorf("project ID must either be defined in config file (%s) or provided as flag (--%s=...)", path, flagProjectID)
+	if len(cfg.Format) > 0 && cfg.Format != formatJSON && cfg.Format != formatNDJSON {
+		return config.Config{}, fmt.Errorf("unknown output format %s; must be %s or %s", cfg.Format, formatJSON, formatNDJSON)
 	}
 
-	return i.cfg, nil
+	return cfg, nil
 }
 
 func (i *initializer) readConfigFile(path string) error {
-	home := os.Getenv("HOME")
-	path = strings.ReplaceAll(path, "~", home)
+	cfg, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+
+	i.cfg = cfg
+	return nil
+}
 
-	file, err := os.ReadFile(path)
+func readConfigFile(path string) (config.Config, error) {
+	var cfg config.Config
+
+	file, err := os.ReadFile(expandPath(path))
 	if err != nil {
-		return fmt.Errorf("error reading config file, %s", err)
+		return cfg, fmt.Errorf("error reading config file, %s", err)
 	}
 
-	err = yaml.Unmarshal(file, &i.cfg)
+	err = yaml.Unmarshal(file, &cfg)
 	if err != nil {
-		return fmt.Errorf("error unmarshalling config file, %s", err)
+		return cfg, fmt.Errorf("error unmarshalling config file, %s", err)
 	}
 
-	return nil
+	return cfg, nil
+}
+
+func expandPath(path string) string {
+	home := os.Getenv("HOME")
+	return strings.ReplaceAll(path, "~", home)
 }
@@ -2,22 +2,129 @@ package actions
 
 import (
 	"encoding/json"
+	"fmt"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"strings"
+)
+
+const (
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
 )
 
 func (a *action) toJSON(value any) (string, error) {
 	var bytes []byte
 	var err error
 
-	if a.initializer.Config().PrettyPrint && !a.initializer.Config().RawPrint {
-		spacing := ""
-		for i := 0; i < a.initializer.Config().PrettySpacing; i++ {
-			spacing += " "
-		}
+	// tag Firestore types so the output can be written back as-is
+	value = codec.Encode(value)
 
-		bytes, err = json.MarshalIndent(value, "", spacing)
+	if a.prettyPrint() {
+		bytes, err = json.MarshalIndent(value, "", a.spacing())
 	} else {
 		bytes, err = json.Marshal(value)
 	}
 
 	return string(bytes), err
 }
+
+func (a *action) prettyPrint() bool {
+	cfg := a.initializer.Config()
+	return cfg.PrettyPrint && !cfg.RawPrint && cfg.Format != formatNDJSON
+}
+
+func (a *action) spacing() string {
+	return strings.Repeat(" ", a.initializer.Config().PrettySpacing)
+}
+
+// documentWriter prints values as they arrive instead of collecting them first, either as the elements of a JSON
+// array or as NDJSON, so output starts right away and memory stays bounded. The output is identical to printing the
+// whole array with printOutput.
+type documentWriter struct {
+	a       *action
+	count   int
+	flatten bool
+	held    any
+}
+
+// newDocumentWriter creates a writer; when flatten is set, a single value is printed on its own rather than in an
+// array, which means the first value is held back until a second one arrives.
+func (a *action) newDocumentWriter(flatten bool) *documentWriter {
+	return &documentWriter{a: a, flatten: flatten}
+}
+
+func (w *documentWriter) write(value any) error {
+	if m, ok := value.(map[string]any); (ok && len(m) == 0) || value == nil {
+		return nil
+	}
+
+	if w.flatten && w.count == 0 {
+		w.held = value
+		w.count++
+		return nil
+	}
+	if w.held != nil {
+		held := w.held
+		w.held = nil
+		if err := w.element(held, true); err != nil {
+			return err
+		}
+	}
+
+	err := w.element(value, w.count == 0)
+	w.count++
+	return err
+}
+
+func (w *documentWriter) element(value any, first bool) error {
+	if w.a.initializer.Config().Format == formatNDJSON {
+		encoded, err := json.Marshal(codec.Encode(value))
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Println(string(encoded))
+		return err
+	}
+
+	var encoded []byte
+	var err error
+	if w.a.prettyPrint() {
+		spacing := w.a.spacing()
+		encoded, err = json.MarshalIndent(codec.Encode(value), spacing, spacing)
+		encoded = append([]byte(spacing), encoded...)
+	} else {
+		encoded, err = json.Marshal(codec.Encode(value))
+	}
+	if err != nil {
+		return err
+	}
+
+	separator := ","
+	if first {
+		separator = "["
+	}
+	if w.a.prettyPrint() {
+		separator += "\n"
+	}
+
+	_, err = fmt.Print(separator + string(encoded))
+	return err
+}
+
+func (w *documentWriter) close() error {
+	if w.held != nil {
+		w.a.printOutput(w.held)
+		return nil
+	}
+
+	if w.count == 0 || w.a.initializer.Config().Format == formatNDJSON {
+		return nil
+	}
+
+	closing := "]\n"
+	if w.a.prettyPrint() {
+		closing = "\n" + closing
+	}
+	_, err := fmt.Print(closing)
+	return err
+}
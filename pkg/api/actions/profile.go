@@ -0,0 +1,202 @@
+package actions
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"os"
+	"strings"
+)
+
+const keyDefaultProfile = "default-profile"
+
+func Profile(root Action) Action {
+	a := &action{
+		initializer: root.Initializer(),
+	}
+
+	a.command = &cobra.Command{
+		Use:   "profile",
+		Short: "List, inspect and switch configuration profiles",
+		Long:  "Manage the named connection profiles defined under profiles: in the configuration file. Each profile may override any top-level configuration key, such as service-account, project-id, output and backup settings. The active profile is chosen with --profile, or default-profile in the configuration file.",
+		Example: strings.ReplaceAll(`%E profile list
+%E profile show staging
+%E profile use prod`, "%E", os.Args[0]),
+	}
+
+	a.addHelpFlag()
+	a.Add(profileList(a), profileUse(a), profileShow(a))
+
+	return a
+}
+
+func profileList(root Action) Action {
+	a := &action{
+		initializer: root.Initializer(),
+	}
+
+	a.command = &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List configuration profiles",
+		Args:    cobra.NoArgs,
+		PreRunE: a.initializer.Configure,
+		RunE:    a.runProfileList,
+	}
+
+	a.addHelpFlag()
+
+	return a
+}
+
+func profileUse(root Action) Action {
+	a := &action{
+		initializer: root.Initializer(),
+	}
+
+	a.command = &cobra.Command{
+		Use:     "use <name>",
+		Short:   "Make a profile the default by writing default-profile to the configuration file",
+		Args:    cobra.ExactArgs(1),
+		PreRunE: a.initializer.Configure,
+		RunE:    a.runProfileUse,
+	}
+
+	a.addHelpFlag()
+
+	return a
+}
+
+func profileShow(root Action) Action {
+	a := &action{
+		initializer: root.Initializer(),
+	}
+
+	a.command = &cobra.Command{
+		Use:     "show [<name>]",
+		Short:   "Show the resolved configuration of a profile (the active one by default)",
+		Args:    cobra.MaximumNArgs(1),
+		PreRunE: a.initializer.Configure,
+		RunE:    a.runProfileShow,
+	}
+
+	a.addHelpFlag()
+
+	return a
+}
+
+func (a *action) runProfileList(_ *cobra.Command, _ []string) error {
+	a.handleHelpFlag()
+
+	cfg := a.initializer.Config()
+
+	profiles := make([]map[string]any, 0)
+	for _, name := range cfg.ProfileNames() {
+		profile, err := cfg.WithProfile(name)
+		if err != nil {
+			return err
+		}
+
+		profiles = append(profiles, map[string]any{
+			"name":       name,
+			"project-id": profile.ProjectID,
+			"active":     name == cfg.Profile,
+		})
+	}
+
+	a.printOutput(profiles)
+	return nil
+}
+
+func (a *action) runProfileShow(_ *cobra.Command, args []string) error {
+	a.handleHelpFlag()
+
+	cfg := a.initializer.Config()
+	if len(args) > 0 {
+		var err error
+		if cfg, err = cfg.WithProfile(args[0]); err != nil {
+			return err
+		}
+	}
+
+	// round trip through YAML so the output uses config file keys
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	var shown map[string]any
+	if err = yaml.Unmarshal(data, &shown); err != nil {
+		return err
+	}
+
+	delete(shown, "profiles")
+	delete(shown, keyDefaultProfile)
+	shown["profile"] = cfg.Profile
+
+	a.printOutput(shown)
+	return nil
+}
+
+func (a *action) runProfileUse(cmd *cobra.Command, args []string) error {
+	a.handleHelpFlag()
+
+	name := args[0]
+	if _, ok := a.initializer.Config().Profiles[name]; !ok {
+		return fmt.Errorf("unknown profile %s", name)
+	}
+
+	path, _ := cmd.Flags().GetString(flagConfigFile)
+	path = expandPath(path)
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file, %s", err)
+	}
+
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file, %s", err)
+	}
+
+	// edit the YAML tree rather than the struct, so comments and formatting survive
+	var doc yaml.Node
+	if err = yaml.Unmarshal(file, &doc); err != nil {
+		return fmt.Errorf("error unmarshalling config file, %s", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("config file %s is not a YAML mapping", path)
+	}
+
+	setMappingValue(doc.Content[0], keyDefaultProfile, name)
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(&doc); err != nil {
+		return fmt.Errorf("error marshalling config file, %s", err)
+	}
+
+	if err = os.WriteFile(path, out.Bytes(), stat.Mode().Perm()); err != nil {
+		return fmt.Errorf("error writing config file, %s", err)
+	}
+
+	fmt.Printf("Now using profile %s\n", name)
+	return nil
+}
+
+func setMappingValue(mapping *yaml.Node, key string, value string) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content[i+1].SetString(value)
+			return
+		}
+	}
+
+	k := &yaml.Node{}
+	k.SetString(key)
+	v := &yaml.Node{}
+	v.SetString(value)
+	mapping.Content = append(mapping.Content, k, v)
+}
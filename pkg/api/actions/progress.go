@@ -0,0 +1,80 @@
+package actions
+
+import (
+	"fmt"
+	"golang.org/x/term"
+	"os"
+	"time"
+)
+
+const (
+	progressInterval    = 100 * time.Millisecond
+	progressLogInterval = 10 * time.Second
+)
+
+// progress prints a running count of processed documents to stderr. On a terminal the count is rewritten in place;
+// otherwise, such as in a log file, a line is printed every so often.
+type progress struct {
+	verb     string
+	total    int
+	done     int
+	failed   int
+	silent   bool
+	terminal bool
+	printed  time.Time
+}
+
+// newProgress starts counting documents toward total, or an unknown number if total is 0. A dry run prints its plans
+// instead of a count.
+func (a *action) newProgress(verb string, total int) *progress {
+	return &progress{
+		verb:     verb,
+		total:    total,
+		silent:   a.dryRun(),
+		terminal: term.IsTerminal(int(os.Stderr.Fd())),
+		printed:  time.Now(),
+	}
+}
+
+// add counts a processed document, printing its error if it failed.
+func (p *progress) add(path string, err error) {
+	p.done++
+	if err != nil {
+		p.failed++
+		p.finish()
+		_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+	}
+
+	interval := progressLogInterval
+	if p.terminal {
+		interval = progressInterval
+	}
+	if p.silent || time.Since(p.printed) < interval {
+		return
+	}
+	p.printed = time.Now()
+	p.print()
+}
+
+func (p *progress) print() {
+	line := fmt.Sprintf("%d documents %s", p.done-p.failed, p.verb)
+	if p.total > 0 {
+		line = fmt.Sprintf("%d of %d documents %s", p.done-p.failed, p.total, p.verb)
+	}
+	if p.failed > 0 {
+		line = fmt.Sprintf("%s, %d failed", line, p.failed)
+	}
+
+	if p.terminal {
+		_, _ = fmt.Fprintf(os.Stderr, "\r\033[K%s", line)
+	} else {
+		_, _ = fmt.Fprintln(os.Stderr, line)
+	}
+}
+
+// finish clears the running count, so the summary that follows starts on a clean line.
+func (p *progress) finish() {
+	if p.terminal {
+		_, _ = fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+}
@@ -3,6 +3,7 @@ package actions
 import (
 	"fmt"
 	"github.com/spf13/cobra"
+	"jhight.com/firestore-cli/pkg/api/client"
 	"os"
 	"strings"
 )
@@ -10,15 +11,24 @@ import (
 const defaultConfigPath = "~/.firestore-cli.yaml"
 const defaultSpacing = 2
 const defaultBackupCollection = "backup"
+const defaultBackupDirectory = "~/.firestore-cli/backups"
+const defaultBackupMaxSizeMB = 64
+const defaultBackupMaxFiles = 10
 
 const (
-	flagConfigFile     = "config"
-	flagServiceAccount = "service-account"
-	flagProjectID      = "project-id"
-	flagPrettyPrint    = "pretty"
-	flagRawPrint       = "raw"
-	flagSpacing        = "spacing"
-	flagFlatten        = "flatten"
+	flagConfigFile      = "config"
+	flagProfile         = "profile"
+	flagServiceAccount  = "service-account"
+	flagProjectID       = "project-id"
+	flagDatabaseID      = "database"
+	flagEmulator        = "emulator"
+	flagAccessTokenFile = "access-token-file"
+	flagPrettyPrint     = "pretty"
+	flagRawPrint        = "raw"
+	flagSpacing         = "spacing"
+	flagFormat          = "format"
+	flagFlatten         = "flatten"
+	flagDryRun          = "dry-run"
 )
 
 func Root(i Initializer) Action {
@@ -69,11 +79,17 @@ func Root(i Initializer) Action {
 
 	root.addHelpFlag()
 	root.command.PersistentFlags().String(flagConfigFile, defaultConfigPath, "The file path to the firestore-cli configuration file")
-	root.command.PersistentFlags().StringP(flagServiceAccount, "s", "", "The file path to the Google Cloud Platform service account JSON file")
+	root.command.PersistentFlags().String(flagProfile, "", "The configuration profile to use (defaults to default-profile in the configuration file)")
+	root.command.PersistentFlags().StringP(flagServiceAccount, "s", "", "The file path to the Google Cloud Platform service account JSON file (falls back to $GOOGLE_APPLICATION_CREDENTIALS, then Application Default Credentials, then an access token)")
 	root.command.PersistentFlags().StringP(flagProjectID, "p", "", "The Google Cloud Platform project ID")
+	root.command.PersistentFlags().StringP(flagDatabaseID, "d", "", "The Firestore database ID (defaults to the (default) database)")
+	root.command.PersistentFlags().String(flagAccessTokenFile, "", fmt.Sprintf("The file path to a raw OAuth access token, used as a last resort when no other credentials are found (or set $%s)", client.AccessTokenEnv))
+	root.command.PersistentFlags().String(flagEmulator, "", fmt.Sprintf("The host:port of a Firestore emulator to connect to instead of Google Cloud (defaults to $%s, if set)", client.EmulatorHostEnv))
 	root.command.PersistentFlags().Bool(flagPrettyPrint, true, "Pretty print JSON output")
 	root.command.PersistentFlags().Bool(flagRawPrint, false, "Raw print JSON output (disables pretty print)")
 	root.command.PersistentFlags().Int(flagSpacing, defaultSpacing, "The number of spaces to use for pretty printing JSON output")
+	root.command.PersistentFlags().String(flagFormat, formatJSON, fmt.Sprintf("Output format for lists of documents: %s (a single array) or %s (one document per line)", formatJSON, formatNDJSON))
+	root.command.PersistentFlags().Bool(flagDryRun, false, "Show what a command would write, with a diff of each document, without writing anything")
 	root.command.PersistentFlags().Bool(flagFlatten, false, "Flatten output to an array of values, if more than one result (only valid when selecting a single field). If only a single result, the raw value itself is printed.")
 
 	return root
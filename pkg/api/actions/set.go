@@ -1,10 +1,9 @@
 package actions
 
 import (
-	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/spf13/cobra"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
 	"jhight.com/firestore-cli/pkg/api/client/query"
 	"os"
 	"slices"
@@ -17,10 +16,9 @@ func Set(root Action) Action {
 	}
 
 	a.command = &cobra.Command{
-		Use:     "set <path> [<json>]",
-		Aliases: []string{"import"},
-		Short:   "Set (e.g., create or replace) a document",
-		Long:    "Set the entire specified Firestore document with specified JSON data. Only the specified fields will exist in the document. If the document does not exist, it will be created.",
+		Use:   "set <path> [<json>]",
+		Short: "Set (e.g., create or replace) a document",
+		Long:  "Set the entire specified Firestore document with specified JSON data. Only the specified fields will exist in the document. If the document does not exist, it will be created.",
 		Example: strings.ReplaceAll(`%E set users/1234 '{"name": "John Doe", "age": 30, "height": 5.9, "active": true}'
 %E set users/1234/orders/5678 '{"item": "shoes", "quantity": 1, "price": 100.00}'
 cat file.json | %E set users/1234`, "%E", os.Args[0]),
@@ -54,7 +52,7 @@ func (a *action) runSet(_ *cobra.Command, args []string) error {
 	}
 
 	var fields map[string]any
-	if err := json.Unmarshal([]byte(input), &fields); err != nil {
+	if err := codec.Unmarshal([]byte(input), &fields); err != nil {
 		return err
 	}
 
@@ -72,6 +70,6 @@ func (a *action) runSet(_ *cobra.Command, args []string) error {
 		}
 	}
 
-	fmt.Printf("%s successfully set\n", path)
+	a.printWritten("%s successfully set\n", path)
 	return nil
 }
@@ -0,0 +1,615 @@
+package actions
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+	"io"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"os"
+	"os/signal"
+	"path"
+	"slices"
+	"strings"
+)
+
+const (
+	shellContinuationPrompt = "... "
+	shellCompletionLimit    = 500
+)
+
+// keys the terminal passes to the completion callback; Ctrl-C is remapped to keyCancel so that it clears the line
+// rather than ending the shell
+const (
+	keyTab    = '\t'
+	keyCtrlC  = 3
+	keyCancel = 7
+)
+
+var errShellExit = errors.New("exit")
+
+var shellBuiltins = []string{"cd", "ls", "pwd", "exit", "quit"}
+
+// shellLocationCommands only read, so they run on the current location when given no path; commands that write must be
+// given one explicitly (. for the current location)
+var shellLocationCommands = []string{"get", "collections", "watch", "export", "diff"}
+
+// shellFileCommands accept local files in place of paths
+var shellFileCommands = []string{"diff"}
+
+// shellConnectionFlags choose the connection, which stays open for the whole shell
+var shellConnectionFlags = []string{flagConfigFile, flagProfile, flagServiceAccount, flagProjectID, flagDatabaseID, flagEmulator, flagAccessTokenFile}
+
+func Shell(root Action) Action {
+	a := &action{
+		initializer: root.Initializer(),
+	}
+
+	a.command = &cobra.Command{
+		Use:   "shell",
+		Short: "Start an interactive shell",
+		Long:  "Start an interactive shell that keeps a single Firestore connection open. Navigate with cd, ls and pwd, and run any other command with paths relative to the current location (prefix a path with / to make it absolute, or use .. to go up). Commands that only read run on the current location when given no path, but commands that write need one, such as . for the current location. Tab completes commands and collection and document IDs, up and down recall history, and JSON spanning several lines is read until its brackets and quotes are closed. Exit with exit, quit or Ctrl-D.",
+		Args:  cobra.NoArgs,
+		Example: strings.ReplaceAll(`- start a shell, then browse and query a user's orders
+	%E shell
+	> cd users/user-1234
+	> ls
+	> get orders --filter '{"price":{">":100}}'
+	> update . '{
+	    "age": 31
+	  }'`, "%E", os.Args[0]),
+		PreRunE: a.initializer.Initialize,
+		RunE:    a.runShell,
+	}
+
+	a.addHelpFlag()
+
+	return a
+}
+
+func (a *action) runShell(_ *cobra.Command, _ []string) error {
+	a.handleHelpFlag()
+
+	// the flags the shell was started with are reset with the others after each command; the config keeps the output
+	// settings, but dry run is passed on to every command explicitly
+	s := &shell{a: a, root: a.command.Root(), dryRun: a.dryRun()}
+
+	// errors are printed as they happen, by the shell rather than cobra; usage would only clutter the shell
+	silenceErrors := s.root.SilenceErrors
+	s.root.SilenceUsage = true
+	s.root.SilenceErrors = true
+	defer func() { s.root.SilenceErrors = silenceErrors }()
+
+	// keep Ctrl-C from ending the shell while a command runs (commands like watch still see it)
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer func() {
+		signal.Stop(interrupts)
+		close(interrupts)
+	}()
+	go func() {
+		for range interrupts {
+		}
+	}()
+
+	in := a.command.InOrStdin()
+	if file, ok := in.(*os.File); ok && term.IsTerminal(int(file.Fd())) {
+		s.reader = newTerminalReader(file, s.complete)
+	} else {
+		s.reader = &streamReader{reader: bufio.NewReader(in)}
+	}
+
+	for {
+		line, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err = s.run(line); errors.Is(err, errShellExit) {
+			return nil
+		} else if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "%s %s\n", s.root.ErrPrefix(), err)
+		}
+	}
+}
+
+type shell struct {
+	a      *action
+	root   *cobra.Command
+	cwd    string
+	dryRun bool
+	reader lineReader
+}
+
+func (s *shell) prompt() string {
+	if s.dryRun {
+		return fmt.Sprintf("%s:/%s (dry run)> ", s.a.initializer.Config().ProjectID, s.cwd)
+	}
+	return fmt.Sprintf("%s:/%s> ", s.a.initializer.Config().ProjectID, s.cwd)
+}
+
+// read reads one command, continuing onto more lines while JSON brackets or quotes are left open.
+func (s *shell) read() (string, error) {
+	line, err := s.reader.readLine(s.prompt())
+	if err != nil {
+		return "", err
+	}
+
+	for !isComplete(line) {
+		more, err := s.reader.readLine(shellContinuationPrompt)
+		if err != nil {
+			return "", err
+		}
+		line += "\n" + more
+	}
+
+	return line, nil
+}
+
+func (s *shell) run(line string) error {
+	words, err := splitWords(line)
+	if err != nil {
+		return err
+	}
+	if len(words) == 0 {
+		return nil
+	}
+
+	switch words[0] {
+	case "exit", "quit":
+		return errShellExit
+	case "pwd":
+		fmt.Println("/" + s.cwd)
+		return nil
+	case "cd":
+		return s.cd(words[1:])
+	case "ls":
+		return s.ls(words[1:])
+	case s.a.command.Name():
+		return fmt.Errorf("already in a shell")
+	}
+
+	return s.execute(words)
+}
+
+func (s *shell) cd(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("cd takes a single path")
+	}
+
+	target := ""
+	if len(args) > 0 {
+		target = s.resolve(args[0])
+	}
+
+	store := s.a.initializer.Firestore()
+	if len(target) > 0 && !store.IsPathToDocument(target) && !store.IsPathToCollection(target) {
+		return fmt.Errorf("invalid path, %s", target)
+	}
+
+	s.cwd = target
+	return nil
+}
+
+func (s *shell) ls(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("ls takes a single path")
+	}
+
+	target := s.cwd
+	if len(args) > 0 {
+		target = s.resolve(args[0])
+	}
+
+	return s.children(target, 0, func(name string) {
+		fmt.Println(name)
+	})
+}
+
+// execute runs a regular command, with its paths resolved against the current location.
+func (s *shell) execute(words []string) error {
+	cmd, _, err := s.root.Find(words)
+	if err != nil {
+		return err
+	}
+	if cmd == s.root {
+		return fmt.Errorf("unknown command %s", words[0])
+	}
+
+	args := s.resolveArgs(cmd, words)
+	if slices.Contains(args, "--"+flagHelp) {
+		// the help flag exits the process, so show help directly
+		return cmd.Help()
+	}
+
+	if flag := s.connectionFlag(args); len(flag) > 0 {
+		return fmt.Errorf("--%s can't be changed within a shell; start a new shell instead", flag)
+	}
+
+	if s.dryRun {
+		args = append(args, "--"+flagDryRun)
+	}
+
+	s.root.SetArgs(args)
+	err = s.root.Execute()
+
+	// flag values would otherwise carry over into the next command
+	if resetErr := resetFlags(cmd); err == nil {
+		err = resetErr
+	}
+
+	return err
+}
+
+// connectionFlag returns the name of the first connection flag among the arguments, if any.
+func (s *shell) connectionFlag(args []string) string {
+	flags := s.root.PersistentFlags()
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		flag := flags.Lookup(name)
+		if !strings.HasPrefix(arg, "--") && len(name) == 1 {
+			flag = flags.ShorthandLookup(name)
+		}
+
+		if flag != nil && slices.Contains(shellConnectionFlags, flag.Name) {
+			return flag.Name
+		}
+	}
+	return ""
+}
+
+// resolveArgs resolves the path arguments of a command (those named <path>, <source-path>, <collection> and so on in
+// its usage) against the current location; local files are left alone for commands that accept them. A read-only
+// command that takes a path but is given none is run on the current location.
+func (s *shell) resolveArgs(cmd *cobra.Command, words []string) []string {
+	paths := pathArgs(cmd)
+	files := slices.Contains(shellFileCommands, cmd.Name())
+
+	names := len(strings.Fields(cmd.CommandPath())) - 1
+	args := slices.Clone(words)
+
+	position := 0
+	for i := names; i < len(args); i++ {
+		if strings.HasPrefix(args[i], "-") && args[i] != "-" {
+			if takesValue(cmd, args[i]) {
+				i++
+			}
+			continue
+		}
+
+		if paths[position] && !(files && isLocalFile(args[i])) {
+			args[i] = s.resolve(args[i])
+		}
+		position++
+	}
+
+	if position == 0 && paths[0] && len(s.cwd) > 0 && slices.Contains(shellLocationCommands, cmd.Name()) {
+		args = append(args, s.cwd)
+	}
+
+	return args
+}
+
+// resolve turns a path relative to the current location into a path relative to the database root.
+func (s *shell) resolve(p string) string {
+	if strings.HasPrefix(p, query.CollectionGroupPrefix) {
+		return p
+	}
+	if !strings.HasPrefix(p, "/") {
+		p = path.Join("/", s.cwd, p)
+	}
+	return strings.TrimPrefix(path.Clean(p), "/")
+}
+
+// children visits the IDs of the documents in a collection, or of the collections in a document (or at the root),
+// visiting at most limit documents when limit is positive.
+func (s *shell) children(p string, limit int, visit func(name string)) error {
+	store := s.a.initializer.Firestore()
+
+	if len(p) > 0 && store.IsPathToCollection(p) {
+		input := query.Input{Path: p, Fields: []string{query.SelectionDocumentID}, Limit: limit}
+		return store.Query(input, func(document map[string]any) error {
+			if id, ok := document[query.SelectionDocumentID].(string); ok {
+				visit(id)
+			}
+			return nil
+		})
+	}
+
+	collections, err := store.Collections(query.Input{Path: p})
+	if err != nil {
+		return err
+	}
+	for _, c := range collections {
+		if id, ok := c.(string); ok {
+			visit(id)
+		}
+	}
+	return nil
+}
+
+// complete is the terminal's completion callback: tab completes the command name or the path under the cursor.
+func (s *shell) complete(line string, pos int, key rune) (string, int, bool) {
+	switch key {
+	case keyCancel:
+		return "", 0, true
+	case keyTab:
+	default:
+		return "", 0, false
+	}
+
+	start := strings.LastIndexAny(line[:pos], " \t\n") + 1
+	word := line[start:pos]
+
+	candidates := make([]string, 0)
+	if len(strings.TrimSpace(line[:start])) == 0 {
+		names := slices.Clone(shellBuiltins)
+		for _, cmd := range s.root.Commands() {
+			if cmd.IsAvailableCommand() && cmd != s.a.command {
+				names = append(names, cmd.Name())
+			}
+		}
+		for _, name := range names {
+			if strings.HasPrefix(name, word) {
+				candidates = append(candidates, name+" ")
+			}
+		}
+	} else {
+		dir, prefix := "", word
+		if i := strings.LastIndex(word, "/"); i >= 0 {
+			dir, prefix = word[:i+1], word[i+1:]
+		}
+		_ = s.children(s.resolve(dir), shellCompletionLimit, func(name string) {
+			if strings.HasPrefix(name, prefix) {
+				candidates = append(candidates, dir+name+"/")
+			}
+		})
+	}
+
+	if len(candidates) == 0 {
+		return "", 0, false
+	}
+
+	completion := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, completion) {
+			completion = completion[:len(completion)-1]
+		}
+	}
+	if completion == word {
+		slices.Sort(candidates)
+		s.reader.print(strings.Join(candidates, "  ") + "\n")
+	}
+
+	return line[:start] + completion + line[pos:], start + len(completion), true
+}
+
+// takesValue reports whether a flag argument such as --limit or -l is followed by a separate value.
+func takesValue(cmd *cobra.Command, arg string) bool {
+	if strings.Contains(arg, "=") {
+		return false
+	}
+
+	name := strings.TrimLeft(arg, "-")
+	flag := cmd.Flag(name)
+	if !strings.HasPrefix(arg, "--") && len(name) == 1 {
+		if flag = cmd.Flags().ShorthandLookup(name); flag == nil {
+			flag = cmd.InheritedFlags().ShorthandLookup(name)
+		}
+	}
+
+	return flag != nil && flag.Value.Type() != "bool"
+}
+
+func pathArgs(cmd *cobra.Command) map[int]bool {
+	paths := make(map[int]bool)
+	for i, placeholder := range strings.Fields(cmd.Use)[1:] {
+		placeholder = strings.Trim(placeholder, "[]<>")
+		if strings.Contains(placeholder, "path") || strings.HasSuffix(placeholder, "collection") {
+			paths[i] = true
+		}
+	}
+	return paths
+}
+
+// resetFlags sets the flags of a command back to their defaults, including the persistent flags of the root command.
+func resetFlags(cmd *cobra.Command) error {
+	var err error
+	reset := func(flag *pflag.Flag) {
+		if setErr := flag.Value.Set(flag.DefValue); setErr != nil && err == nil {
+			err = fmt.Errorf("error resetting --%s, %s", flag.Name, setErr)
+		}
+		flag.Changed = false
+	}
+
+	cmd.Flags().VisitAll(reset)
+	cmd.Root().PersistentFlags().VisitAll(reset)
+
+	return err
+}
+
+// isComplete reports whether every bracket and quote in the line has been closed. Brackets count both unquoted and
+// within single quotes, where JSON is usually written, but not within JSON strings.
+func isComplete(line string) bool {
+	depth := 0
+	var quote rune
+	inString := false
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && (quote != '\'' || inString):
+			escaped = true
+		case inString:
+			inString = r != '"'
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			}
+		case r == '"' && quote == '\'':
+			inString = true
+		case r == '\'':
+			if quote == 0 {
+				quote = r
+			} else {
+				quote = 0
+			}
+		case r == '"':
+			quote = r
+		case r == '{' || r == '[':
+			depth++
+		case r == '}' || r == ']':
+			depth--
+		}
+	}
+
+	return quote == 0 && !inString && depth <= 0
+}
+
+// splitWords splits a command line into words like a POSIX shell would, honouring single and double quotes and
+// backslash escapes.
+func splitWords(line string) ([]string, error) {
+	words := make([]string, 0)
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else if r == '\\' {
+				escaped = true
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+			inWord = true
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+type lineReader interface {
+	readLine(prompt string) (string, error)
+	print(text string)
+}
+
+// terminalReader reads lines from a terminal with line editing, history and completion. The terminal is only in raw
+// mode while a line is being read, so command output is unaffected.
+type terminalReader struct {
+	fd       int
+	terminal *term.Terminal
+}
+
+func newTerminalReader(file *os.File, complete func(line string, pos int, key rune) (string, int, bool)) *terminalReader {
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{&interruptReader{file}, os.Stdout}
+
+	t := term.NewTerminal(rw, "")
+	t.AutoCompleteCallback = complete
+
+	return &terminalReader{fd: int(file.Fd()), terminal: t}
+}
+
+func (r *terminalReader) readLine(prompt string) (string, error) {
+	state, err := term.MakeRaw(r.fd)
+	if err != nil {
+		return "", fmt.Errorf("error configuring terminal, %s", err)
+	}
+	defer func() { _ = term.Restore(r.fd, state) }()
+
+	if width, height, err := term.GetSize(r.fd); err == nil {
+		_ = r.terminal.SetSize(width, height)
+	}
+
+	r.terminal.SetPrompt(prompt)
+	line, err := r.terminal.ReadLine()
+	if errors.Is(err, term.ErrPasteIndicator) {
+		err = nil
+	}
+	return line, err
+}
+
+func (r *terminalReader) print(text string) {
+	_, _ = r.terminal.Write([]byte(text))
+}
+
+// interruptReader remaps Ctrl-C, which the terminal would treat as end of input, to a key the completion callback
+// handles by clearing the line.
+type interruptReader struct {
+	io.Reader
+}
+
+func (r *interruptReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	for i := range p[:n] {
+		if p[i] == keyCtrlC {
+			p[i] = keyCancel
+		}
+	}
+	return n, err
+}
+
+// streamReader reads lines from a pipe or file, so scripts can be fed to the shell.
+type streamReader struct {
+	reader *bufio.Reader
+}
+
+func (r *streamReader) readLine(_ string) (string, error) {
+	line, err := r.reader.ReadString('\n')
+	if err == io.EOF && len(line) > 0 {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+func (r *streamReader) print(text string) {
+	fmt.Print(text)
+}
@@ -0,0 +1,352 @@
+package actions
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/cobra"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"jhight.com/firestore-cli/pkg/config"
+	"math/big"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	flagToConfig         = "to-config"
+	flagToProfile        = "to-profile"
+	flagToServiceAccount = "to-service-account"
+	flagToProjectID      = "to-project-id"
+	flagToDatabaseID     = "to-database"
+	flagToEmulator       = "to-emulator"
+	flagFields           = "fields"
+	flagRewriteIDs       = "rewrite-ids"
+	flagIDSalt           = "id-salt"
+)
+
+const (
+	rewriteHash   = "hash"
+	rewriteRandom = "random"
+)
+
+const (
+	rewrittenIDLength = 20
+	idAlphabet        = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+func Transfer(root Action) Action {
+	a := &action{
+		initializer: root.Initializer(),
+	}
+
+	a.command = &cobra.Command{
+		Use:   "transfer <source-path> [<destination-path>]",
+		Short: "Copy documents to another project or database",
+		Long:  "Copy a document, or the documents of a collection, from the configured project and database (the source) to another one (the destination), such as from production into staging or the emulator. The destination is set with the --to-* flags; anything not given is taken from the source, unless --to-config or --to-profile is used, in which case it starts from that configuration. Documents can be selected with a filter, trimmed to a set of fields, and given new IDs so the copy can't be traced back to the original. References to transferred documents follow them to their new paths.",
+		Example: strings.ReplaceAll(`- copy active users from the prod profile into the emulator
+	%E transfer users --profile prod --to-emulator localhost:8080 --filter '{"active":true}'
+
+- copy 100 orders from one database to another, under a different collection
+	%E transfer orders sample-orders --database orders-db --to-database test-db --limit 100
+
+- copy users into staging with only a few fields, under anonymized IDs
+	%E transfer users --to-profile staging --fields name,address.city --rewrite-ids hash --id-salt s3cret`, "%E", os.Args[0]),
+		Args:    cobra.RangeArgs(1, 2),
+		PreRunE: a.initializer.Initialize,
+		RunE:    a.runTransfer,
+	}
+
+	a.addHelpFlag()
+	a.command.Flags().String(flagToConfig, "", "The configuration file for the destination (defaults to the source configuration)")
+	a.command.Flags().String(flagToProfile, "", "The configuration profile for the destination")
+	a.command.Flags().String(flagToServiceAccount, "", "The service account JSON file for the destination")
+	a.command.Flags().String(flagToProjectID, "", "The destination Google Cloud Platform project ID")
+	a.command.Flags().String(flagToDatabaseID, "", "The destination Firestore database ID")
+	a.command.Flags().String(flagToEmulator, "", "The host:port of a Firestore emulator to use as the destination")
+	a.command.Flags().StringP(flagFilter, "f", "", "Only transfer documents matching this filter expression (see get --help for syntax)")
+	a.command.Flags().IntP(flagLimit, "l", 0, "Transfer at most this many documents")
+	a.command.Flags().String(flagFields, "", "Only transfer these comma-separated fields (nested fields use dots, e.g., address.city)")
+	a.command.Flags().String(flagMode, string(client.WriteModeSet), "Write semantics: create (fail if the document exists), set (replace), or merge (update the given fields, creating if needed)")
+	a.command.Flags().String(flagRewriteIDs, "", fmt.Sprintf("Give transferred documents new IDs: %s (the same ID always maps to the same new ID) or %s", rewriteHash, rewriteRandom))
+	a.command.Flags().String(flagIDSalt, "", fmt.Sprintf("A secret mixed into %s IDs, so they can't be reversed by hashing known IDs", rewriteHash))
+
+	return a
+}
+
+func (a *action) runTransfer(_ *cobra.Command, args []string) error {
+	a.handleHelpFlag()
+
+	t := &transfer{
+		source:      strings.Trim(args[0], "/"),
+		destination: strings.Trim(args[0], "/"),
+		rewrite:     a.command.Flag(flagRewriteIDs).Value.String(),
+		salt:        a.command.Flag(flagIDSalt).Value.String(),
+		random:      make(map[string]string),
+		fields:      splitFields(a.command.Flag(flagFields).Value.String()),
+	}
+	if len(args) > 1 {
+		t.destination = strings.Trim(args[1], "/")
+	}
+
+	if len(t.rewrite) > 0 && t.rewrite != rewriteHash && t.rewrite != rewriteRandom {
+		return fmt.Errorf("invalid ID rewrite %s; must be %s or %s", t.rewrite, rewriteHash, rewriteRandom)
+	}
+
+	mode := client.WriteMode(a.command.Flag(flagMode).Value.String())
+	if !slices.Contains([]client.WriteMode{client.WriteModeCreate, client.WriteModeSet, client.WriteModeMerge}, mode) {
+		return fmt.Errorf("invalid mode %s; must be one of create, set or merge", mode)
+	}
+
+	source := a.initializer.Firestore()
+	t.collection = source.IsPathToCollection(t.source)
+	if t.collection != source.IsPathToCollection(t.destination) {
+		return fmt.Errorf("invalid destination, %s; documents must be transferred to a document path, and collections to a collection path", t.destination)
+	}
+
+	input := query.Input{Path: t.source}
+	if filter := a.command.Flag(flagFilter).Value.String(); len(filter) > 0 {
+		if err := json.Unmarshal([]byte(filter), &input.Filter); err != nil {
+			return fmt.Errorf("query parse failure, %s; see help for more information on query syntax", err)
+		}
+	}
+	if a.command.Flag(flagLimit).Changed {
+		limit, err := strconv.Atoi(a.command.Flag(flagLimit).Value.String())
+		if err != nil {
+			return err
+		}
+		input.Limit = limit
+	}
+
+	cfg, err := a.destinationConfig()
+	if err != nil {
+		return err
+	}
+	if client.Backend(cfg) == client.Backend(a.initializer.Config()) && t.source == t.destination && len(t.rewrite) == 0 {
+		return fmt.Errorf("source and destination are the same; use the --to-* flags to choose another project or database")
+	}
+
+	destination, err := a.initializer.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = destination.Close() }()
+
+	written, failed := 0, 0
+	done := func(w client.Write, err error) {
+		if err != nil {
+			failed++
+			_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", w.Path, err)
+			return
+		}
+		written++
+	}
+
+	// read from the source in the background, handing each document to the destination's bulk writer as it asks
+	err = client.Stream(func(emit func(w client.Write) error) error {
+		err := source.Export(input, false, func(record map[string]any) error {
+			path, fields := t.document(record)
+			return emit(client.Write{Mode: mode, Path: path, Fields: fields, Raw: true})
+		})
+		if err != nil {
+			return fmt.Errorf("error reading %s, %s", t.source, err)
+		}
+		return nil
+	}, func(next func() (client.Write, bool)) error {
+		return destination.BulkWrite(next, done)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d documents transferred to %s, %d failed\n", written, client.Backend(cfg), failed)
+	return nil
+}
+
+// destinationConfig builds the destination config from the --to-* flags, starting from the source config unless
+// another config file or profile is given.
+func (a *action) destinationConfig() (config.Config, error) {
+	cfg := a.initializer.Config().Derived()
+	profile := a.command.Flag(flagToProfile).Value.String()
+
+	if path := a.command.Flag(flagToConfig).Value.String(); len(path) > 0 {
+		var err error
+		if cfg, err = readConfigFile(path); err != nil {
+			return cfg, err
+		}
+		if len(profile) == 0 {
+			profile = cfg.DefaultProfile
+		}
+	}
+
+	if len(profile) > 0 {
+		var err error
+		if cfg, err = cfg.WithProfile(profile); err != nil {
+			return cfg, fmt.Errorf("%s; destination profiles must be defined in the destination config file", err)
+		}
+	}
+
+	overrides := map[string]*string{
+		flagToServiceAccount: &cfg.ServiceAccount,
+		flagToProjectID:      &cfg.ProjectID,
+		flagToDatabaseID:     &cfg.DatabaseID,
+		flagToEmulator:       &cfg.EmulatorHost,
+	}
+	for flag, value := range overrides {
+		if v := a.command.Flag(flag).Value.String(); len(v) > 0 {
+			*value = v
+		}
+	}
+
+	return cfg, nil
+}
+
+type transfer struct {
+	source      string
+	destination string
+	collection  bool
+	fields      []string
+	rewrite     string
+	salt        string
+	random      map[string]string
+}
+
+// document turns an exported record into the destination path and fields to write. Values are encoded, so they are
+// decoded again by the destination (document references in particular must point into the destination database), and
+// written raw, so strings that look like functions are copied rather than evaluated.
+func (t *transfer) document(record map[string]any) (string, map[string]any) {
+	_, path, record := query.SplitRecord(record)
+
+	if len(t.fields) > 0 {
+		record = maskFields(record, t.fields)
+	}
+
+	target, _ := t.target(path)
+	fields, _ := t.references(codec.Encode(record)).(map[string]any)
+
+	return target, fields
+}
+
+// target maps a path in the transferred data to its path in the destination, giving document IDs new values when
+// rewriting. Paths outside the transferred data are not mapped.
+func (t *transfer) target(path string) (string, bool) {
+	destination := t.destination
+	if !t.collection && len(t.rewrite) > 0 {
+		// a single document keeps the parent of its destination path, but gets a new ID like any other
+		parent := destination[:strings.LastIndex(destination, "/")]
+		destination = parent + "/" + t.id(t.source[strings.LastIndex(t.source, "/")+1:])
+	}
+
+	if path == t.source {
+		return destination, true
+	}
+
+	rest, ok := strings.CutPrefix(path, t.source+"/")
+	if !ok {
+		return path, false
+	}
+
+	segments := strings.Split(rest, "/")
+	for i := range segments {
+		// below a collection the even segments are document IDs, and below a document the odd ones are
+		if (i%2 == 0) == t.collection {
+			segments[i] = t.id(segments[i])
+		}
+	}
+
+	return destination + "/" + strings.Join(segments, "/"), true
+}
+
+// references maps document references to transferred documents, so they follow the documents to their new paths.
+func (t *transfer) references(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		if ref, ok := v[codec.TagRef].(string); ok && codec.IsTagged(v) {
+			target, _ := t.target(ref)
+			return map[string]any{codec.TagRef: target}
+		}
+		for k, e := range v {
+			v[k] = t.references(e)
+		}
+		return v
+	case []any:
+		for i, e := range v {
+			v[i] = t.references(e)
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+func (t *transfer) id(id string) string {
+	switch t.rewrite {
+	case rewriteHash:
+		mac := hmac.New(sha256.New, []byte(t.salt))
+		mac.Write([]byte(id))
+		return hex.EncodeToString(mac.Sum(nil))[:rewrittenIDLength]
+	case rewriteRandom:
+		// the same ID gets the same new ID within a transfer, so references stay consistent
+		if rewritten, ok := t.random[id]; ok {
+			return rewritten
+		}
+		rewritten := randomID()
+		t.random[id] = rewritten
+		return rewritten
+	default:
+		return id
+	}
+}
+
+func randomID() string {
+	id := make([]byte, rewrittenIDLength)
+	for i := range id {
+		n, _ := rand.Int(rand.Reader, big.NewInt(int64(len(idAlphabet))))
+		id[i] = idAlphabet[n.Int64()]
+	}
+	return string(id)
+}
+
+// maskFields keeps only the given fields of a document, which may be nested (e.g., address.city).
+func maskFields(document map[string]any, fields []string) map[string]any {
+	masked := make(map[string]any)
+
+	for _, field := range fields {
+		keys := strings.Split(field, ".")
+
+		var value any = document
+		found := true
+		for _, key := range keys {
+			m, ok := value.(map[string]any)
+			if !ok {
+				found = false
+				break
+			}
+			if value, found = m[key]; !found {
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+
+		parent := masked
+		for _, key := range keys[:len(keys)-1] {
+			child, ok := parent[key].(map[string]any)
+			if !ok {
+				child = make(map[string]any)
+				parent[key] = child
+			}
+			parent = child
+		}
+		parent[keys[len(keys)-1]] = value
+	}
+
+	return masked
+}
@@ -0,0 +1,550 @@
+package actions
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"jhight.com/firestore-cli/pkg/api/client/diff"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	flagAtomic      = "atomic"
+	flagMaxAttempts = "max-attempts"
+)
+
+const (
+	stepGet   = "get"
+	stepCheck = "check"
+)
+
+// stepReferencePattern matches a reference to a value read by an earlier step, e.g. ${user.address.city}.
+var stepReferencePattern = regexp.MustCompile(`\$\{([^}]+)}`)
+
+// txStep is one step of a transaction script. Exactly one of get, check, create, set, update or delete is given.
+type txStep struct {
+	Get    string         `json:"get" yaml:"get"`
+	As     string         `json:"as" yaml:"as"`
+	Check  map[string]any `json:"check" yaml:"check"`
+	Create string         `json:"create" yaml:"create"`
+	Set    string         `json:"set" yaml:"set"`
+	Update string         `json:"update" yaml:"update"`
+	Delete string         `json:"delete" yaml:"delete"`
+	Data   map[string]any `json:"data" yaml:"data"`
+}
+
+type txScript struct {
+	Steps []txStep `json:"steps" yaml:"steps"`
+}
+
+func Tx(root Action) Action {
+	a := &action{
+		initializer: root.Initializer(),
+	}
+
+	a.command = &cobra.Command{
+		Use:   "tx [<script>]",
+		Short: "Run a script of reads and writes in a single transaction",
+		Long: `Run a JSON or YAML script of steps in a single transaction, read from a file or stdin. Either every write is made, or none are. Each step is one of:
+
+  get: <path>            read a document, named with as: <name> (or its path) for later steps
+  check: {<ref>: <cond>} stop unless a value read earlier matches, e.g. {"from.balance": {">=": 100}}
+  create: <path>         create a document with data: {...}, failing if it exists
+  set: <path>            create or replace a document with data: {...}
+  update: <path>         update fields of an existing document with data: {...}
+  delete: <path>         delete a document
+
+Paths and data can reference values read by earlier steps as ${<name>.<field>}, including ${<name>.$id} and ${<name>.$path}; a field of the document whose name starts with $ takes another $ in front, as in ${<name>.$$id}. A value that is a reference alone keeps its type; one embedded in text is formatted as text. Values read are written as they are, never called as functions, even if they look like $delete(). Conditions use the operators of get --filter (==, !=, <, <=, >, >=, $in, $not-in), or $exists: true|false; a bare value means ==.
+
+Reads must come before writes. If another write conflicts with the transaction, it's retried from the start, up to --max-attempts times. A script that only writes can be committed with --atomic as a single batch instead, with no reads or retries.`,
+		Example: strings.ReplaceAll(`- move 100 between accounts, unless the balance is too low
+	%E tx transfer.yaml
+
+	# transfer.yaml
+	steps:
+	  - get: accounts/alice
+	    as: from
+	  - check: {from.balance: {">=": 100}}
+	  - update: accounts/alice
+	    data: {balance: "$increment(-100)"}
+	  - update: accounts/bob
+	    data: {balance: "$increment(100)"}
+	  - create: transfers/alice-bob
+	    data: {from: "${from.$path}", amount: 100, at: "$serverTimestamp()"}
+
+- create two documents together, or neither
+	echo '[{"create":"users/1","data":{"name":"A"}},{"create":"users/2","data":{"name":"B"}}]' | %E tx --atomic`, "%E", os.Args[0]),
+		Args:    cobra.MaximumNArgs(1),
+		PreRunE: a.initializer.Initialize,
+		RunE:    a.runTx,
+	}
+
+	a.addHelpFlag()
+	a.command.Flags().Bool(flagAtomic, false, "Commit a script that only writes as a single atomic batch, without a transaction")
+	a.command.Flags().Int(flagMaxAttempts, client.DefaultMaxAttempts, "Try the transaction this many times when it conflicts with other writes")
+
+	return a
+}
+
+func (a *action) runTx(_ *cobra.Command, args []string) error {
+	a.handleHelpFlag()
+
+	var input []byte
+	var err error
+	if len(args) > 0 {
+		if input, err = os.ReadFile(args[0]); err != nil {
+			return fmt.Errorf("error reading script, %s", err)
+		}
+	} else if a.shouldReadFromStdin() {
+		var s string
+		if s, err = a.readFromStdin(); err != nil {
+			return err
+		}
+		input = []byte(s)
+	}
+	if len(bytes.TrimSpace(input)) == 0 {
+		return errors.New("a script is required, as a file or on stdin")
+	}
+
+	steps, err := parseTxScript(input)
+	if err != nil {
+		return err
+	}
+
+	store := a.initializer.Firestore()
+
+	if a.command.Flag(flagAtomic).Value.String() == "true" {
+		for i, step := range steps {
+			if op, _ := step.operation(); op == stepGet || op == stepCheck {
+				return fmt.Errorf("step %d: --%s scripts can only write, not %s", i+1, flagAtomic, op)
+			}
+		}
+
+		batch := &batchTx{}
+		if _, err = a.runTxSteps(batch, steps); err != nil {
+			return err
+		}
+		if err = store.Batch(batch.writes); err != nil {
+			return err
+		}
+		a.printWritten("Batch committed: %d writes\n", len(batch.writes))
+		return nil
+	}
+
+	maxAttempts, err := a.command.Flags().GetInt(flagMaxAttempts)
+	if err != nil {
+		return err
+	}
+	if maxAttempts < 1 {
+		return fmt.Errorf("--%s must be at least 1", flagMaxAttempts)
+	}
+
+	// the steps run again from the start on each attempt, so only the last attempt's reads are kept
+	attempts := 0
+	var reads map[string]any
+	err = store.Transact(maxAttempts, func(tx client.Tx) error {
+		attempts++
+		var err error
+		reads, err = a.runTxSteps(tx, steps)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("transaction failed, %s", err)
+	}
+
+	if len(reads) > 0 {
+		a.printOutput(reads)
+	}
+
+	writes := 0
+	for _, step := range steps {
+		if op, _ := step.operation(); op != stepGet && op != stepCheck {
+			writes++
+		}
+	}
+	if attempts > 1 {
+		a.printWritten("Transaction committed: %d writes, after %d attempts\n", writes, attempts)
+	} else {
+		a.printWritten("Transaction committed: %d writes\n", writes)
+	}
+	return nil
+}
+
+// parseTxScript reads a script as JSON or YAML, either as an object with a list of steps or the list of steps alone.
+func parseTxScript(input []byte) ([]txStep, error) {
+	var script txScript
+	var err error
+
+	input = bytes.TrimSpace(input)
+	switch {
+	case input[0] == '[':
+		err = codec.Unmarshal(input, &script.Steps)
+	case input[0] == '{':
+		err = codec.Unmarshal(input, &script)
+	default:
+		if err = yaml.Unmarshal(input, &script); err != nil {
+			err = yaml.Unmarshal(input, &script.Steps)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing script, %s", err)
+	}
+
+	if len(script.Steps) == 0 {
+		return nil, errors.New("the script has no steps")
+	}
+	for i, step := range script.Steps {
+		if _, err = step.operation(); err != nil {
+			return nil, fmt.Errorf("step %d: %s", i+1, err)
+		}
+	}
+
+	return script.Steps, nil
+}
+
+// operation returns which kind of step this is.
+func (s txStep) operation() (string, error) {
+	ops := make([]string, 0, 1)
+	for op, given := range map[string]bool{
+		stepGet:                        len(s.Get) > 0,
+		stepCheck:                      len(s.Check) > 0,
+		string(client.WriteModeCreate): len(s.Create) > 0,
+		string(client.WriteModeSet):    len(s.Set) > 0,
+		string(client.WriteModeUpdate): len(s.Update) > 0,
+		string(client.WriteModeDelete): len(s.Delete) > 0,
+	} {
+		if given {
+			ops = append(ops, op)
+		}
+	}
+
+	switch len(ops) {
+	case 0:
+		return "", errors.New("a step needs one of get, check, create, set, update or delete")
+	case 1:
+		return ops[0], nil
+	default:
+		slices.Sort(ops)
+		return "", fmt.Errorf("a step can only have one of get, check, create, set, update or delete, not %s", strings.Join(ops, " and "))
+	}
+}
+
+func (s txStep) path() string {
+	return cmp.Or(s.Get, s.Create, s.Set, s.Update, s.Delete)
+}
+
+// runTxSteps runs the steps of a script in a transaction, returning the documents read, by name.
+func (a *action) runTxSteps(tx client.Tx, steps []txStep) (map[string]any, error) {
+	reads := make(map[string]any)
+	writing := false
+
+	for i, step := range steps {
+		op, _ := step.operation()
+
+		path, err := resolveReferences(step.path(), reads, false)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %s", i+1, err)
+		}
+		p, _ := path.(string)
+
+		switch op {
+		case stepGet:
+			if writing {
+				return nil, fmt.Errorf("step %d: reads must come before writes in a transaction", i+1)
+			}
+			document, err := tx.Get(p)
+			if err != nil {
+				return nil, fmt.Errorf("step %d (get %s): %s", i+1, p, err)
+			}
+			// the read is kept as a record, so its $id and $path don't overwrite fields of the same name
+			var read map[string]any
+			if document != nil {
+				read = query.NewRecord(p[strings.LastIndex(p, "/")+1:], p, document)
+			}
+			reads[cmp.Or(step.As, p)] = read
+		case stepCheck:
+			if err = a.checkTxStep(step.Check, reads); err != nil {
+				return nil, fmt.Errorf("step %d: %s", i+1, err)
+			}
+		default:
+			writing = true
+			fields, err := resolveReferences(step.Data, reads, true)
+			if err != nil {
+				return nil, fmt.Errorf("step %d (%s %s): %s", i+1, op, p, err)
+			}
+			w := client.Write{Mode: client.WriteMode(op), Path: p}
+			if op != string(client.WriteModeDelete) {
+				w.Fields, _ = fields.(map[string]any)
+				if w.Fields == nil {
+					w.Fields = make(map[string]any)
+				}
+			}
+			if err = tx.Write(w); err != nil {
+				return nil, fmt.Errorf("step %d (%s %s): %s", i+1, op, p, err)
+			}
+		}
+	}
+
+	return reads, nil
+}
+
+// batchTx collects the writes of a script for an atomic batch, which can't read.
+type batchTx struct {
+	writes []client.Write
+}
+
+func (b *batchTx) Get(_ string) (map[string]any, error) {
+	return nil, fmt.Errorf("--%s scripts can only write", flagAtomic)
+}
+
+func (b *batchTx) Write(w client.Write) error {
+	b.writes = append(b.writes, w)
+	return nil
+}
+
+// resolveReferences replaces references to values read by earlier steps, at any depth of nested maps and arrays. When
+// escaping, substituted strings are escaped (see codec.Escape), so a value read as $delete() is written as that string
+// rather than calling the function; a function written in the script itself, such as $increment(${n.step}), still is.
+func resolveReferences(value any, reads map[string]any, escape bool) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		resolved := make(map[string]any, len(v))
+		for k, e := range v {
+			r, err := resolveReferences(e, reads, escape)
+			if err != nil {
+				return nil, err
+			}
+			resolved[k] = r
+		}
+		return resolved, nil
+	case []any:
+		resolved := make([]any, 0, len(v))
+		for _, e := range v {
+			r, err := resolveReferences(e, reads, escape)
+			if err != nil {
+				return nil, err
+			}
+			resolved = append(resolved, r)
+		}
+		return resolved, nil
+	case string:
+		// a reference on its own keeps the type of the value
+		if m := stepReferencePattern.FindStringSubmatch(v); m != nil && m[0] == v {
+			value, err := lookupReference(m[1], reads)
+			if err != nil || !escape {
+				return value, err
+			}
+			return escapeStrings(value), nil
+		}
+
+		var err error
+		resolved := stepReferencePattern.ReplaceAllStringFunc(v, func(s string) string {
+			value, e := lookupReference(stepReferencePattern.FindStringSubmatch(s)[1], reads)
+			if e != nil {
+				err = e
+				return s
+			}
+			switch value.(type) {
+			case string, int64, float64, bool:
+				return fmt.Sprint(value)
+			default:
+				err = fmt.Errorf("%s can't be embedded in text", s)
+				return s
+			}
+		})
+		if escape && codec.Escape(v) == v {
+			resolved = codec.Escape(resolved)
+		}
+		return resolved, err
+	default:
+		return value, nil
+	}
+}
+
+// escapeStrings escapes the strings of a value read by an earlier step, at any depth of nested maps and arrays.
+func escapeStrings(value any) any {
+	switch v := value.(type) {
+	case string:
+		return codec.Escape(v)
+	case map[string]any:
+		escaped := make(map[string]any, len(v))
+		for k, e := range v {
+			escaped[k] = escapeStrings(e)
+		}
+		return escaped
+	case []any:
+		escaped := make([]any, 0, len(v))
+		for _, e := range v {
+			escaped = append(escaped, escapeStrings(e))
+		}
+		return escaped
+	default:
+		return value
+	}
+}
+
+// lookupReference finds the value a reference such as user.address.city names: the document read as user, and its
+// nested field address.city. The read is a record (see query.NewRecord), so $id and $path name the document's ID and
+// path, and a field of its own whose name starts with $ is named with another $ in front.
+func lookupReference(reference string, reads map[string]any) (any, error) {
+	name, field, _ := strings.Cut(strings.TrimSpace(reference), ".")
+
+	read, ok := reads[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown reference %s; no earlier get is named %s", reference, name)
+	}
+	document, _ := read.(map[string]any)
+	if document == nil {
+		return nil, fmt.Errorf("can't resolve %s; the document read as %s doesn't exist", reference, name)
+	}
+	if len(field) == 0 {
+		_, _, data := query.SplitRecord(document)
+		return data, nil
+	}
+
+	var value any = document
+	for _, key := range strings.Split(field, ".") {
+		m, _ := value.(map[string]any)
+		if value, ok = m[key]; !ok {
+			return nil, fmt.Errorf("can't resolve %s; %s has no field %s", reference, name, field)
+		}
+	}
+	return value, nil
+}
+
+// checkTxStep evaluates the conditions of a check step, failing on the first that doesn't hold.
+func (a *action) checkTxStep(conditions map[string]any, reads map[string]any) error {
+	references := make([]string, 0, len(conditions))
+	for reference := range conditions {
+		references = append(references, reference)
+	}
+	slices.Sort(references)
+
+	for _, reference := range references {
+		condition, ok := conditions[reference].(map[string]any)
+		if !ok || codec.IsTagged(condition) {
+			condition = map[string]any{string(query.Equal): conditions[reference]}
+		}
+
+		for operator, expected := range condition {
+			if operator == "$exists" {
+				_, err := lookupReference(reference, reads)
+				if exists, _ := expected.(bool); exists != (err == nil) {
+					return fmt.Errorf("check failed: %s $exists is %t", reference, err == nil)
+				}
+				continue
+			}
+
+			actual, err := lookupReference(reference, reads)
+			if err != nil {
+				return fmt.Errorf("check failed: %s", err)
+			}
+			if expected, err = resolveReferences(expected, reads, true); err != nil {
+				return err
+			}
+			if expected, err = a.initializer.Firestore().Decode(expected); err != nil {
+				return fmt.Errorf("invalid check value for %s, %s", reference, err)
+			}
+
+			holds, err := checkCondition(query.FieldOperator(operator), actual, expected)
+			if err != nil {
+				return fmt.Errorf("invalid check for %s, %s", reference, err)
+			}
+			if !holds {
+				return fmt.Errorf("check failed: %s is %s, expected %s %s", reference, describeValue(actual), operator, describeValue(expected))
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkCondition reports whether a value compares to the expected one as the operator says.
+func checkCondition(operator query.FieldOperator, actual any, expected any) (bool, error) {
+	switch operator {
+	case query.Equal:
+		return checkEqual(actual, expected), nil
+	case query.NotEqual:
+		return !checkEqual(actual, expected), nil
+	case query.In, query.NotIn:
+		values, ok := expected.([]any)
+		if !ok {
+			return false, fmt.Errorf("%s needs an array", operator)
+		}
+		in := slices.ContainsFunc(values, func(v any) bool { return checkEqual(actual, v) })
+		return in == (operator == query.In), nil
+	case query.LessThan, query.LessThanOrEqual, query.GreaterThan, query.GreaterThanOrEqual:
+		c, ok := checkCompare(actual, expected)
+		if !ok {
+			return false, nil
+		}
+		switch operator {
+		case query.LessThan:
+			return c < 0, nil
+		case query.LessThanOrEqual:
+			return c <= 0, nil
+		case query.GreaterThan:
+			return c > 0, nil
+		default:
+			return c >= 0, nil
+		}
+	default:
+		return false, fmt.Errorf("unknown operator %s", operator)
+	}
+}
+
+func checkEqual(actual any, expected any) bool {
+	if c, ok := checkCompare(actual, expected); ok {
+		return c == 0
+	}
+	return diff.Equal(actual, expected)
+}
+
+// checkCompare orders two numbers, strings or times; values of other types, or of different types, can't be ordered.
+func checkCompare(actual any, expected any) (int, bool) {
+	if x, ok := checkNumber(actual); ok {
+		y, ok := checkNumber(expected)
+		return cmp.Compare(x, y), ok
+	}
+
+	switch x := actual.(type) {
+	case string:
+		y, ok := expected.(string)
+		return cmp.Compare(x, y), ok
+	case time.Time:
+		y, ok := expected.(time.Time)
+		return x.Compare(y), ok
+	default:
+		return 0, false
+	}
+}
+
+func checkNumber(value any) (float64, bool) {
+	switch n := value.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+func describeValue(value any) string {
+	if s, ok := value.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprint(codec.Encode(value))
+}
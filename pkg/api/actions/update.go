@@ -1,10 +1,12 @@
 package actions
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/spf13/cobra"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/backup"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
 	"jhight.com/firestore-cli/pkg/api/client/query"
 	"os"
 	"slices"
@@ -19,17 +21,21 @@ func Update(root Action) Action {
 	a.command = &cobra.Command{
 		Use:     "update <path> [<json>]",
 		Aliases: []string{"u"},
-		Short:   "Update specific properties in a document",
-		Long:    "Update the specified Firestore document with the specified JSON data. Other fields will remain unchanged. If the field does not exist, it will be created. If the specified document does not exist, a new one will not be created.",
+		Short:   "Update specific properties in a document, or in every document matching a filter",
+		Long:    "Update the specified Firestore document with the specified JSON data. Other fields will remain unchanged. If the field does not exist, it will be created. If the specified document does not exist, a new one will not be created. Given a collection path, the same fields are updated in every document of the collection matching --filter (or every document, with --all), after confirming how many documents match. Documents are written in batches, with progress shown as they go, and a document that fails to update is reported without stopping the others.",
 		Example: strings.ReplaceAll(`%E update users/1234 '{"name": "John Doe", "age": 30, "height": 5.9, "active": true}'
 %E update users/1234/orders/5678 '{"item": "shoes"}'
-cat file.json | %E update users 1234`, "%E", os.Args[0]),
+cat file.json | %E update users 1234
+%E update users --filter '{"address.city":"Chicago"}' '{"address.state":"Illinois","updated":"$now()"}'
+%E update '**/orders' --filter '{"status":"pending"}' '{"status":"cancelled"}' --yes
+%E update users --all '{"migrated":true}'`, "%E", os.Args[0]),
 		Args:    cobra.MinimumNArgs(1),
 		PreRunE: a.initializer.Initialize,
 		RunE:    a.runUpdate,
 	}
 
 	a.addHelpFlag()
+	a.addMatchFlags("Update")
 
 	return a
 }
@@ -54,11 +60,21 @@ func (a *action) runUpdate(_ *cobra.Command, args []string) error {
 	}
 
 	var fields map[string]any
-	err := json.Unmarshal([]byte(input), &fields)
+	err := codec.Unmarshal([]byte(input), &fields)
 	if err != nil {
 		return err
 	}
 
+	if a.isMatchPath(path) {
+		if err = a.requireMatchFilter(path, "Update"); err != nil {
+			return err
+		}
+		return a.updateMatches(path, fields)
+	}
+	if a.hasMatchFilter() {
+		return fmt.Errorf("a filter can only be applied to a collection")
+	}
+
 	// backup before update, if configured
 	if slices.Contains(a.initializer.Config().Backup.Commands, "update") {
 		before, _ := a.initializer.Firestore().Get(query.Input{Path: path})
@@ -73,6 +89,58 @@ func (a *action) runUpdate(_ *cobra.Command, args []string) error {
 		}
 	}
 
-	fmt.Printf("%s successfully updated\n", path)
+	a.printWritten("%s successfully updated\n", path)
+	return nil
+}
+
+// updateMatches applies the same fields to every document of a collection matching the filter.
+func (a *action) updateMatches(path string, fields map[string]any) error {
+	input, err := a.matchInput(path)
+	if err != nil {
+		return err
+	}
+
+	count, ok, err := a.confirmMatches(input, "Update")
+	if err != nil || !ok {
+		return err
+	}
+
+	// the state of each document before it's updated, kept until the update is done, when backing up
+	var sink backup.Sink
+	before := newBeforeStates()
+	if slices.Contains(a.initializer.Config().Backup.Commands, "update") && !a.dryRun() {
+		if sink, err = a.backupSink(); err != nil {
+			return err
+		}
+		defer func() { _ = sink.Close() }()
+	}
+
+	write := func(record map[string]any) client.Write {
+		_, documentPath, data := query.SplitRecord(record)
+		if sink != nil {
+			before.put(documentPath, data)
+		}
+		return client.Write{Mode: client.WriteModeUpdate, Path: documentPath, Fields: fields}
+	}
+
+	p := a.newProgress("updated", count)
+	done := func(w client.Write, err error) {
+		p.add(w.Path, err)
+		if sink != nil {
+			state := before.take(w.Path)
+			if err == nil {
+				after, _ := a.initializer.Firestore().Get(query.Input{Path: w.Path})
+				a.writeBackup(sink, w.Path, state, after)
+			}
+		}
+	}
+
+	err = a.writeMatches(input, false, write, done)
+	p.finish()
+	if err != nil {
+		return err
+	}
+
+	a.printWritten("%d documents updated, %d failed\n", p.done-p.failed, p.failed)
 	return nil
 }
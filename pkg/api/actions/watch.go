@@ -0,0 +1,96 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/cobra"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+func Watch(root Action) Action {
+	a := &action{
+		initializer: root.Initializer(),
+	}
+
+	a.command = &cobra.Command{
+		Use:   "watch <path> [<fields>]",
+		Short: "Watch a document or query for changes",
+		Long:  "Watch a Firestore document, or the documents of a collection matching a filter, and print every change as it happens. The current state is printed first, as added documents. Each event has the type of change (added, modified or removed), the document path, the time of the change, and the document before and after it. Use --format ndjson for one event per line. The listener reconnects after transient errors; press Ctrl-C to stop.",
+		Args:  cobra.RangeArgs(1, 2),
+		Example: strings.ReplaceAll(`- watch a single document
+	%E watch users/user-1234
+
+- watch the name and age of users over 30, one event per line
+	%E watch users name,age --filter '{"age":{">":30}}' --format ndjson
+
+- watch every orders subcollection, under all users
+	%E watch '**/orders'`, "%E", os.Args[0]),
+		PreRunE: a.initializer.Initialize,
+		RunE:    a.runWatch,
+	}
+
+	a.addHelpFlag()
+	a.command.Flags().StringP(flagFilter, "f", "", "Only watch documents matching this filter expression (see get --help for syntax).")
+	a.command.Flags().StringP(flagWhere, "w", "", fmt.Sprintf("Alias for filter by expression (--%s).", flagFilter))
+	a.command.Flags().Bool(flagGroup, false, fmt.Sprintf("Watch every collection with the given ID, wherever it is nested. Same as prefixing the path with %s.", query.CollectionGroupPrefix))
+
+	return a
+}
+
+func (a *action) runWatch(_ *cobra.Command, args []string) error {
+	a.handleHelpFlag()
+
+	input := query.Input{Path: strings.TrimSuffix(args[0], "/")}
+	if len(args) > 1 {
+		input.Fields = splitFields(args[1])
+	}
+
+	if strings.HasPrefix(input.Path, query.CollectionGroupPrefix) {
+		input.Path = strings.TrimPrefix(input.Path, query.CollectionGroupPrefix)
+		input.CollectionGroup = true
+	} else if a.command.Flag(flagGroup).Changed {
+		input.CollectionGroup = a.command.Flag(flagGroup).Value.String() == "true"
+	}
+
+	filterString := ""
+	if a.command.Flag(flagFilter).Changed {
+		filterString = a.command.Flag(flagFilter).Value.String()
+	} else if a.command.Flag(flagWhere).Changed {
+		filterString = a.command.Flag(flagWhere).Value.String()
+	}
+	if len(filterString) > 0 {
+		if err := json.Unmarshal([]byte(filterString), &input.Filter); err != nil {
+			return fmt.Errorf("query parse failure, %s; see help for more information on query syntax", err)
+		}
+	}
+
+	store := a.initializer.Firestore()
+	if !input.CollectionGroup && !store.IsPathToDocument(input.Path) && !store.IsPathToCollection(input.Path) {
+		return fmt.Errorf("invalid path, %s", input.Path)
+	}
+	if len(input.Filter) > 0 && !input.CollectionGroup && store.IsPathToDocument(input.Path) {
+		return fmt.Errorf("--%s can only be used when watching a collection", flagFilter)
+	}
+
+	// stop listening cleanly on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return store.Watch(ctx, input, func(change client.Change) error {
+		a.printOutput(map[string]any{
+			"type":   string(change.Type),
+			"path":   change.Path,
+			"time":   change.Time,
+			"before": codec.Nullable(change.Before),
+			"after":  codec.Nullable(change.After),
+		})
+		return nil
+	})
+}
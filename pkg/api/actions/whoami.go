@@ -0,0 +1,57 @@
+package actions
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"os"
+	"strings"
+)
+
+func WhoAmI(root Action) Action {
+	a := &action{
+		initializer: root.Initializer(),
+	}
+
+	a.command = &cobra.Command{
+		Use:   "whoami",
+		Short: "Show the resolved identity, project and credential source",
+		Long:  "Show the principal, project and credential source Firestore CLI resolved from flags, config file and environment. Useful for checking which identity a destructive command will run as.",
+		Example: strings.ReplaceAll(`%E whoami
+%E whoami --service-account ~/prod.json`, "%E", os.Args[0]),
+		Args:    cobra.NoArgs,
+		PreRunE: a.initializer.Initialize,
+		RunE:    a.runWhoAmI,
+	}
+
+	a.addHelpFlag()
+
+	return a
+}
+
+func (a *action) runWhoAmI(_ *cobra.Command, _ []string) error {
+	a.handleHelpFlag()
+
+	creds := a.initializer.Firestore().Credentials()
+
+	principal, err := creds.LookupPrincipal(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(principal) == 0 {
+		principal = "(unknown)"
+	}
+
+	whoami := map[string]any{
+		"principal": principal,
+		"project":   creds.ProjectID,
+		"database":  client.DatabaseID(a.initializer.Config()),
+		"source":    creds.Source,
+	}
+	if len(creds.Path) > 0 {
+		whoami["credentials"] = creds.Path
+	}
+
+	a.printOutput(whoami)
+	return nil
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	pb "cloud.google.com/go/firestore/apiv1/firestorepb"
+	"fmt"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+)
+
+const (
+	aliasCount = "count"
+	aliasSum   = "sum_%d"
+	aliasAvg   = "avg_%d"
+)
+
+// Aggregate runs a server-side aggregation query, so counting, summing or averaging costs a fraction of reading
+// every matching document. Results are keyed $count, $sum and $avg, with sums and averages keyed by field.
+func (f *firestoreClientManager) Aggregate(input query.Input) (map[string]any, error) {
+	q, err := f.query(input)
+	if err != nil {
+		return nil, err
+	}
+
+	aq := q.NewAggregationQuery()
+	if input.Count {
+		aq = aq.WithCount(aliasCount)
+	}
+	for i, field := range input.Sum {
+		aq = aq.WithSum(field, fmt.Sprintf(aliasSum, i))
+	}
+	for i, field := range input.Avg {
+		aq = aq.WithAvg(field, fmt.Sprintf(aliasAvg, i))
+	}
+
+	result, err := aq.Get(f.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error aggregating documents, %s", err)
+	}
+
+	aggregates := make(map[string]any)
+	if input.Count {
+		aggregates[query.AggregateCount] = aggregateValue(result[aliasCount])
+	}
+	if len(input.Sum) > 0 {
+		sums := make(map[string]any)
+		for i, field := range input.Sum {
+			sums[field] = aggregateValue(result[fmt.Sprintf(aliasSum, i)])
+		}
+		aggregates[query.AggregateSum] = sums
+	}
+	if len(input.Avg) > 0 {
+		avgs := make(map[string]any)
+		for i, field := range input.Avg {
+			avgs[field] = aggregateValue(result[fmt.Sprintf(aliasAvg, i)])
+		}
+		aggregates[query.AggregateAvg] = avgs
+	}
+
+	return aggregates, nil
+}
+
+func aggregateValue(value any) any {
+	v, ok := value.(*pb.Value)
+	if !ok {
+		return value
+	}
+
+	switch v.GetValueType().(type) {
+	case *pb.Value_IntegerValue:
+		return v.GetIntegerValue()
+	case *pb.Value_DoubleValue:
+		return v.GetDoubleValue()
+	default:
+		// e.g., the average of no documents
+		return nil
+	}
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"cloud.google.com/go/firestore"
+	"fmt"
+	"jhight.com/firestore-cli/pkg/config"
+	"os"
+)
+
+const EmulatorHostEnv = "FIRESTORE_EMULATOR_HOST"
+const defaultEmulatorProjectID = "demo-firestore-cli"
+
+// WithEmulatorEnv returns the config with its emulator host taken from the FIRESTORE_EMULATOR_HOST environment
+// variable, unless it sets one itself. Only the config of the command's own store is resolved this way; another store,
+// such as the destination of a transfer, uses the emulator only when its own config says so.
+func WithEmulatorEnv(cfg config.Config) config.Config {
+	if host := os.Getenv(EmulatorHostEnv); len(cfg.EmulatorHost) == 0 && len(host) > 0 {
+		cfg.EmulatorHost = host
+		cfg.EmulatorFromEnv = true
+	}
+	return cfg
+}
+
+// DatabaseID returns the configured database ID, or the (default) database if none is configured.
+func DatabaseID(cfg config.Config) string {
+	if len(cfg.DatabaseID) > 0 {
+		return cfg.DatabaseID
+	}
+	return firestore.DefaultDatabaseID
+}
+
+// Backend returns a human-readable description of the Firestore backend the config points to.
+func Backend(cfg config.Config) string {
+	backend := fmt.Sprintf("project %s", cfg.ProjectID)
+	if len(cfg.EmulatorHost) > 0 {
+		backend = fmt.Sprintf("emulator %s", cfg.EmulatorHost)
+	}
+
+	if DatabaseID(cfg) != firestore.DefaultDatabaseID {
+		backend = fmt.Sprintf("%s, database %s", backend, DatabaseID(cfg))
+	}
+
+	return backend
+}
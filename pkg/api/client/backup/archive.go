@@ -0,0 +1,244 @@
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	archiveName      = "backup"
+	archiveExtension = ".ndjson.gz"
+)
+
+type archiveSink struct {
+	directory string
+	maxSize   int64
+	maxFiles  int
+	decode    func(value any) (any, error)
+}
+
+// NewArchiveSink keeps backups as lines of a gzip-compressed NDJSON file in a local directory. Once the file reaches
+// maxSize bytes it's set aside under the time it was rotated and a new one is started; only the newest maxFiles
+// rotated files are kept (all of them if maxFiles is 0). Values are encoded (see codec), and decode reads them back as
+// Firestore values.
+func NewArchiveSink(directory string, maxSize int64, maxFiles int, decode func(value any) (any, error)) Sink {
+	return &archiveSink{directory: directory, maxSize: maxSize, maxFiles: maxFiles, decode: decode}
+}
+
+func (s *archiveSink) Write(r Record) error {
+	if err := os.MkdirAll(s.directory, 0700); err != nil {
+		return fmt.Errorf("error creating backup directory, %s", err)
+	}
+	if err := s.rotate(); err != nil {
+		return fmt.Errorf("error rotating backup archive, %s", err)
+	}
+
+	fields := r.fields()
+	fields["id"] = r.ID
+	line, err := json.Marshal(codec.Encode(fields))
+	if err != nil {
+		return err
+	}
+
+	// each record is appended as a gzip member of its own; readers treat consecutive members as one stream
+	file, err := os.OpenFile(s.current(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(file)
+	if _, err = gz.Write(append(line, '\n')); err == nil {
+		err = gz.Close()
+	}
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// rotate sets the current archive aside once it has reached the maximum size, and removes the oldest rotated archives
+// beyond the maximum count.
+func (s *archiveSink) rotate() error {
+	stat, err := os.Stat(s.current())
+	if errors.Is(err, os.ErrNotExist) || (err == nil && stat.Size() < s.maxSize) || s.maxSize <= 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// named by the time it's rotated, which isn't a record ID, so the IDs of the records still to come aren't moved on
+	stamp := time.Now().UnixMilli()
+	rotated := s.rotatedPath(stamp)
+	for {
+		if _, err = os.Lstat(rotated); err != nil {
+			break
+		}
+		// rotated twice in a millisecond
+		stamp++
+		rotated = s.rotatedPath(stamp)
+	}
+	if err = os.Rename(s.current(), rotated); err != nil {
+		return err
+	}
+
+	if s.maxFiles <= 0 {
+		return nil
+	}
+	files, err := s.rotated()
+	if err != nil {
+		return err
+	}
+	for len(files) > s.maxFiles {
+		if err = os.Remove(files[len(files)-1]); err != nil {
+			return err
+		}
+		files = files[:len(files)-1]
+	}
+
+	return nil
+}
+
+func (s *archiveSink) List(filter Filter, visit func(record Record) error) error {
+	files, err := s.rotated()
+	if err != nil {
+		return err
+	}
+
+	listed := 0
+	for _, file := range append([]string{s.current()}, files...) {
+		records, err := s.read(file)
+		if err != nil {
+			return err
+		}
+
+		for i := len(records) - 1; i >= 0; i-- {
+			r := records[i]
+			if !filter.Since.IsZero() && r.CreatedAt.Before(filter.Since) {
+				return nil
+			}
+			if !filter.matches(r) {
+				continue
+			}
+			if err = visit(r); err != nil {
+				return err
+			}
+			if listed++; filter.Limit > 0 && listed == filter.Limit {
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *archiveSink) Get(id string) (Record, error) {
+	var found *Record
+	err := s.List(Filter{}, func(r Record) error {
+		if r.ID != id {
+			return nil
+		}
+		found = &r
+		return errStop
+	})
+	if err != nil && !errors.Is(err, errStop) {
+		return Record{}, err
+	}
+	if found == nil {
+		return Record{}, ErrNotFound
+	}
+	return *found, nil
+}
+
+func (s *archiveSink) Close() error {
+	return nil
+}
+
+func (s *archiveSink) current() string {
+	return filepath.Join(s.directory, archiveName+archiveExtension)
+}
+
+func (s *archiveSink) rotatedPath(stamp int64) string {
+	return filepath.Join(s.directory, fmt.Sprintf("%s-%d%s", archiveName, stamp, archiveExtension))
+}
+
+// rotated returns the paths of the rotated archives, newest first.
+func (s *archiveSink) rotated() ([]string, error) {
+	entries, err := os.ReadDir(s.directory)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading backup directory, %s", err)
+	}
+
+	ids := make([]string, 0)
+	for _, entry := range entries {
+		id, ok := strings.CutPrefix(strings.TrimSuffix(entry.Name(), archiveExtension), archiveName+"-")
+		if ok && strings.HasSuffix(entry.Name(), archiveExtension) && entry.Type().IsRegular() {
+			ids = append(ids, id)
+		}
+	}
+
+	slices.SortFunc(ids, compareIDs)
+	slices.Reverse(ids)
+
+	files := make([]string, 0, len(ids))
+	for _, id := range ids {
+		files = append(files, filepath.Join(s.directory, fmt.Sprintf("%s-%s%s", archiveName, id, archiveExtension)))
+	}
+	return files, nil
+}
+
+// read returns the records of an archive file, oldest first.
+func (s *archiveSink) read(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	gz, err := gzip.NewReader(file)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s, %s", path, err)
+	}
+
+	records := make([]Record, 0)
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var encoded map[string]any
+		if err = codec.Unmarshal(scanner.Bytes(), &encoded); err != nil {
+			return nil, fmt.Errorf("error reading %s, %s", path, err)
+		}
+		id, _ := encoded["id"].(string)
+		delete(encoded, "id")
+
+		r, err := decodeRecord(id, encoded, s.decode)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s, %s", path, err)
+	}
+
+	return records, nil
+}
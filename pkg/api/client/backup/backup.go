@@ -0,0 +1,132 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	SinkFirestore = "firestore"
+	SinkProject   = "project"
+	SinkDirectory = "directory"
+	SinkArchive   = "archive"
+)
+
+var Sinks = []string{SinkFirestore, SinkProject, SinkDirectory, SinkArchive}
+
+var ErrNotFound = errors.New("backup not found")
+
+// errStop ends a listing early, once enough records have been visited.
+var errStop = errors.New("stop")
+
+// Record is a document's state before and after a change. A nil Before means the change created the document, and a
+// nil After means it deleted it; a change with neither state has nothing to restore, so it isn't recorded.
+type Record struct {
+	ID        string
+	CreatedAt time.Time
+	Path      string
+	Project   string
+	Database  string
+	Before    map[string]any
+	After     map[string]any
+}
+
+// Change names the kind of change the record holds: create, update or delete.
+func (r Record) Change() string {
+	switch {
+	case r.Before == nil:
+		return "create"
+	case r.After == nil:
+		return "delete"
+	default:
+		return "update"
+	}
+}
+
+// Filter selects records by document path and time. Zero values select everything, and Limit caps the number of
+// records listed.
+type Filter struct {
+	Path  string
+	Since time.Time
+	Until time.Time
+	Limit int
+}
+
+func (f Filter) matches(r Record) bool {
+	if len(f.Path) > 0 && r.Path != f.Path {
+		return false
+	}
+	if !f.Since.IsZero() && r.CreatedAt.Before(f.Since) {
+		return false
+	}
+	return f.Until.IsZero() || r.CreatedAt.Before(f.Until)
+}
+
+// Sink is where backups are kept.
+type Sink interface {
+	Write(record Record) error
+	// List visits the records matching the filter, newest first. Returning an error from visit stops the listing.
+	List(filter Filter, visit func(record Record) error) error
+	// Get returns the record with the given ID, or ErrNotFound.
+	Get(id string) (Record, error)
+	Close() error
+}
+
+var (
+	idMutex sync.Mutex
+	lastID  int64
+)
+
+// NewID returns a record ID for the given time: its Unix time in milliseconds, so IDs sort by time. IDs are unique
+// within the process; a record taken in the same millisecond as the last one gets the next millisecond.
+func NewID(t time.Time) string {
+	idMutex.Lock()
+	defer idMutex.Unlock()
+
+	id := t.UnixMilli()
+	if id <= lastID {
+		id = lastID + 1
+	}
+	lastID = id
+
+	return strconv.FormatInt(id, 10)
+}
+
+// fields turns a record into the fields it's stored with. A missing state is kept as null, which codec would otherwise
+// encode as an empty document.
+func (r Record) fields() map[string]any {
+	return map[string]any{
+		"created_at": r.CreatedAt,
+		"path":       r.Path,
+		"project":    r.Project,
+		"database":   r.Database,
+		"before":     codec.Nullable(r.Before),
+		"after":      codec.Nullable(r.After),
+	}
+}
+
+// record reads a record from the fields it's stored with.
+func record(id string, fields map[string]any) Record {
+	r := Record{ID: id}
+	r.CreatedAt, _ = fields["created_at"].(time.Time)
+	r.Path, _ = fields["path"].(string)
+	r.Project, _ = fields["project"].(string)
+	r.Database, _ = fields["database"].(string)
+	r.Before, _ = fields["before"].(map[string]any)
+	r.After, _ = fields["after"].(map[string]any)
+	return r
+}
+
+// decodeRecord reads a record from its encoded form (see codec), as kept outside the database it was taken from.
+func decodeRecord(id string, encoded map[string]any, decode func(value any) (any, error)) (Record, error) {
+	decoded, err := decode(encoded)
+	if err != nil {
+		return Record{}, fmt.Errorf("invalid backup %s, %s", id, err)
+	}
+	fields, _ := decoded.(map[string]any)
+	return record(id, fields), nil
+}
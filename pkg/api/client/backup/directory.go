@@ -0,0 +1,137 @@
+package backup
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const fileExtension = ".json"
+
+type directorySink struct {
+	directory string
+	decode    func(value any) (any, error)
+}
+
+// NewDirectorySink keeps each backup as a JSON file, named by its ID (the time it was taken), in a local directory.
+// Values are encoded (see codec), and decode reads them back as Firestore values.
+func NewDirectorySink(directory string, decode func(value any) (any, error)) Sink {
+	return &directorySink{directory: directory, decode: decode}
+}
+
+func (s *directorySink) Write(r Record) error {
+	if err := os.MkdirAll(s.directory, 0700); err != nil {
+		return fmt.Errorf("error creating backup directory, %s", err)
+	}
+
+	data, err := json.MarshalIndent(codec.Encode(r.fields()), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// O_EXCL, so an existing backup is never overwritten
+	file, err := os.OpenFile(s.file(r.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(append(data, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (s *directorySink) List(filter Filter, visit func(record Record) error) error {
+	ids, err := s.ids()
+	if err != nil {
+		return err
+	}
+
+	listed := 0
+	for _, id := range ids {
+		r, err := s.Get(id)
+		if err != nil {
+			return err
+		}
+		if !filter.Since.IsZero() && r.CreatedAt.Before(filter.Since) {
+			break
+		}
+		if !filter.matches(r) {
+			continue
+		}
+		if err = visit(r); err != nil {
+			return err
+		}
+		if listed++; filter.Limit > 0 && listed == filter.Limit {
+			break
+		}
+	}
+
+	return nil
+}
+
+// ids returns the IDs of the backups in the directory, newest first.
+func (s *directorySink) ids() ([]string, error) {
+	entries, err := os.ReadDir(s.directory)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading backup directory, %s", err)
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), fileExtension)
+		if _, err = strconv.ParseInt(id, 10, 64); ok && err == nil && entry.Type().IsRegular() {
+			ids = append(ids, id)
+		}
+	}
+
+	slices.SortFunc(ids, compareIDs)
+	slices.Reverse(ids)
+	return ids, nil
+}
+
+func (s *directorySink) Get(id string) (Record, error) {
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return Record{}, ErrNotFound
+	}
+
+	data, err := os.ReadFile(s.file(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return Record{}, ErrNotFound
+	}
+	if err != nil {
+		return Record{}, err
+	}
+
+	var encoded map[string]any
+	if err = codec.Unmarshal(data, &encoded); err != nil {
+		return Record{}, fmt.Errorf("invalid backup %s, %s", id, err)
+	}
+
+	return decodeRecord(id, encoded, s.decode)
+}
+
+func (s *directorySink) Close() error {
+	return nil
+}
+
+func (s *directorySink) file(id string) string {
+	return filepath.Join(s.directory, id+fileExtension)
+}
+
+// compareIDs orders IDs by the times they stand for.
+func compareIDs(a, b string) int {
+	x, _ := strconv.ParseInt(a, 10, 64)
+	y, _ := strconv.ParseInt(b, 10, 64)
+	return cmp.Compare(x, y)
+}
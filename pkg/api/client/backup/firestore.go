@@ -0,0 +1,127 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"slices"
+)
+
+// documentIDField orders a query by document ID
+const documentIDField = "__name__"
+
+type firestoreSink struct {
+	store      client.Store
+	collection string
+	decode     func(value any) (any, error)
+	owned      bool
+}
+
+// NewFirestoreSink keeps backups as documents of a collection in the database being changed.
+func NewFirestoreSink(store client.Store, collection string) Sink {
+	return &firestoreSink{store: store, collection: collection}
+}
+
+// NewProjectSink keeps backups as documents of a collection in another project or database. Document states are
+// written as Firestore values of the backup database, so a reference points at the same path there; decode reads them
+// back as values of the database being changed, where the reference points again. The sink closes the store when
+// it's closed.
+func NewProjectSink(store client.Store, collection string, decode func(value any) (any, error)) Sink {
+	return &firestoreSink{store: store, collection: collection, decode: decode, owned: true}
+}
+
+func (s *firestoreSink) Write(r Record) error {
+	// written raw, so document states are kept exactly, even strings that look like functions
+	fields, _ := codec.Encode(r.fields()).(map[string]any)
+	return s.store.Batch([]client.Write{{Mode: client.WriteModeCreate, Path: fmt.Sprintf("%s/%s", s.collection, r.ID), Fields: fields, Raw: true}})
+}
+
+func (s *firestoreSink) List(filter Filter, visit func(record Record) error) error {
+	// IDs are creation times, so ordering by ID orders by time; filtering by path and ordering by ID descending would
+	// need a composite index, so the records of a path are read oldest first and visited newest first afterward
+	input := query.Input{Path: s.collection}
+	if len(filter.Path) > 0 {
+		input.Filter = map[string]any{"path": filter.Path}
+	} else {
+		input.OrderBy = []query.OrderBy{{Field: documentIDField, Direction: query.Descending}}
+	}
+
+	records := make([]Record, 0)
+	err := s.store.Export(input, false, func(document map[string]any) error {
+		r, err := s.record(document)
+		if err != nil {
+			return err
+		}
+
+		if len(filter.Path) > 0 {
+			if filter.matches(r) {
+				records = append(records, r)
+			}
+			return nil
+		}
+
+		if !filter.Since.IsZero() && r.CreatedAt.Before(filter.Since) {
+			return errStop
+		}
+		if !filter.matches(r) {
+			return nil
+		}
+		if err = visit(r); err != nil {
+			return err
+		}
+		if records = append(records, r); filter.Limit > 0 && len(records) == filter.Limit {
+			return errStop
+		}
+		return nil
+	})
+	if errors.Is(err, errStop) {
+		return nil
+	}
+	if err != nil || len(filter.Path) == 0 {
+		return err
+	}
+
+	slices.Reverse(records)
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[:filter.Limit]
+	}
+	for _, r := range records {
+		if err = visit(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *firestoreSink) Get(id string) (Record, error) {
+	document, err := s.store.Get(query.Input{Path: fmt.Sprintf("%s/%s", s.collection, id)})
+	if client.IsNotFound(err) {
+		return Record{}, ErrNotFound
+	}
+	if err != nil {
+		return Record{}, err
+	}
+
+	if s.decode != nil {
+		return decodeRecord(id, codec.Encode(document).(map[string]any), s.decode)
+	}
+	return record(id, document), nil
+}
+
+func (s *firestoreSink) record(document map[string]any) (Record, error) {
+	id, _ := document[query.SelectionDocumentID].(string)
+	if s.decode != nil {
+		return decodeRecord(id, codec.Encode(document).(map[string]any), s.decode)
+	}
+	return record(id, document), nil
+}
+
+func (s *firestoreSink) Close() error {
+	if s.owned {
+		return s.store.Close()
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package client
+
+import (
+	"cloud.google.com/go/firestore"
+	"errors"
+	"fmt"
+)
+
+const bulkBatchSize = 500
+
+type WriteMode string
+
+const (
+	WriteModeCreate WriteMode = "create"
+	WriteModeSet    WriteMode = "set"
+	WriteModeMerge  WriteMode = "merge"
+	WriteModeUpdate WriteMode = "update"
+	WriteModeDelete WriteMode = "delete"
+)
+
+var WriteModes = []WriteMode{WriteModeCreate, WriteModeSet, WriteModeMerge, WriteModeUpdate, WriteModeDelete}
+
+type Write struct {
+	Mode   WriteMode
+	Path   string
+	Fields map[string]any
+	// Raw writes fields encoded with codec.Encode, such as a document as it was read, exactly: they are decoded, but
+	// $function(...) strings are kept as strings rather than evaluated.
+	Raw bool
+}
+
+type bulkJob struct {
+	write Write
+	job   *firestore.BulkWriterJob
+	err   error
+}
+
+// BulkWrite pulls writes from next until it returns false and sends them through a firestore.BulkWriter, calling done
+// once per write with its outcome, in the order the writes were pulled. A failed write doesn't stop the others. Writes
+// are flushed in batches, so memory use is bounded no matter how many writes there are.
+func (f *firestoreClientManager) BulkWrite(next func() (Write, bool), done func(w Write, err error)) error {
+	return f.bulkWrite(next, done, f.enqueue)
+}
+
+// Stream runs read in the background, handing each write it emits to write as write asks for the next one, so
+// documents are read while earlier ones are written and memory use stays bounded. If write returns before taking
+// every write, emit returns an error to stop read, which read should return. The error from write comes first, then
+// the one from read.
+func Stream(read func(emit func(w Write) error) error, write func(next func() (Write, bool)) error) error {
+	writes := make(chan Write)
+	stop := make(chan struct{})
+	readErr := make(chan error, 1)
+	stopped := false
+	go func() {
+		defer close(writes)
+		readErr <- read(func(w Write) error {
+			select {
+			case writes <- w:
+				return nil
+			case <-stop:
+				stopped = true
+				return errStreamStopped
+			}
+		})
+	}()
+
+	next := func() (Write, bool) {
+		w, ok := <-writes
+		return w, ok
+	}
+
+	err := write(next)
+
+	// the reader may still be waiting to hand over a write if write returned early
+	close(stop)
+	for range writes {
+	}
+	rerr := <-readErr
+
+	if err != nil {
+		return err
+	}
+	if stopped {
+		return nil
+	}
+	return rerr
+}
+
+var errStreamStopped = errors.New("writing stopped")
+
+type enqueueFunc func(bw *firestore.BulkWriter, w Write) (*firestore.BulkWriterJob, error)
+
+func (f *firestoreClientManager) bulkWrite(next func() (Write, bool), done func(w Write, err error), enqueue enqueueFunc) error {
+	for {
+		bw := f.client.BulkWriter(f.ctx)
+
+		jobs := make([]bulkJob, 0, bulkBatchSize)
+		more := true
+		for len(jobs) < bulkBatchSize {
+			var w Write
+			if w, more = next(); !more {
+				break
+			}
+
+			job, err := enqueue(bw, w)
+			jobs = append(jobs, bulkJob{write: w, job: job, err: err})
+		}
+
+		bw.End()
+
+		for _, j := range jobs {
+			err := j.err
+			if err == nil {
+				_, err = j.job.Results()
+			}
+			done(j.write, err)
+		}
+
+		if !more {
+			return f.ctx.Err()
+		}
+	}
+}
+
+func (f *firestoreClientManager) enqueue(bw *firestore.BulkWriter, w Write) (*firestore.BulkWriterJob, error) {
+	c, err := f.writeCall(w)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.mode {
+	case WriteModeCreate:
+		return bw.Create(c.ref, c.data)
+	case WriteModeSet:
+		return bw.Set(c.ref, c.data, c.options...)
+	case WriteModeUpdate:
+		return bw.Update(c.ref, c.updates)
+	default:
+		return bw.Delete(c.ref)
+	}
+}
+
+// writeCall is a Write turned into the arguments of the Firestore call that makes it: Create, Set, Update or Delete.
+// A merge is made by Set, with firestore.MergeAll.
+type writeCall struct {
+	mode    WriteMode
+	ref     *firestore.DocumentRef
+	data    map[string]any
+	options []firestore.SetOption
+	updates []firestore.Update
+}
+
+// writeCall prepares a write for any of the ways writes are made: a bulk writer, a transaction or a batch.
+func (f *firestoreClientManager) writeCall(w Write) (writeCall, error) {
+	dr := f.client.Doc(w.Path)
+	if dr == nil {
+		return writeCall{}, fmt.Errorf("invalid document path, %s", w.Path)
+	}
+
+	if w.Mode == WriteModeDelete {
+		return writeCall{mode: WriteModeDelete, ref: dr}, nil
+	}
+
+	fields, err := f.writeFields(w)
+	if err != nil {
+		return writeCall{}, err
+	}
+
+	switch w.Mode {
+	case WriteModeCreate:
+		return writeCall{mode: WriteModeCreate, ref: dr, data: withoutDeletes(fields)}, nil
+	case WriteModeSet:
+		return writeCall{mode: WriteModeSet, ref: dr, data: withoutDeletes(fields)}, nil
+	case WriteModeMerge:
+		return writeCall{mode: WriteModeSet, ref: dr, data: mergeFields(fields), options: []firestore.SetOption{firestore.MergeAll}}, nil
+	case WriteModeUpdate:
+		if len(fields) == 0 {
+			return writeCall{}, fmt.Errorf("no fields to update")
+		}
+		return writeCall{mode: WriteModeUpdate, ref: dr, updates: updates(fields)}, nil
+	default:
+		return writeCall{}, fmt.Errorf("unknown write mode %s", w.Mode)
+	}
+}
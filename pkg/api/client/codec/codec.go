@@ -0,0 +1,323 @@
+package codec
+
+import (
+	"bytes"
+	"cloud.google.com/go/firestore"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tags used to represent Firestore types that have no native JSON form.
+const (
+	TagTimestamp string = "$timestamp"
+	TagRef       string = "$ref"
+	TagGeoPoint  string = "$geopoint"
+	TagBytes     string = "$bytes"
+	TagDouble    string = "$double"
+)
+
+// functionLike matches strings shaped like an input function, such as $delete() or $increment(1), with any number of
+// $ in front.
+var functionLike = regexp.MustCompile(`^\$+[A-Za-z]+\(.*\)$`)
+
+// tagLike matches the keys of maps shaped like a tagged value, such as $ref, with any number of $ in front.
+var tagLike = regexp.MustCompile(`^\$+(timestamp|ref|geopoint|bytes|double)$`)
+
+// Double is a float64 that always marshals as a JSON floating point literal (e.g., 5.0 rather than 5), so it is read
+// back as a double rather than an integer. NaN and infinities, which JSON can't represent, are tagged.
+type Double float64
+
+func (d Double) MarshalJSON() ([]byte, error) {
+	f := float64(d)
+	switch {
+	case math.IsNaN(f):
+		return json.Marshal(map[string]any{TagDouble: "NaN"})
+	case math.IsInf(f, 1):
+		return json.Marshal(map[string]any{TagDouble: "Infinity"})
+	case math.IsInf(f, -1):
+		return json.Marshal(map[string]any{TagDouble: "-Infinity"})
+	}
+
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+	return []byte(s), nil
+}
+
+// Encode converts Firestore values into JSON-friendly values, tagging the types JSON can't express natively so that
+// Decode can restore them. Strings that read like input functions are escaped (see Escape), and so are maps that read
+// like tagged values, such as {"$ref":"users/1"}, whose key gets another $ in front.
+func Encode(value any) any {
+	switch v := value.(type) {
+	case string:
+		return Escape(v)
+	case map[string]any:
+		encoded := make(map[string]any, len(v))
+		for k, e := range v {
+			if len(v) == 1 && tagLike.MatchString(k) {
+				k = "$" + k
+			}
+			encoded[k] = Encode(e)
+		}
+		return encoded
+	case []map[string]any:
+		encoded := make([]any, 0, len(v))
+		for _, e := range v {
+			encoded = append(encoded, Encode(e))
+		}
+		return encoded
+	case []any:
+		encoded := make([]any, 0, len(v))
+		for _, e := range v {
+			encoded = append(encoded, Encode(e))
+		}
+		return encoded
+	case time.Time:
+		return map[string]any{TagTimestamp: v.Format(time.RFC3339Nano)}
+	case *firestore.DocumentRef:
+		if v == nil {
+			return nil
+		}
+		return map[string]any{TagRef: Path(v)}
+	case *latlng.LatLng:
+		if v == nil {
+			return nil
+		}
+		return map[string]any{TagGeoPoint: []any{Double(v.Latitude), Double(v.Longitude)}}
+	case []byte:
+		return map[string]any{TagBytes: base64.StdEncoding.EncodeToString(v)}
+	case float64:
+		return Double(v)
+	default:
+		return value
+	}
+}
+
+// Decode reverses Encode, turning tagged values back into Firestore types. Numbers decoded with json.Number (see
+// Unmarshal) become int64 when they are integer literals and float64 otherwise. The client is used to build document
+// references.
+func Decode(value any, client *firestore.Client) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		if tag, tagged, ok := taggedValue(v); ok {
+			return decodeTagged(tag, tagged, client)
+		}
+
+		decoded := make(map[string]any, len(v))
+		for k, e := range v {
+			d, err := Decode(e, client)
+			if err != nil {
+				return nil, err
+			}
+			if tag, ok := EscapedTag(v); ok {
+				k = tag
+			}
+			decoded[k] = d
+		}
+		return decoded, nil
+	case []any:
+		decoded := make([]any, 0, len(v))
+		for _, e := range v {
+			d, err := Decode(e, client)
+			if err != nil {
+				return nil, err
+			}
+			decoded = append(decoded, d)
+		}
+		return decoded, nil
+	case json.Number:
+		return decodeNumber(v)
+	case string:
+		s, _ := Unescape(v)
+		return s, nil
+	default:
+		return value, nil
+	}
+}
+
+// Escape marks a string that reads like an input function, such as $delete(), as a literal by putting another $ in
+// front of it, so that writing it back stores the string rather than calling the function. Any other string is
+// returned as is.
+func Escape(s string) string {
+	if functionLike.MatchString(s) {
+		return "$" + s
+	}
+	return s
+}
+
+// Unescape reverses Escape, reporting whether the string was escaped.
+func Unescape(s string) (string, bool) {
+	if strings.HasPrefix(s, "$$") && functionLike.MatchString(s) {
+		return s[1:], true
+	}
+	return s, false
+}
+
+// Unmarshal parses JSON like json.Unmarshal, but keeps numbers as json.Number so Decode can tell integers from doubles.
+func Unmarshal(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return fmt.Errorf("invalid character after top-level value")
+	}
+	return nil
+}
+
+// Path returns the document path relative to the database root, e.g. users/user-1234.
+func Path(dr *firestore.DocumentRef) string {
+	if _, path, ok := strings.Cut(dr.Path, "/documents/"); ok {
+		return path
+	}
+	return dr.Path
+}
+
+// Nullable keeps a missing document as null when it's encoded, rather than an empty object.
+func Nullable(document map[string]any) any {
+	if document == nil {
+		return nil
+	}
+	return document
+}
+
+// IsTagged reports whether the map is a single tagged value, such as {"$timestamp":"..."}.
+func IsTagged(m map[string]any) bool {
+	_, _, ok := taggedValue(m)
+	return ok
+}
+
+// EscapedTag reports whether the map is one Encode escaped because it read like a tagged value, such as
+// {"$$ref":"users/1"}, returning its key without the extra $.
+func EscapedTag(m map[string]any) (string, bool) {
+	if len(m) != 1 {
+		return "", false
+	}
+	for k := range m {
+		if strings.HasPrefix(k, "$$") && tagLike.MatchString(k) {
+			return k[1:], true
+		}
+	}
+	return "", false
+}
+
+func taggedValue(m map[string]any) (string, any, bool) {
+	if len(m) != 1 {
+		return "", nil, false
+	}
+
+	for k, v := range m {
+		switch k {
+		case TagTimestamp, TagRef, TagGeoPoint, TagBytes, TagDouble:
+			return k, v, true
+		}
+	}
+
+	return "", nil, false
+}
+
+func decodeTagged(tag string, value any, client *firestore.Client) (any, error) {
+	switch tag {
+	case TagTimestamp:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be an RFC 3339 string", tag)
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %s", tag, s)
+		}
+		return t, nil
+	case TagRef:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a document path", tag)
+		}
+		if _, relative, found := strings.Cut(s, "/documents/"); found {
+			s = relative
+		}
+		dr := client.Doc(s)
+		if dr == nil {
+			return nil, fmt.Errorf("invalid %s document path %s", tag, s)
+		}
+		return dr, nil
+	case TagGeoPoint:
+		pair, ok := value.([]any)
+		if !ok || len(pair) != 2 {
+			return nil, fmt.Errorf("%s must be an array of [latitude, longitude]", tag)
+		}
+		lat, latErr := toFloat(pair[0])
+		lng, lngErr := toFloat(pair[1])
+		if latErr != nil || lngErr != nil {
+			return nil, fmt.Errorf("%s must be an array of [latitude, longitude]", tag)
+		}
+		return &latlng.LatLng{Latitude: lat, Longitude: lng}, nil
+	case TagBytes:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a base64 string", tag)
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s, %s", tag, err)
+		}
+		return b, nil
+	case TagDouble:
+		if s, ok := value.(string); ok {
+			switch s {
+			case "NaN":
+				return math.NaN(), nil
+			case "Infinity":
+				return math.Inf(1), nil
+			case "-Infinity":
+				return math.Inf(-1), nil
+			}
+		}
+		f, err := toFloat(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number, NaN, Infinity or -Infinity", tag)
+		}
+		return f, nil
+	default:
+		return nil, fmt.Errorf("unknown tag %s", tag)
+	}
+}
+
+func decodeNumber(n json.Number) (any, error) {
+	s := n.String()
+	if !strings.ContainsAny(s, ".eE") {
+		if i, err := n.Int64(); err == nil {
+			return i, nil
+		}
+	}
+
+	f, err := n.Float64()
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %s", s)
+	}
+	return f, nil
+}
+
+func toFloat(value any) (float64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.Float64()
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	default:
+		return 0, fmt.Errorf("not a number")
+	}
+}
@@ -0,0 +1,154 @@
+package client
+
+import (
+	"cloud.google.com/go/firestore"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"strings"
+)
+
+const copyPreflightBatchSize = 100
+
+type ConflictPolicy string
+
+const (
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	ConflictSkip      ConflictPolicy = "skip"
+	ConflictFail      ConflictPolicy = "fail"
+)
+
+var ConflictPolicies = []ConflictPolicy{ConflictOverwrite, ConflictSkip, ConflictFail}
+
+// ErrExists is passed to the done callback of Copy for a document that was not written because it already exists.
+var ErrExists = errors.New("document already exists")
+
+// Copy copies a document, or the documents of a collection, to the destination path, descending into subcollections
+// when recursive is set. Documents are written exactly as read, so every Firestore type is kept. done is called once
+// per document with its destination write and outcome.
+//
+// With ConflictOverwrite, existing destination documents are replaced. With ConflictSkip, they are left alone and
+// reported to done with ErrExists. With ConflictFail, nothing is written if any destination document already exists,
+// which means reading the source twice.
+func (f *firestoreClientManager) Copy(source string, destination string, recursive bool, conflict ConflictPolicy, done func(w Write, err error)) error {
+	source = strings.Trim(source, "/")
+	destination = strings.Trim(destination, "/")
+
+	if source == destination || (recursive && strings.HasPrefix(destination, source+"/")) {
+		return fmt.Errorf("can't copy %s into itself", source)
+	}
+
+	walk, err := f.copyWalk(source, destination, recursive)
+	if err != nil {
+		return err
+	}
+
+	mode := WriteModeCreate
+	switch conflict {
+	case ConflictOverwrite:
+		mode = WriteModeSet
+	case ConflictSkip:
+	case ConflictFail:
+		if err = f.copyPreflight(walk); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown conflict policy %s", conflict)
+	}
+
+	copied := func(w Write, err error) {
+		if status.Code(err) == codes.AlreadyExists {
+			err = ErrExists
+		}
+		done(w, err)
+	}
+
+	// walk the source in the background, handing each document to the bulk writer as it asks for the next write
+	return Stream(func(emit func(w Write) error) error {
+		return walk(func(ds *firestore.DocumentSnapshot, path string) error {
+			return emit(Write{Mode: mode, Path: path, Fields: ds.Data()})
+		})
+	}, func(next func() (Write, bool)) error {
+		return f.bulkWrite(next, copied, f.enqueueCopy)
+	})
+}
+
+// copyWalk returns a function that walks the source, visiting each document with the path it is copied to.
+func (f *firestoreClientManager) copyWalk(source string, destination string, recursive bool) (func(visit func(ds *firestore.DocumentSnapshot, path string) error) error, error) {
+	target := func(ds *firestore.DocumentSnapshot) string {
+		return destination + strings.TrimPrefix(codec.Path(ds.Ref), source)
+	}
+
+	if cr := f.client.Collection(source); cr != nil {
+		if f.client.Collection(destination) == nil {
+			return nil, fmt.Errorf("invalid destination, %s; a collection must be copied to a collection path", destination)
+		}
+		return func(visit func(ds *firestore.DocumentSnapshot, path string) error) error {
+			return walkCollection(f.ctx, f.client, cr, recursive, func(ds *firestore.DocumentSnapshot) error {
+				return visit(ds, target(ds))
+			})
+		}, nil
+	}
+
+	if dr := f.client.Doc(source); dr != nil {
+		if f.client.Doc(destination) == nil {
+			return nil, fmt.Errorf("invalid destination, %s; a document must be copied to a document path", destination)
+		}
+		return func(visit func(ds *firestore.DocumentSnapshot, path string) error) error {
+			return walkDocumentRef(f.ctx, f.client, dr, recursive, func(ds *firestore.DocumentSnapshot) error {
+				return visit(ds, target(ds))
+			})
+		}, nil
+	}
+
+	return nil, fmt.Errorf("invalid path format, %s", source)
+}
+
+// copyPreflight fails if any document the walk would write already exists.
+func (f *firestoreClientManager) copyPreflight(walk func(visit func(ds *firestore.DocumentSnapshot, path string) error) error) error {
+	refs := make([]*firestore.DocumentRef, 0, copyPreflightBatchSize)
+
+	check := func() error {
+		if len(refs) == 0 {
+			return nil
+		}
+		snapshots, err := f.client.GetAll(f.ctx, refs)
+		if err != nil {
+			return fmt.Errorf("error checking destination documents, %s", err)
+		}
+		for _, ds := range snapshots {
+			if ds.Exists() {
+				return fmt.Errorf("%s already exists; nothing was copied", codec.Path(ds.Ref))
+			}
+		}
+		refs = refs[:0]
+		return nil
+	}
+
+	err := walk(func(_ *firestore.DocumentSnapshot, path string) error {
+		refs = append(refs, f.client.Doc(path))
+		if len(refs) < copyPreflightBatchSize {
+			return nil
+		}
+		return check()
+	})
+	if err != nil {
+		return err
+	}
+
+	return check()
+}
+
+func (f *firestoreClientManager) enqueueCopy(bw *firestore.BulkWriter, w Write) (*firestore.BulkWriterJob, error) {
+	dr := f.client.Doc(w.Path)
+	if dr == nil {
+		return nil, fmt.Errorf("invalid document path, %s", w.Path)
+	}
+
+	if w.Mode == WriteModeCreate {
+		return bw.Create(dr, w.Fields)
+	}
+	return bw.Set(dr, w.Fields)
+}
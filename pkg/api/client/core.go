@@ -5,27 +5,12 @@ import (
 	"context"
 	"fmt"
 	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
 	"strings"
 )
 
-func count(ctx context.Context, client *firestore.Client, collectionPath string) int {
-	cr := client.Collection(collectionPath)
-	if cr == nil {
-		return 0
-	}
-
-	iter := cr.Documents(ctx)
-	c := 0
-	for {
-		_, err := iter.Next()
-		if err != nil {
-			break
-		}
-		c++
-	}
-	return c
-}
-
 func create[T any](ctx context.Context, client *firestore.Client, documentPath string, data T) error {
 	dr := client.Doc(documentPath)
 	if dr == nil {
@@ -58,39 +43,21 @@ func update[T any](ctx context.Context, client *firestore.Client, documentPath s
 		return fmt.Errorf("invalid document path, %s", documentPath)
 	}
 
-	updates := make([]firestore.Update, 0)
-	for k, v := range fields {
-		updates = append(updates, firestore.Update{Path: k, Value: v})
-	}
-
-	if _, err := dr.Update(ctx, updates); err != nil {
+	if _, err := dr.Update(ctx, updates(fields)); err != nil {
 		return fmt.Errorf("error updating document, %s", err)
 	}
 
 	return nil
 }
 
-func remove(ctx context.Context, client *firestore.Client, path string) error {
-	cr := client.Collection(path)
-	dr := client.Doc(path)
-
-	if cr != nil {
-		err := removeCollection(ctx, client, cr)
-		if err != nil {
-			return err
-		}
-		return nil
-	}
-
-	if dr != nil {
-		err := removeDocument(ctx, client, dr)
-		if err != nil {
-			return err
-		}
-		return nil
+// updates turns fields into the updates of an Update. A field set to firestore.Delete is deleted, but a nested map or
+// array replaces the value of its field whole, so a delete inside one is left out.
+func updates[T any](fields map[string]T) []firestore.Update {
+	u := make([]firestore.Update, 0)
+	for k, v := range fields {
+		u = append(u, firestore.Update{Path: k, Value: dropDeletes(v, true)})
 	}
-
-	return fmt.Errorf("invalid path format, %s", path)
+	return u
 }
 
 func removeField(ctx context.Context, client *firestore.Client, documentPath string, fieldPath string) error {
@@ -107,62 +74,127 @@ func removeField(ctx context.Context, client *firestore.Client, documentPath str
 	return nil
 }
 
-func removeCollection(ctx context.Context, client *firestore.Client, cr *firestore.CollectionRef) error {
-	if cr == nil {
-		return fmt.Errorf("invalid collection reference, %s", cr.Path)
+// walkBatchSize is how many listed documents are read at once while walking a collection.
+const walkBatchSize = 100
+
+// walkCollection visits each document in the collection one at a time, descending into subcollections after each
+// document when recursive is set, so memory use is bounded by the depth of the tree rather than its size. Descending
+// lists the documents by reference, which takes in missing documents that only have subcollections, so nothing under
+// them is skipped.
+func walkCollection(ctx context.Context, client *firestore.Client, cr *firestore.CollectionRef, recursive bool, visit func(ds *firestore.DocumentSnapshot) error) error {
+	if !recursive {
+		return walkQuery(ctx, client, cr.Query, false, visit)
+	}
+
+	iter := cr.DocumentRefs(ctx)
+	refs := make([]*firestore.DocumentRef, 0, walkBatchSize)
+	for {
+		dr, err := iter.Next()
+		if err != nil && err != iterator.Done {
+			return fmt.Errorf("error listing documents of %s, %s", cr.Path, err)
+		}
+		if dr != nil {
+			refs = append(refs, dr)
+		}
+
+		if len(refs) == walkBatchSize || (err == iterator.Done && len(refs) > 0) {
+			if err := walkRefs(ctx, client, refs, visit); err != nil {
+				return err
+			}
+			refs = refs[:0]
+		}
+		if err == iterator.Done {
+			return nil
+		}
+	}
+}
+
+// walkRefs visits the documents that exist among refs, descending into the subcollections of each whether it exists
+// or not.
+func walkRefs(ctx context.Context, client *firestore.Client, refs []*firestore.DocumentRef, visit func(ds *firestore.DocumentSnapshot) error) error {
+	snapshots, err := client.GetAll(ctx, refs)
+	if err != nil {
+		return fmt.Errorf("error reading documents, %s", err)
 	}
 
-	removed := 0
+	for _, ds := range snapshots {
+		if ds.Exists() {
+			if err = visit(ds); err != nil {
+				return err
+			}
+		}
+		if err = walkSubcollections(ctx, client, ds.Ref, visit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// walkQuery is walkCollection for the documents matching a query. Missing documents never match a query, so only the
+// subcollections of documents that exist are walked.
+func walkQuery(ctx context.Context, client *firestore.Client, q firestore.Query, recursive bool, visit func(ds *firestore.DocumentSnapshot) error) error {
+	iter := q.Documents(ctx)
+	defer iter.Stop()
 
-	iter := cr.Documents(ctx)
 	for {
 		ds, err := iter.Next()
 		if err == iterator.Done {
 			break
 		}
 		if err != nil {
+			return fmt.Errorf("error reading documents, %s", err)
+		}
+
+		if err = visit(ds); err != nil {
 			return err
 		}
 
-		if err = removeDocument(ctx, client, ds.Ref); err != nil {
-			return fmt.Errorf("error deleting document, %s", err)
+		if recursive {
+			if err = walkSubcollections(ctx, client, ds.Ref, visit); err != nil {
+				return err
+			}
 		}
+	}
 
-		removed++
+	return nil
+}
+
+func walkDocumentRef(ctx context.Context, client *firestore.Client, dr *firestore.DocumentRef, recursive bool, visit func(ds *firestore.DocumentSnapshot) error) error {
+	ds, err := dr.Get(ctx)
+	if err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("error reading document, %s", err)
+	}
+
+	// a missing document may still have subcollections
+	if ds.Exists() {
+		if err = visit(ds); err != nil {
+			return err
+		}
 	}
 
-	if removed == 0 {
-		return fmt.Errorf("invalid collection reference, %s", cr.Path)
+	if recursive {
+		return walkSubcollections(ctx, client, dr, visit)
 	}
 
 	return nil
 }
 
-func removeDocument(ctx context.Context, client *firestore.Client, dr *firestore.DocumentRef) error {
-	if dr == nil {
-		return fmt.Errorf("invalid document reference, %s", dr.Path)
-	}
-
+func walkSubcollections(ctx context.Context, client *firestore.Client, dr *firestore.DocumentRef, visit func(ds *firestore.DocumentSnapshot) error) error {
 	iter := dr.Collections(ctx)
 	for {
 		cr, err := iter.Next()
 		if err == iterator.Done {
-			break
+			return nil
 		}
 		if err != nil {
-			return err
+			return fmt.Errorf("error listing subcollections of %s, %s", codec.Path(dr), err)
 		}
 
-		if err = removeCollection(ctx, client, cr); err != nil {
-			return fmt.Errorf("error deleting collection, %s", err)
+		if err = walkCollection(ctx, client, cr, true, visit); err != nil {
+			return err
 		}
 	}
-
-	if _, err := dr.Delete(ctx); err != nil {
-		return fmt.Errorf("error deleting document, %s", err)
-	}
-
-	return nil
 }
 
 func collections(ctx context.Context, client *firestore.Client, documentPath string) []any {
@@ -0,0 +1,175 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/option"
+	"jhight.com/firestore-cli/pkg/config"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const AccessTokenEnv = "FIRESTORE_CLI_ACCESS_TOKEN"
+const applicationCredentialsEnv = "GOOGLE_APPLICATION_CREDENTIALS"
+const tokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+
+var scopes = []string{
+	"https://www.googleapis.com/auth/cloud-platform",
+	"https://www.googleapis.com/auth/datastore",
+}
+
+type CredentialSource string
+
+const (
+	CredentialSourceEmulator           CredentialSource = "emulator"
+	CredentialSourceServiceAccount     CredentialSource = "service-account"
+	CredentialSourceEnvironment        CredentialSource = applicationCredentialsEnv
+	CredentialSourceApplicationDefault CredentialSource = "application-default"
+	CredentialSourceAccessToken        CredentialSource = "access-token"
+)
+
+// Credentials describe the identity the Firestore client was resolved to, and where it came from.
+type Credentials struct {
+	Source    CredentialSource
+	Path      string
+	Principal string
+	ProjectID string
+
+	tokenSource oauth2.TokenSource
+	options     []option.ClientOption
+}
+
+// ResolveCredentials walks the credential chain: an explicit service account file, then
+// GOOGLE_APPLICATION_CREDENTIALS, then Application Default Credentials, and finally a raw access token read from
+// FIRESTORE_CLI_ACCESS_TOKEN or the configured access token file. The emulator short-circuits the chain entirely.
+func ResolveCredentials(ctx context.Context, cfg config.Config) (Credentials, error) {
+	if len(cfg.EmulatorHost) > 0 {
+		return Credentials{Source: CredentialSourceEmulator, Path: cfg.EmulatorHost, Principal: "(unauthenticated)"}, nil
+	}
+
+	if len(cfg.ServiceAccount) > 0 {
+		return credentialsFromFile(ctx, CredentialSourceServiceAccount, cfg.ServiceAccount)
+	}
+
+	if path := os.Getenv(applicationCredentialsEnv); len(path) > 0 {
+		return credentialsFromFile(ctx, CredentialSourceEnvironment, path)
+	}
+
+	if creds, err := google.FindDefaultCredentials(ctx, scopes...); err == nil {
+		return fromGoogleCredentials(CredentialSourceApplicationDefault, "", creds), nil
+	}
+
+	token, path, err := accessToken(cfg)
+	if err != nil {
+		return Credentials{}, err
+	}
+	if len(token) > 0 {
+		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+		return Credentials{
+			Source:      CredentialSourceAccessToken,
+			Path:        path,
+			tokenSource: ts,
+			options:     []option.ClientOption{option.WithTokenSource(ts)},
+		}, nil
+	}
+
+	return Credentials{}, fmt.Errorf("no credentials found; provide a service account file, set %s, log in with gcloud auth application-default login, or set %s", applicationCredentialsEnv, AccessTokenEnv)
+}
+
+// LookupPrincipal returns the principal, asking Google's token info endpoint when the credentials don't name one.
+func (c Credentials) LookupPrincipal(ctx context.Context) (string, error) {
+	if len(c.Principal) > 0 || c.tokenSource == nil {
+		return c.Principal, nil
+	}
+
+	token, err := c.tokenSource.Token()
+	if err != nil {
+		return "", fmt.Errorf("error fetching access token, %s", err)
+	}
+
+	// the token goes in the body rather than the URL, which proxies and servers are apt to log
+	form := url.Values{"access_token": {token.AccessToken}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenInfoURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error looking up token info, %s", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error looking up token info, %s", resp.Status)
+	}
+
+	var info struct {
+		Email string `json:"email"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", fmt.Errorf("error decoding token info, %s", err)
+	}
+
+	return info.Email, nil
+}
+
+func credentialsFromFile(ctx context.Context, source CredentialSource, path string) (Credentials, error) {
+	path = expandHome(path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("error reading credentials file, %s", err)
+	}
+
+	creds, err := google.CredentialsFromJSON(ctx, data, scopes...)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("error parsing credentials file %s, %s", path, err)
+	}
+
+	return fromGoogleCredentials(source, path, creds), nil
+}
+
+func fromGoogleCredentials(source CredentialSource, path string, creds *google.Credentials) Credentials {
+	var file struct {
+		ClientEmail string `json:"client_email"`
+	}
+	_ = json.Unmarshal(creds.JSON, &file)
+
+	return Credentials{
+		Source:      source,
+		Path:        path,
+		Principal:   file.ClientEmail,
+		ProjectID:   creds.ProjectID,
+		tokenSource: creds.TokenSource,
+		options:     []option.ClientOption{option.WithCredentials(creds)},
+	}
+}
+
+func accessToken(cfg config.Config) (string, string, error) {
+	if token := strings.TrimSpace(os.Getenv(AccessTokenEnv)); len(token) > 0 {
+		return token, "", nil
+	}
+
+	if len(cfg.AccessTokenFile) == 0 {
+		return "", "", nil
+	}
+
+	path := expandHome(cfg.AccessTokenFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("error reading access token file, %s", err)
+	}
+
+	return strings.TrimSpace(string(data)), path, nil
+}
+
+func expandHome(path string) string {
+	return strings.ReplaceAll(path, "~", os.Getenv("HOME"))
+}
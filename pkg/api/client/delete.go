@@ -0,0 +1,217 @@
+package client
+
+import (
+	"cloud.google.com/go/firestore"
+	"errors"
+	"fmt"
+	"google.golang.org/api/iterator"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"strings"
+	"sync"
+)
+
+// deleteConcurrency is how many top-level documents have their subcollections listed at once.
+const deleteConcurrency = 8
+
+var errDeleteStopped = errors.New("delete stopped")
+
+// deleteRoot is a top-level document of a delete, numbered in the order it was listed.
+type deleteRoot struct {
+	seq int
+	ref *firestore.DocumentRef
+}
+
+// deleteItem is a write for the bulk writer or, with no write, a marker that every write under a root has been sent.
+type deleteItem struct {
+	write Write
+	seq   int
+	id    string
+}
+
+// deleteProgress tracks the writes under a root, so the checkpoint only moves past it once they've all been made.
+type deleteProgress struct {
+	id      string
+	walked  bool
+	sent    int
+	written int
+	failed  bool
+}
+
+// Delete deletes a collection or document along with everything under it, calling done once per document with the
+// outcome. Several workers list documents at once, each descending through the subcollections of one top-level
+// document, while the bulk writer deletes what they've found in batches. An empty collection deletes nothing.
+//
+// A collection is deleted in document ID order, starting after the document ID resume when it's set. Documents that
+// don't exist but have subcollections are descended into like any other. checkpoint is
+// called with the ID of each top-level document that's gone, along with everything under it and before it, so an
+// interrupted delete can resume after it. A failed write stops the checkpoint from moving on.
+func (f *firestoreClientManager) Delete(path string, resume string, done func(w Write, err error), checkpoint func(resume string)) error {
+	path = strings.Trim(path, "/")
+
+	cr := f.client.Collection(path)
+	dr := f.client.Doc(path)
+	if cr == nil && dr == nil {
+		return fmt.Errorf("invalid path format, %s", path)
+	}
+
+	roots := make(chan deleteRoot)
+	items := make(chan deleteItem, bulkBatchSize)
+	stop := make(chan struct{})
+
+	var mu sync.Mutex
+	var failure error
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failure == nil {
+			failure = err
+			close(stop)
+		}
+	}
+
+	send := func(item deleteItem) error {
+		select {
+		case <-stop:
+			return errDeleteStopped
+		case items <- item:
+			return nil
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	// list the top-level documents
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(roots)
+
+		if cr == nil {
+			roots <- deleteRoot{ref: dr}
+			return
+		}
+
+		// listing includes missing documents that only have subcollections, which a query would skip; documents are
+		// listed in ID order, so those up to the resume point are passed over
+		iter := cr.DocumentRefs(f.ctx)
+		for seq := 0; ; {
+			ref, err := iter.Next()
+			if err == iterator.Done {
+				return
+			}
+			if err != nil {
+				fail(fmt.Errorf("error reading documents, %s", err))
+				return
+			}
+			if len(resume) > 0 && ref.ID <= resume {
+				continue
+			}
+
+			select {
+			case <-stop:
+				return
+			case roots <- deleteRoot{seq: seq, ref: ref}:
+				seq++
+			}
+		}
+	}()
+
+	// descend through each top-level document, deleting what's under it before the document itself
+	for range deleteConcurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range roots {
+				err := walkSubcollections(f.ctx, f.client, r.ref, func(ds *firestore.DocumentSnapshot) error {
+					return send(deleteItem{write: Write{Mode: WriteModeDelete, Path: codec.Path(ds.Ref)}, seq: r.seq})
+				})
+				if err == nil {
+					err = send(deleteItem{write: Write{Mode: WriteModeDelete, Path: codec.Path(r.ref)}, seq: r.seq})
+				}
+				if err == nil {
+					err = send(deleteItem{seq: r.seq, id: r.ref.ID})
+				}
+				if err != nil && !errors.Is(err, errDeleteStopped) {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(items)
+	}()
+
+	// next and written both run on this goroutine, so the checkpoint needs no locking
+	progress := make(map[int]*deleteProgress)
+	pending := make(map[string]int)
+	low := 0
+	stalled := cr == nil || checkpoint == nil
+
+	advance := func() {
+		last := ""
+		for p := progress[low]; p != nil && p.walked && p.written == p.sent; p = progress[low] {
+			if p.failed {
+				stalled = true
+				clear(progress)
+				return
+			}
+			last = p.id
+			delete(progress, low)
+			low++
+		}
+		if len(last) > 0 {
+			checkpoint(last)
+		}
+	}
+
+	next := func() (Write, bool) {
+		for item := range items {
+			var p *deleteProgress
+			if !stalled {
+				if p = progress[item.seq]; p == nil {
+					p = &deleteProgress{}
+					progress[item.seq] = p
+				}
+			}
+
+			if len(item.write.Path) == 0 {
+				if p != nil {
+					p.id = item.id
+					p.walked = true
+					advance()
+				}
+				continue
+			}
+
+			pending[item.write.Path] = item.seq
+			if p != nil {
+				p.sent++
+			}
+			return item.write, true
+		}
+		return Write{}, false
+	}
+
+	written := func(w Write, err error) {
+		done(w, err)
+
+		seq := pending[w.Path]
+		delete(pending, w.Path)
+		if p := progress[seq]; p != nil && !stalled {
+			p.written++
+			p.failed = p.failed || err != nil
+			advance()
+		}
+	}
+
+	err := f.bulkWrite(next, written, f.enqueue)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if failure != nil {
+		return failure
+	}
+	return err
+}
@@ -0,0 +1,200 @@
+package diff
+
+import (
+	"bytes"
+	"cloud.google.com/go/firestore"
+	"fmt"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+type Op string
+
+const (
+	OpAdd     Op = "add"
+	OpRemove  Op = "remove"
+	OpReplace Op = "replace"
+)
+
+// Change is a single difference between two values. Path holds map keys (strings) and array indexes (ints) leading
+// from the root to the changed value. From is unset for additions and To is unset for removals.
+type Change struct {
+	Op   Op
+	Path []any
+	From any
+	To   any
+}
+
+// Compare returns the changes that turn a into b, ordered by path. Values are compared as Firestore types: an int64 and
+// a float64 with the same value differ, timestamps are compared as instants, and references by their paths. Array
+// elements are compared by position, and array removals are listed from the end, so the changes can be applied in
+// order.
+func Compare(a, b map[string]any) []Change {
+	changes := make([]Change, 0)
+	compare(nil, a, b, &changes)
+	return changes
+}
+
+func compare(path []any, a, b any, changes *[]Change) {
+	am, aIsMap := a.(map[string]any)
+	bm, bIsMap := b.(map[string]any)
+	if aIsMap && bIsMap {
+		keys := make([]string, 0, len(am)+len(bm))
+		for k := range am {
+			keys = append(keys, k)
+		}
+		for k := range bm {
+			if _, ok := am[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+
+		for _, k := range keys {
+			av, inA := am[k]
+			bv, inB := bm[k]
+			switch {
+			case !inB:
+				*changes = append(*changes, Change{Op: OpRemove, Path: child(path, k), From: av})
+			case !inA:
+				*changes = append(*changes, Change{Op: OpAdd, Path: child(path, k), To: bv})
+			default:
+				compare(child(path, k), av, bv, changes)
+			}
+		}
+		return
+	}
+
+	aa, aIsArray := a.([]any)
+	ba, bIsArray := b.([]any)
+	if aIsArray && bIsArray {
+		common := min(len(aa), len(ba))
+		for i := 0; i < common; i++ {
+			compare(child(path, i), aa[i], ba[i], changes)
+		}
+		for i := len(aa) - 1; i >= common; i-- {
+			*changes = append(*changes, Change{Op: OpRemove, Path: child(path, i), From: aa[i]})
+		}
+		for i := common; i < len(ba); i++ {
+			*changes = append(*changes, Change{Op: OpAdd, Path: child(path, i), To: ba[i]})
+		}
+		return
+	}
+
+	if !Equal(a, b) {
+		*changes = append(*changes, Change{Op: OpReplace, Path: path, From: a, To: b})
+	}
+}
+
+func child(path []any, element any) []any {
+	return append(slices.Clip(path), element)
+}
+
+// Equal reports whether two Firestore values are the same, including their types.
+func Equal(a, b any) bool {
+	switch x := a.(type) {
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		return ok && len(Compare(x, y)) == 0
+	case []any:
+		y, ok := b.([]any)
+		if !ok {
+			return false
+		}
+		changes := make([]Change, 0)
+		compare(nil, x, y, &changes)
+		return len(changes) == 0
+	case time.Time:
+		y, ok := b.(time.Time)
+		return ok && x.Equal(y)
+	case *firestore.DocumentRef:
+		y, ok := b.(*firestore.DocumentRef)
+		if !ok || x == nil || y == nil {
+			return ok && x == y
+		}
+		return codec.Path(x) == codec.Path(y)
+	case *latlng.LatLng:
+		y, ok := b.(*latlng.LatLng)
+		if !ok || x == nil || y == nil {
+			return ok && x == y
+		}
+		return x.Latitude == y.Latitude && x.Longitude == y.Longitude
+	case []byte:
+		y, ok := b.([]byte)
+		return ok && bytes.Equal(x, y)
+	case float64:
+		y, ok := b.(float64)
+		return ok && (x == y || (math.IsNaN(x) && math.IsNaN(y)))
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
+
+// FieldPath formats a change path the way fields are written elsewhere, e.g., address.city or tags[2]. Keys that
+// aren't plain identifiers are quoted with backticks, as in Firestore field paths.
+func FieldPath(path []any) string {
+	var sb strings.Builder
+	for _, element := range path {
+		switch e := element.(type) {
+		case int:
+			sb.WriteString(fmt.Sprintf("[%d]", e))
+		case string:
+			if sb.Len() > 0 {
+				sb.WriteString(".")
+			}
+			if isIdentifier(e) {
+				sb.WriteString(e)
+			} else {
+				sb.WriteString("`" + strings.ReplaceAll(e, "`", "\\`") + "`")
+			}
+		}
+	}
+	return sb.String()
+}
+
+func isIdentifier(key string) bool {
+	if len(key) == 0 {
+		return false
+	}
+	for i, r := range key {
+		if r == '_' || r == '$' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// Patch converts changes to JSON Patch (RFC 6902) operations. Values are left as they are, to be encoded by codec
+// when the operations are written out.
+func Patch(changes []Change) []map[string]any {
+	operations := make([]map[string]any, 0, len(changes))
+	for _, c := range changes {
+		operation := map[string]any{"op": string(c.Op), "path": Pointer(c.Path)}
+		if c.Op != OpRemove {
+			operation["value"] = c.To
+		}
+		operations = append(operations, operation)
+	}
+	return operations
+}
+
+// Pointer formats a change path as a JSON Pointer (RFC 6901).
+func Pointer(path []any) string {
+	var sb strings.Builder
+	for _, element := range path {
+		sb.WriteString("/")
+		switch e := element.(type) {
+		case int:
+			sb.WriteString(fmt.Sprintf("%d", e))
+		case string:
+			sb.WriteString(strings.ReplaceAll(strings.ReplaceAll(e, "~", "~0"), "/", "~1"))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,166 @@
+package client
+
+import (
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"strings"
+)
+
+type dryRunStore struct {
+	Store
+	report func(p Plan)
+}
+
+// DryRun wraps a store so writes are planned rather than made: each write reads the current state of its target and
+// hands report what it would do, and nothing is written. Reads pass through. A write that would fail returns the error
+// it would fail with.
+func DryRun(store Store, report func(p Plan)) Store {
+	return &dryRunStore{Store: store, report: report}
+}
+
+func (s *dryRunStore) plan(w Write) error {
+	p, err := s.Preview(w)
+	if err != nil {
+		return err
+	}
+	s.report(p)
+	return nil
+}
+
+func (s *dryRunStore) Create(path string, fields map[string]any) error {
+	return s.plan(Write{Mode: WriteModeCreate, Path: path, Fields: fields})
+}
+
+func (s *dryRunStore) Set(path string, fields map[string]any) error {
+	return s.plan(Write{Mode: WriteModeSet, Path: path, Fields: fields})
+}
+
+func (s *dryRunStore) Update(path string, fields map[string]any) error {
+	return s.plan(Write{Mode: WriteModeUpdate, Path: path, Fields: fields})
+}
+
+func (s *dryRunStore) DeleteField(path string, field string) error {
+	return s.plan(Write{Mode: WriteModeUpdate, Path: path, Fields: map[string]any{field: query.FunctionDelete + "()"}})
+}
+
+// Delete reports every document that would be removed, including those in subcollections, along with each collection
+// that would be emptied. Only a document deleted by path is reported with its fields. Nothing is deleted, so there's
+// no checkpoint to resume from.
+func (s *dryRunStore) Delete(path string, _ string, done func(w Write, err error), _ func(resume string)) error {
+	path = strings.Trim(path, "/")
+	collections := make(map[string]bool)
+
+	return s.Export(query.Input{Path: path}, true, func(record map[string]any) error {
+		_, documentPath, data := query.SplitRecord(record)
+
+		// the collection a deleted document is in goes too, unless the document was deleted on its own
+		if collection := documentPath[:strings.LastIndex(documentPath, "/")]; documentPath != path && !collections[collection] {
+			collections[collection] = true
+			s.report(Plan{Write: Write{Mode: WriteModeDelete, Path: collection}})
+		}
+
+		p := Plan{Write: Write{Mode: WriteModeDelete, Path: documentPath}}
+		if documentPath == path {
+			p.Before = data
+		}
+		s.report(p)
+		done(p.Write, nil)
+		return nil
+	})
+}
+
+func (s *dryRunStore) BulkWrite(next func() (Write, bool), done func(w Write, err error)) error {
+	for {
+		w, ok := next()
+		if !ok {
+			return nil
+		}
+		done(w, s.plan(w))
+	}
+}
+
+type dryRunTx struct {
+	s *dryRunStore
+}
+
+// Transact runs fn once, reading documents as they are now and reporting each write rather than making it. Writes are
+// planned against the documents as they are now, not as earlier writes in fn would leave them.
+func (s *dryRunStore) Transact(_ int, fn func(tx Tx) error) error {
+	return fn(&dryRunTx{s: s})
+}
+
+func (t *dryRunTx) Get(path string) (map[string]any, error) {
+	p, err := t.s.Preview(Write{Mode: WriteModeDelete, Path: path})
+	return p.Before, err
+}
+
+func (t *dryRunTx) Write(w Write) error {
+	return t.s.plan(w)
+}
+
+// Batch reports each write in the batch.
+func (s *dryRunStore) Batch(writes []Write) error {
+	for _, w := range writes {
+		if err := s.plan(w); err != nil {
+			return fmt.Errorf("%s: %s", w.Path, err)
+		}
+	}
+	return nil
+}
+
+// Copy reports the writes a copy would make, following the conflict policy.
+func (s *dryRunStore) Copy(source string, destination string, recursive bool, conflict ConflictPolicy, done func(w Write, err error)) error {
+	source = strings.Trim(source, "/")
+	destination = strings.Trim(destination, "/")
+
+	if source == destination || (recursive && strings.HasPrefix(destination, source+"/")) {
+		return fmt.Errorf("can't copy %s into itself", source)
+	}
+	if s.IsPathToCollection(source) != s.IsPathToCollection(destination) {
+		return fmt.Errorf("invalid destination, %s; a collection must be copied to a collection path, and a document to a document path", destination)
+	}
+
+	mode := WriteModeCreate
+	if conflict == ConflictOverwrite {
+		mode = WriteModeSet
+	}
+
+	plans := make([]Plan, 0)
+	writes := make([]Write, 0)
+	errs := make([]error, 0)
+
+	err := s.Export(query.Input{Path: source}, recursive, func(record map[string]any) error {
+		_, path, data := query.SplitRecord(record)
+
+		// documents are copied as they are, so the write is raw and nothing that looks like a function is evaluated
+		fields, _ := codec.Encode(data).(map[string]any)
+		w := Write{Mode: mode, Path: destination + strings.TrimPrefix(path, source), Fields: fields, Raw: true}
+		p, err := s.Preview(w)
+		if status.Code(err) == codes.AlreadyExists {
+			if conflict == ConflictFail {
+				return fmt.Errorf("%s already exists; nothing was copied", w.Path)
+			}
+			err = ErrExists
+		}
+
+		plans = append(plans, p)
+		writes = append(writes, w)
+		errs = append(errs, err)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, w := range writes {
+		if errs[i] == nil {
+			s.report(plans[i])
+		}
+		done(w, errs[i])
+	}
+
+	return nil
+}
@@ -0,0 +1,11 @@
+package client
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// IsNotFound reports whether err means the document doesn't exist.
+func IsNotFound(err error) bool {
+	return status.Code(err) == codes.NotFound
+}
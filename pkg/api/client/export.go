@@ -0,0 +1,53 @@
+package client
+
+import (
+	"cloud.google.com/go/firestore"
+	"fmt"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+)
+
+// Export visits every document at the input path, a collection or a single document, as a record with $id and $path
+// (see query.NewRecord), descending into subcollections when recursive is set, including those of missing documents.
+// For a collection, the input filter, order and limit select which documents are exported (subcollections of selected
+// documents are exported in full).
+func (f *firestoreClientManager) Export(input query.Input, recursive bool, visit func(document map[string]any) error) error {
+	record := func(ds *firestore.DocumentSnapshot) error {
+		return visit(exportRecord(ds))
+	}
+
+	if cr := f.client.Collection(input.Path); cr != nil {
+		if recursive && selectsAll(input) {
+			return walkCollection(f.ctx, f.client, cr, true, record)
+		}
+
+		q, err := f.query(input)
+		if err != nil {
+			return err
+		}
+		return walkQuery(f.ctx, f.client, q, recursive, record)
+	}
+
+	if dr := f.client.Doc(input.Path); dr != nil {
+		if len(input.Filter) > 0 {
+			return fmt.Errorf("a filter can only be applied to a collection")
+		}
+		return walkDocumentRef(f.ctx, f.client, dr, recursive, record)
+	}
+
+	return fmt.Errorf("invalid path format, %s", input.Path)
+}
+
+// exportRecord turns a snapshot into a record of its data plus $id and $path, with $path relative to the database root
+// so the record can be imported into another project or database.
+func exportRecord(ds *firestore.DocumentSnapshot) map[string]any {
+	return query.NewRecord(ds.Ref.ID, codec.Path(ds.Ref), ds.Data())
+}
+
+// selectsAll reports whether the input reads a whole collection, with nothing to filter, order, skip or limit its
+// documents by, so they can be listed rather than queried.
+func selectsAll(input query.Input) bool {
+	return !input.CollectionGroup && len(input.Fields) == 0 && len(input.Filter) == 0 && len(input.OrderBy) == 0 &&
+		input.Limit == 0 && input.Offset == 0 && input.StartAt == nil && input.StartAfter == nil && input.EndAt == nil &&
+		input.EndBefore == nil && len(input.PageToken) == 0
+}
@@ -4,14 +4,13 @@ import (
 	"cloud.google.com/go/firestore"
 	"context"
 	"fmt"
-	"jhight.com/firestore-cli/pkg/api/client/query"
-	"strings"
-	"time"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
 )
 
 type firestoreClientManager struct {
-	ctx    context.Context
-	client *firestore.Client
+	ctx         context.Context
+	client      *firestore.Client
+	credentials Credentials
 }
 
 func (f *firestoreClientManager) IsPathToDocument(path string) bool {
@@ -23,11 +22,21 @@ func (f *firestoreClientManager) IsPathToCollection(path string) bool {
 }
 
 func (f *firestoreClientManager) Create(path string, fields map[string]any) error {
-	return create(f.ctx, f.client, path, processInputValues(fields))
+	processed, err := f.processInputValues(fields)
+	if err != nil {
+		return err
+	}
+
+	return create(f.ctx, f.client, path, withoutDeletes(processed))
 }
 
 func (f *firestoreClientManager) Set(path string, fields map[string]any) error {
-	return set(f.ctx, f.client, path, processInputValues(fields))
+	processed, err := f.processInputValues(fields)
+	if err != nil {
+		return err
+	}
+
+	return set(f.ctx, f.client, path, withoutDeletes(processed))
 }
 
 func (f *firestoreClientManager) Update(path string, fields map[string]any) error {
@@ -35,40 +44,27 @@ func (f *firestoreClientManager) Update(path string, fields map[string]any) erro
 		return fmt.Errorf("no fields to update")
 	}
 
-	return update(f.ctx, f.client, path, processInputValues(fields))
-}
+	processed, err := f.processInputValues(fields)
+	if err != nil {
+		return err
+	}
 
-func (f *firestoreClientManager) Delete(path string) error {
-	return remove(f.ctx, f.client, path)
+	return update(f.ctx, f.client, path, processed)
 }
 
 func (f *firestoreClientManager) DeleteField(path string, field string) error {
 	return removeField(f.ctx, f.client, path, field)
 }
 
+func (f *firestoreClientManager) Credentials() Credentials {
+	return f.credentials
+}
+
 func (f *firestoreClientManager) Close() error {
 	return f.client.Close()
 }
 
-func processInputValues(fields map[string]any) map[string]any {
-	for k, v := range fields {
-		switch (v).(type) {
-		case string:
-			s := v.(string)
-			if strings.HasPrefix(s, query.FunctionTimestamp) {
-				val := strings.TrimSuffix(strings.TrimPrefix(v.(string), query.FunctionTimestamp+"("), ")")
-				parsed, err := time.Parse(time.RFC3339Nano, val)
-				if err != nil {
-					fmt.Printf("invalid timestamp format %s; see help for more information on query syntax", val)
-					return nil
-				} else {
-					fields[k] = parsed
-				}
-			} else if strings.ToLower(s) == query.FunctionNow+"()" {
-				fields[k] = time.Now()
-			}
-		}
-	}
-
-	return fields
+// Decode turns values encoded by codec (e.g., read from a file) back into Firestore types.
+func (f *firestoreClientManager) Decode(value any) (any, error) {
+	return codec.Decode(value, f.client)
 }
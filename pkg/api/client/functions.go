@@ -0,0 +1,242 @@
+package client
+
+import (
+	"cloud.google.com/go/firestore"
+	"fmt"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// inputFunction turns the argument of a $function(argument) input token into the value to write.
+type inputFunction func(f *firestoreClientManager, argument string) (any, error)
+
+// inputFunctions holds every $function(...) token accepted in input values. Names are matched case-insensitively. To
+// add a new function, register it here.
+var inputFunctions = map[string]inputFunction{
+	query.FunctionNow: func(_ *firestoreClientManager, _ string) (any, error) {
+		return time.Now(), nil
+	},
+	query.FunctionTimestamp: func(_ *firestoreClientManager, argument string) (any, error) {
+		parsed, err := time.Parse(time.RFC3339Nano, argument)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp format %s", argument)
+		}
+		return parsed, nil
+	},
+	query.FunctionIncrement: func(f *firestoreClientManager, argument string) (any, error) {
+		args, err := f.functionArguments(argument)
+		if err != nil || len(args) != 1 {
+			return nil, fmt.Errorf("%s requires a single number", query.FunctionIncrement)
+		}
+		switch args[0].(type) {
+		case int64, float64:
+			return firestore.Increment(args[0]), nil
+		default:
+			return nil, fmt.Errorf("%s requires a single number", query.FunctionIncrement)
+		}
+	},
+	query.FunctionArrayUnion: func(f *firestoreClientManager, argument string) (any, error) {
+		args, err := f.functionArguments(argument)
+		if err != nil {
+			return nil, err
+		}
+		return firestore.ArrayUnion(args...), nil
+	},
+	query.FunctionArrayRemove: func(f *firestoreClientManager, argument string) (any, error) {
+		args, err := f.functionArguments(argument)
+		if err != nil {
+			return nil, err
+		}
+		return firestore.ArrayRemove(args...), nil
+	},
+	query.FunctionServerTimestamp: func(_ *firestoreClientManager, _ string) (any, error) {
+		return firestore.ServerTimestamp, nil
+	},
+	query.FunctionDelete: func(_ *firestoreClientManager, _ string) (any, error) {
+		return firestore.Delete, nil
+	},
+}
+
+var functionPattern = regexp.MustCompile(`^(\$[A-Za-z]+)\((.*)\)$`)
+
+// processInputValues prepares user input for writing: tagged values are decoded into Firestore types and
+// $function(...) tokens are evaluated, at any depth of nested maps and arrays. A token escaped with another $ in front,
+// such as $$delete(), is written as the string it escapes instead.
+func (f *firestoreClientManager) processInputValues(fields map[string]any) (map[string]any, error) {
+	// fields are transformed one by one, so a document whose only field is named like a tag isn't taken for a tagged
+	// value
+	tag, escaped := codec.EscapedTag(fields)
+	processed := make(map[string]any, len(fields))
+	for k, v := range fields {
+		t, err := f.transformInput(v, k)
+		if err != nil {
+			return nil, err
+		}
+		if escaped {
+			k = tag
+		}
+		processed[k] = t
+	}
+	return processed, nil
+}
+
+// writeFields prepares the fields of a write, which are only decoded for a raw write.
+func (f *firestoreClientManager) writeFields(w Write) (map[string]any, error) {
+	if !w.Raw {
+		return f.processInputValues(w.Fields)
+	}
+
+	// fields are decoded one by one, so a document whose only field is named like a tag isn't taken for a tagged value
+	decoded := make(map[string]any, len(w.Fields))
+	for k, v := range w.Fields {
+		d, err := codec.Decode(v, f.client)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value, %s", err)
+		}
+		decoded[k] = d
+	}
+	return decoded, nil
+}
+
+func (f *firestoreClientManager) transformInput(value any, path string) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		if codec.IsTagged(v) {
+			decoded, err := codec.Decode(v, f.client)
+			if err != nil {
+				return nil, inputError(path, err)
+			}
+			return decoded, nil
+		}
+
+		transformed := make(map[string]any, len(v))
+		for k, e := range v {
+			t, err := f.transformInput(e, fieldPath(path, k))
+			if err != nil {
+				return nil, err
+			}
+			if tag, ok := codec.EscapedTag(v); ok {
+				// {"$$ref":...} is the escaped form of a map with a $ref field, which is written as it is
+				k = tag
+			}
+			transformed[k] = t
+		}
+		return transformed, nil
+	case []any:
+		transformed := make([]any, 0, len(v))
+		for i, e := range v {
+			t, err := f.transformInput(e, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			transformed = append(transformed, t)
+		}
+		return transformed, nil
+	case string:
+		if literal, ok := codec.Unescape(v); ok {
+			// $$function(...) is the escaped form of the string $function(...), which is written as it is
+			return literal, nil
+		}
+		matches := functionPattern.FindStringSubmatch(v)
+		if matches == nil {
+			return v, nil
+		}
+		fn, ok := lookupInputFunction(matches[1])
+		if !ok {
+			// not one of ours, so keep it as a literal string
+			return v, nil
+		}
+		t, err := fn(f, strings.TrimSpace(matches[2]))
+		if err != nil {
+			return nil, inputError(path, err)
+		}
+		return t, nil
+	default:
+		decoded, err := codec.Decode(v, f.client)
+		if err != nil {
+			return nil, inputError(path, err)
+		}
+		return decoded, nil
+	}
+}
+
+// functionArguments parses a comma-separated argument list as JSON values, e.g. 1, "a", {"$ref":"users/1"}.
+func (f *firestoreClientManager) functionArguments(argument string) ([]any, error) {
+	if len(argument) == 0 {
+		return []any{}, nil
+	}
+
+	var args []any
+	if err := codec.Unmarshal([]byte("["+argument+"]"), &args); err != nil {
+		return nil, fmt.Errorf("invalid function arguments %s, %s", argument, err)
+	}
+
+	decoded, err := codec.Decode(args, f.client)
+	if err != nil {
+		return nil, err
+	}
+	return decoded.([]any), nil
+}
+
+func lookupInputFunction(name string) (inputFunction, bool) {
+	for n, fn := range inputFunctions {
+		if strings.EqualFold(n, name) {
+			return fn, true
+		}
+	}
+	return nil, false
+}
+
+// withoutDeletes drops fields set to firestore.Delete, at any depth of nested maps and arrays. Create and a full Set
+// replace the whole document, so leaving the field out is how it gets deleted there; only Update and a merging Set
+// accept the sentinel itself (see updates and mergeFields).
+func withoutDeletes(fields map[string]any) map[string]any {
+	return dropDeletes(fields, true).(map[string]any)
+}
+
+// mergeFields prepares fields for a merging Set, which deletes a field set to firestore.Delete at any depth of nested
+// maps. An array is written whole, so a delete inside it is dropped instead.
+func mergeFields(fields map[string]any) map[string]any {
+	return dropDeletes(fields, false).(map[string]any)
+}
+
+// dropDeletes removes firestore.Delete from the arrays in a value, and from its maps as well when maps is set. Maps
+// inside arrays are written whole, so deletes are always dropped from them.
+func dropDeletes(value any, maps bool) any {
+	switch v := value.(type) {
+	case map[string]any:
+		kept := make(map[string]any, len(v))
+		for k, e := range v {
+			if maps && e == firestore.Delete {
+				continue
+			}
+			kept[k] = dropDeletes(e, maps)
+		}
+		return kept
+	case []any:
+		kept := make([]any, 0, len(v))
+		for _, e := range v {
+			if e == firestore.Delete {
+				continue
+			}
+			kept = append(kept, dropDeletes(e, true))
+		}
+		return kept
+	default:
+		return value
+	}
+}
+
+func fieldPath(parent string, field string) string {
+	if len(parent) == 0 {
+		return field
+	}
+	return parent + "." + field
+}
+
+func inputError(path string, err error) error {
+	return fmt.Errorf("invalid value at %s, %s; see help for more information on input syntax", path, err)
+}
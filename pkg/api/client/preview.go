@@ -0,0 +1,124 @@
+package client
+
+import (
+	"cloud.google.com/go/firestore"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"jhight.com/firestore-cli/pkg/api/client/preview"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"maps"
+	"strings"
+	"time"
+)
+
+// Plan is what a write would do to a document: its state before the write (nil if it doesn't exist) and after it (nil
+// if the write deletes it).
+type Plan struct {
+	Write  Write
+	Before map[string]any
+	After  map[string]any
+}
+
+// Preview works out what a write would do, without writing. Input is processed as it would be for the write, and
+// transforms are applied to the current values: $increment(...), $arrayUnion(...) and $arrayRemove(...) show their
+// results, and $serverTimestamp() shows the current time. A write that would fail returns the error it would fail
+// with.
+func (f *firestoreClientManager) Preview(w Write) (Plan, error) {
+	plan := Plan{Write: w}
+
+	fields := w.Fields
+	if w.Raw {
+		// a raw write is previewed as the input it's equivalent to, with its function-like strings escaped
+		decoded, err := f.writeFields(w)
+		if err != nil {
+			return plan, err
+		}
+		fields = codec.Encode(decoded).(map[string]any)
+	}
+
+	dr := f.client.Doc(w.Path)
+	if dr == nil {
+		return plan, fmt.Errorf("invalid document path, %s", w.Path)
+	}
+
+	ds, err := dr.Get(f.ctx)
+	if err != nil && !IsNotFound(err) {
+		return plan, fmt.Errorf("error reading document, %s", err)
+	}
+	if ds.Exists() {
+		plan.Before = ds.Data()
+	}
+
+	switch w.Mode {
+	case WriteModeDelete:
+		return plan, nil
+	case WriteModeCreate:
+		if plan.Before != nil {
+			return plan, status.Errorf(codes.AlreadyExists, "document already exists: %s", w.Path)
+		}
+		plan.After, err = preview.Fields(nil, fields, f.previewValue)
+	case WriteModeSet:
+		plan.After, err = preview.Fields(nil, fields, f.previewValue)
+	case WriteModeMerge:
+		plan.After, err = preview.Fields(plan.Before, fields, f.previewValue)
+	case WriteModeUpdate:
+		if plan.Before == nil {
+			return plan, status.Errorf(codes.NotFound, "no document to update: %s", w.Path)
+		}
+		if len(fields) == 0 {
+			return plan, fmt.Errorf("no fields to update")
+		}
+		plan.After = maps.Clone(plan.Before)
+		for k, v := range fields {
+			// update keys are field paths, so nested fields can be set without replacing their parents
+			if err = preview.Update(plan.After, strings.Split(k, "."), v, k, f.previewValue); err != nil {
+				break
+			}
+		}
+	default:
+		return plan, fmt.Errorf("unknown write mode %s", w.Mode)
+	}
+
+	return plan, err
+}
+
+// previewValue works out the value a field would have once written, given its current value, and whether the write
+// removes it.
+func (f *firestoreClientManager) previewValue(current any, input any, path string) (any, bool, error) {
+	value, err := f.transformInput(input, path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if value == firestore.Delete {
+		return nil, true, nil
+	}
+	if value == firestore.ServerTimestamp {
+		return time.Now(), false, nil
+	}
+	// a map or array is written whole, without the fields deleted inside it
+	value = dropDeletes(value, true)
+
+	// transforms can't be inspected, so their arguments are read from the input again
+	s, _ := input.(string)
+	matches := functionPattern.FindStringSubmatch(s)
+	if matches == nil {
+		return value, false, nil
+	}
+
+	switch {
+	case strings.EqualFold(matches[1], query.FunctionIncrement):
+		args, _ := f.functionArguments(strings.TrimSpace(matches[2]))
+		return preview.Increment(current, args[0]), false, nil
+	case strings.EqualFold(matches[1], query.FunctionArrayUnion):
+		args, _ := f.functionArguments(strings.TrimSpace(matches[2]))
+		return preview.ArrayUnion(current, args), false, nil
+	case strings.EqualFold(matches[1], query.FunctionArrayRemove):
+		args, _ := f.functionArguments(strings.TrimSpace(matches[2]))
+		return preview.ArrayRemove(current, args), false, nil
+	default:
+		return value, false, nil
+	}
+}
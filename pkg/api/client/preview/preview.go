@@ -0,0 +1,128 @@
+package preview
+
+import (
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"jhight.com/firestore-cli/pkg/api/client/diff"
+	"maps"
+)
+
+// Value works out the value a field would have once written, given its current value and the input for it, and
+// whether the write removes the field.
+type Value func(current any, input any, path string) (any, bool, error)
+
+// Fields applies fields to a copy of the current ones, merging nested maps, as a merge does. With no current fields,
+// the result is the fields alone, as a create or set writes them.
+func Fields(current map[string]any, fields map[string]any, value Value) (map[string]any, error) {
+	result := maps.Clone(current)
+	if result == nil {
+		result = make(map[string]any, len(fields))
+	}
+
+	for k, v := range fields {
+		if nested, ok := v.(map[string]any); ok && !codec.IsTagged(nested) {
+			var currentNested map[string]any
+			if current != nil {
+				currentNested, _ = result[k].(map[string]any)
+			}
+			merged, err := Fields(currentNested, nested, value)
+			if err != nil {
+				return nil, err
+			}
+			result[k] = merged
+			continue
+		}
+
+		written, remove, err := value(result[k], v, k)
+		if err != nil {
+			return nil, err
+		}
+		if remove {
+			delete(result, k)
+			continue
+		}
+		result[k] = written
+	}
+
+	return result, nil
+}
+
+// Update sets the value at a field path of a document, such as address.city, creating parent maps as needed. Parent
+// maps are copied rather than changed in place.
+func Update(document map[string]any, keys []string, input any, path string, value Value) error {
+	parent := document
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := parent[key].(map[string]any)
+		if ok {
+			child = maps.Clone(child)
+		} else {
+			child = make(map[string]any)
+		}
+		parent[key] = child
+		parent = child
+	}
+
+	key := keys[len(keys)-1]
+	written, remove, err := value(parent[key], input, path)
+	if err != nil {
+		return err
+	}
+	if remove {
+		delete(parent, key)
+	} else {
+		parent[key] = written
+	}
+	return nil
+}
+
+// Increment adds n to a number the way Firestore does: integers stay integers, and anything that isn't a number
+// becomes n.
+func Increment(current any, n any) any {
+	switch c := current.(type) {
+	case int64:
+		if i, ok := n.(int64); ok {
+			return c + i
+		}
+		return float64(c) + n.(float64)
+	case float64:
+		if i, ok := n.(int64); ok {
+			return c + float64(i)
+		}
+		return c + n.(float64)
+	default:
+		return n
+	}
+}
+
+// ArrayUnion adds the elements missing from the current array, as $arrayUnion(...) does. Anything that isn't an
+// array is replaced.
+func ArrayUnion(current any, elements []any) []any {
+	existing, _ := current.([]any)
+	result := append([]any{}, existing...)
+	for _, e := range elements {
+		if !contains(result, e) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// ArrayRemove removes every instance of the elements from the current array, as $arrayRemove(...) does.
+func ArrayRemove(current any, elements []any) []any {
+	existing, _ := current.([]any)
+	kept := make([]any, 0, len(existing))
+	for _, e := range existing {
+		if !contains(elements, e) {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+func contains(values []any, value any) bool {
+	for _, v := range values {
+		if diff.Equal(v, value) {
+			return true
+		}
+	}
+	return false
+}
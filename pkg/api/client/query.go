@@ -2,24 +2,140 @@ package client
 
 import (
 	"cloud.google.com/go/firestore"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"google.golang.org/api/iterator"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
 	"jhight.com/firestore-cli/pkg/api/client/query"
+	"strings"
 )
 
-func (f *firestoreClientManager) Query(input query.Input) ([]map[string]any, error) {
+const defaultPageSize = 100
+
+// Query streams documents matching the input to visit as they are read, so the caller can start output right away and
+// memory use doesn't grow with the size of the result.
+func (f *firestoreClientManager) Query(input query.Input, visit func(document map[string]any) error) error {
+	q, err := f.query(input)
+	if err != nil {
+		return err
+	}
+
+	iter := q.Documents(f.ctx)
+	defer iter.Stop()
+
+	for {
+		ds, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error querying documents, %s", err)
+		}
+
+		if err = visit(f.document(ds, input.Fields)); err != nil {
+			return err
+		}
+	}
+}
+
+// QueryPage returns one page of up to input.Limit documents, starting after input.PageToken if given, along with an
+// opaque token for the next page. The token is empty once there are no more pages. Paging uses query cursors, so
+// unlike offsets, skipped documents are never read or billed.
+func (f *firestoreClientManager) QueryPage(input query.Input) ([]map[string]any, string, error) {
+	if input.Limit <= 0 {
+		input.Limit = defaultPageSize
+	}
+	limit := input.Limit
+
+	// the offset skips documents before the first page; later pages start after the token instead
+	if len(input.PageToken) > 0 {
+		input.Offset = 0
+	}
+
+	// read one more document than the page holds, so a next token is only given when there's a next page
+	input.Limit++
+	q, err := f.query(input)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// order by document ID last, so the cursor is unique even when order by values repeat
+	direction := firestore.Asc
+	if len(input.OrderBy) > 0 {
+		direction = input.OrderBy[len(input.OrderBy)-1].Direction.FirestoreDirection()
+	}
+	q = q.OrderBy(firestore.DocumentID, direction)
+
+	if len(input.PageToken) > 0 {
+		values, err := f.decodePageToken(input.PageToken, len(input.OrderBy)+1)
+		if err != nil {
+			return nil, "", err
+		}
+		q = q.StartAfter(values...)
+	}
+
+	ds, err := q.Documents(f.ctx).GetAll()
+	if err != nil {
+		return nil, "", fmt.Errorf("error querying documents, %s", err)
+	}
+
+	next := ""
+	if len(ds) > limit {
+		ds = ds[:limit]
+		if next, err = encodePageToken(ds[len(ds)-1], input.OrderBy); err != nil {
+			return nil, "", err
+		}
+	}
+
+	return f.documents(ds, input.Fields), next, nil
+}
+
+func (f *firestoreClientManager) documents(ds []*firestore.DocumentSnapshot, fields []string) []map[string]any {
+	documents := make([]map[string]any, 0)
+	for _, d := range ds {
+		documents = append(documents, f.document(d, fields))
+	}
+
+	return documents
+}
+
+func (f *firestoreClientManager) document(ds *firestore.DocumentSnapshot, fields []string) map[string]any {
+	if len(fields) == 0 {
+		return ds.Data()
+	}
+	return f.projection(ds, fields)
+}
+
+// query builds the Firestore query for the input, starting from either a single collection or, for collection group
+// queries, every collection with the given ID.
+func (f *firestoreClientManager) query(input query.Input) (firestore.Query, error) {
 	var q firestore.Query
 
-	if len(input.Filter) == 0 {
-		q = f.client.Collection(input.Path).Offset(input.Offset)
+	if input.CollectionGroup {
+		if len(input.Path) == 0 || strings.Contains(input.Path, "/") {
+			return q, fmt.Errorf("invalid collection group ID, %s; must be a collection ID such as orders", input.Path)
+		}
+		q = f.client.CollectionGroup(input.Path).Query
 	} else {
+		cr := f.client.Collection(input.Path)
+		if cr == nil {
+			return q, fmt.Errorf("invalid collection path, %s", input.Path)
+		}
+		q = cr.Query
+	}
+
+	if len(input.Filter) > 0 {
 		root, err := query.CreateExpression(input.Filter)
 		if err != nil {
-			return nil, err
+			return q, err
 		}
 
-		q = f.client.
-			Collection(input.Path).
-			WhereEntity(root.FirestoreFilter())
+		q = q.WhereEntity(root.FirestoreFilter())
+	}
+
+	if input.Offset > 0 {
+		q = q.Offset(input.Offset)
 	}
 
 	if len(input.OrderBy) > 0 {
@@ -32,21 +148,92 @@ func (f *firestoreClientManager) Query(input query.Input) ([]map[string]any, err
 		q = q.Limit(input.Limit)
 	}
 
-	iter := q.Documents(f.ctx)
-	ds, err := iter.GetAll()
+	var err error
+	if q, err = f.applyCursor(q, input.StartAt, firestore.Query.StartAt); err != nil {
+		return q, err
+	}
+	if q, err = f.applyCursor(q, input.StartAfter, firestore.Query.StartAfter); err != nil {
+		return q, err
+	}
+	if q, err = f.applyCursor(q, input.EndAt, firestore.Query.EndAt); err != nil {
+		return q, err
+	}
+	if q, err = f.applyCursor(q, input.EndBefore, firestore.Query.EndBefore); err != nil {
+		return q, err
+	}
+
+	return q, nil
+}
+
+func (f *firestoreClientManager) applyCursor(q firestore.Query, c *query.Cursor, apply func(firestore.Query, ...any) firestore.Query) (firestore.Query, error) {
+	if c == nil {
+		return q, nil
+	}
+
+	values, err := f.cursorValues(c)
 	if err != nil {
-		return nil, fmt.Errorf("error querying documents, %s", err)
+		return q, err
 	}
 
-	documents := make([]map[string]any, 0)
-	for _, d := range ds {
-		if len(input.Fields) == 0 {
-			documents = append(documents, d.Data())
-		} else {
-			projection := f.projection(d, input.Fields)
-			documents = append(documents, projection)
+	return apply(q, values...), nil
+}
+
+func (f *firestoreClientManager) cursorValues(c *query.Cursor) ([]any, error) {
+	if len(c.DocumentPath) > 0 {
+		dr := f.client.Doc(c.DocumentPath)
+		if dr == nil {
+			return nil, fmt.Errorf("invalid cursor document path, %s", c.DocumentPath)
+		}
+		ds, err := dr.Get(f.ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error reading cursor document, %s", err)
 		}
+		return []any{ds}, nil
+	}
+
+	decoded, err := codec.Decode(c.Values, f.client)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor value, %s", err)
+	}
+	return decoded.([]any), nil
+}
+
+// encodePageToken captures the order by values and reference of the last document in a page.
+func encodePageToken(last *firestore.DocumentSnapshot, orderBy []query.OrderBy) (string, error) {
+	values := make([]any, 0, len(orderBy)+1)
+	for _, o := range orderBy {
+		v, err := last.DataAt(o.Field)
+		if err != nil {
+			return "", fmt.Errorf("error creating page token, %s", err)
+		}
+		values = append(values, v)
+	}
+	values = append(values, last.Ref)
+
+	data, err := json.Marshal(codec.Encode(values))
+	if err != nil {
+		return "", fmt.Errorf("error creating page token, %s", err)
 	}
 
-	return documents, nil
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func (f *firestoreClientManager) decodePageToken(token string, expected int) ([]any, error) {
+	invalid := fmt.Errorf("invalid page token; page tokens are only valid for the query that produced them")
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, invalid
+	}
+
+	var values []any
+	if err = codec.Unmarshal(data, &values); err != nil || len(values) != expected {
+		return nil, invalid
+	}
+
+	decoded, err := codec.Decode(values, f.client)
+	if err != nil {
+		return nil, invalid
+	}
+	return decoded.([]any), nil
 }
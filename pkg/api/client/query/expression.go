@@ -46,8 +46,13 @@ const (
 )
 
 const (
-	FunctionTimestamp string = "$timestamp"
-	FunctionNow       string = "$now"
+	FunctionTimestamp       string = "$timestamp"
+	FunctionNow             string = "$now"
+	FunctionIncrement       string = "$increment"
+	FunctionArrayUnion      string = "$arrayUnion"
+	FunctionArrayRemove     string = "$arrayRemove"
+	FunctionServerTimestamp string = "$serverTimestamp"
+	FunctionDelete          string = "$delete"
 )
 
 func CreateExpression(body map[string]any) (*Expression, error) {
@@ -103,3 +108,12 @@ const (
 	SelectionDocumentID   string = "$id"
 	SelectionDocumentPath string = "$path"
 )
+
+const (
+	AggregateCount string = "$count"
+	AggregateSum   string = "$sum"
+	AggregateAvg   string = "$avg"
+)
+
+// CollectionGroupPrefix marks a path as a collection group, e.g. **/orders queries every orders collection.
+const CollectionGroupPrefix = "**/"
@@ -1,13 +1,33 @@
 package query
 
 type Input struct {
-	Path    string
-	Fields  []string
-	Filter  map[string]any
-	OrderBy []OrderBy
-	Limit   int
-	Offset  int
-	Count   bool
+	Path            string
+	CollectionGroup bool
+	Fields          []string
+	Filter          map[string]any
+	OrderBy         []OrderBy
+	Limit           int
+	Offset          int
+	Count           bool
+	Sum             []string
+	Avg             []string
+	StartAt         *Cursor
+	StartAfter      *Cursor
+	EndAt           *Cursor
+	EndBefore       *Cursor
+	PageToken       string
+}
+
+// Cursor marks a position in an ordered query, either by the values of the order by fields or by a document, whose
+// values are read from its snapshot.
+type Cursor struct {
+	Values       []any
+	DocumentPath string
+}
+
+// Aggregates reports whether the input asks for a server-side aggregation rather than documents.
+func (i Input) Aggregates() bool {
+	return i.Count || len(i.Sum) > 0 || len(i.Avg) > 0
 }
 
 type OrderBy struct {
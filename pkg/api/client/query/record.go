@@ -0,0 +1,37 @@
+package query
+
+import "strings"
+
+// NewRecord returns a document as an exported record, its data along with its $id and $path. A top-level field whose
+// name starts with $ is written with another $ in front, so it can't be mistaken for either (see SplitRecord).
+func NewRecord(id string, path string, data map[string]any) map[string]any {
+	record := make(map[string]any, len(data)+2)
+	for k, v := range data {
+		if strings.HasPrefix(k, "$") {
+			k = "$" + k
+		}
+		record[k] = v
+	}
+	record[SelectionDocumentID] = id
+	record[SelectionDocumentPath] = path
+	return record
+}
+
+// SplitRecord separates a record into its $id, $path and document data, taking the extra $ off field names that
+// NewRecord added. The record itself isn't changed.
+func SplitRecord(record map[string]any) (string, string, map[string]any) {
+	id, _ := record[SelectionDocumentID].(string)
+	path, _ := record[SelectionDocumentPath].(string)
+
+	data := make(map[string]any, len(record))
+	for k, v := range record {
+		if k == SelectionDocumentID || k == SelectionDocumentPath {
+			continue
+		}
+		if strings.HasPrefix(k, "$$") {
+			k = k[1:]
+		}
+		data[k] = v
+	}
+	return id, path, data
+}
@@ -4,11 +4,9 @@ import (
 	"cloud.google.com/go/firestore"
 	"context"
 	"fmt"
-	"google.golang.org/api/option"
 	"jhight.com/firestore-cli/pkg/api/client/query"
 	"jhight.com/firestore-cli/pkg/config"
 	"os"
-	"strings"
 )
 
 //go:generate go run go.uber.org/mock/mockgen -typed -package $GOPACKAGE -source $GOFILE -destination $GOFILE.mocks.go
@@ -16,27 +14,86 @@ type Store interface {
 	IsPathToDocument(path string) bool
 	IsPathToCollection(path string) bool
 	Get(input query.Input) (map[string]any, error)
-	Query(input query.Input) ([]map[string]any, error)
+	Query(input query.Input, visit func(document map[string]any) error) error
+	QueryPage(input query.Input) ([]map[string]any, string, error)
+	Aggregate(input query.Input) (map[string]any, error)
 	Collections(input query.Input) ([]any, error)
+	Export(input query.Input, recursive bool, visit func(document map[string]any) error) error
+	Watch(ctx context.Context, input query.Input, visit func(change Change) error) error
 	Create(path string, fields map[string]any) error
 	Set(path string, fields map[string]any) error
 	Update(path string, fields map[string]any) error
-	Delete(path string) error
+	Delete(path string, resume string, done func(w Write, err error), checkpoint func(resume string)) error
+	BulkWrite(next func() (Write, bool), done func(w Write, err error)) error
+	Transact(maxAttempts int, fn func(tx Tx) error) error
+	Batch(writes []Write) error
+	Copy(source string, destination string, recursive bool, conflict ConflictPolicy, done func(w Write, err error)) error
 	DeleteField(path string, field string) error
+	Preview(w Write) (Plan, error)
+	Decode(value any) (any, error)
+	Credentials() Credentials
 	Close() error
 }
 
+// Tx reads and writes documents inside a transaction.
+type Tx interface {
+	Get(path string) (map[string]any, error)
+	Write(w Write) error
+}
+
 func New(ctx context.Context, cfg config.Config) (Store, error) {
-	home := os.Getenv("HOME")
-	path := strings.ReplaceAll(cfg.ServiceAccount, "~", home)
+	creds, err := ResolveCredentials(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	client, err := firestore.NewClient(ctx, cfg.ProjectID, option.WithCredentialsFile(path))
+	projectID := cfg.ProjectID
+	if len(projectID) == 0 {
+		projectID = creds.ProjectID
+	}
+
+	// the firestore client dials the emulator without credentials whenever this variable is set as it's created, so it
+	// is set to the config's own emulator host, or cleared, and put back afterwards
+	previous, set := os.LookupEnv(EmulatorHostEnv)
+	if len(cfg.EmulatorHost) > 0 {
+		err = os.Setenv(EmulatorHostEnv, cfg.EmulatorHost)
+	} else {
+		err = os.Unsetenv(EmulatorHostEnv)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error configuring emulator host, %s", err)
+	}
+	defer func() {
+		if set {
+			_ = os.Setenv(EmulatorHostEnv, previous)
+		} else {
+			_ = os.Unsetenv(EmulatorHostEnv)
+		}
+	}()
+
+	if len(cfg.EmulatorHost) > 0 && len(projectID) == 0 {
+		projectID = defaultEmulatorProjectID
+	}
+
+	if len(projectID) == 0 {
+		return nil, fmt.Errorf("project ID is not configured and could not be determined from %s credentials", creds.Source)
+	}
+	cfg.ProjectID = projectID
+	creds.ProjectID = projectID
+
+	var client *firestore.Client
+	if databaseID := DatabaseID(cfg); databaseID != firestore.DefaultDatabaseID {
+		client, err = firestore.NewClientWithDatabase(ctx, projectID, databaseID, creds.options...)
+	} else {
+		client, err = firestore.NewClient(ctx, projectID, creds.options...)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error creating firestore client, %s", err)
+		return nil, fmt.Errorf("error creating firestore client for %s, %s", Backend(cfg), err)
 	}
 
 	return &firestoreClientManager{
-		ctx:    ctx,
-		client: client,
+		ctx:         ctx,
+		client:      client,
+		credentials: creds,
 	}, nil
 }
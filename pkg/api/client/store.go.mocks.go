@@ -10,6 +10,7 @@
 package client
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "go.uber.org/mock/gomock"
@@ -39,6 +40,121 @@ func (m *MockStore) EXPECT() *MockStoreMockRecorder {
 	return m.recorder
 }
 
+// Aggregate mocks base method.
+func (m *MockStore) Aggregate(input query.Input) (map[string]any, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Aggregate", input)
+	ret0, _ := ret[0].(map[string]any)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Aggregate indicates an expected call of Aggregate.
+func (mr *MockStoreMockRecorder) Aggregate(input any) *MockStoreAggregateCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Aggregate", reflect.TypeOf((*MockStore)(nil).Aggregate), input)
+	return &MockStoreAggregateCall{Call: call}
+}
+
+// MockStoreAggregateCall wrap *gomock.Call
+type MockStoreAggregateCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockStoreAggregateCall) Return(arg0 map[string]any, arg1 error) *MockStoreAggregateCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockStoreAggregateCall) Do(f func(query.Input) (map[string]any, error)) *MockStoreAggregateCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockStoreAggregateCall) DoAndReturn(f func(query.Input) (map[string]any, error)) *MockStoreAggregateCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// Batch mocks base method.
+func (m *MockStore) Batch(writes []Write) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Batch", writes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Batch indicates an expected call of Batch.
+func (mr *MockStoreMockRecorder) Batch(writes any) *MockStoreBatchCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Batch", reflect.TypeOf((*MockStore)(nil).Batch), writes)
+	return &MockStoreBatchCall{Call: call}
+}
+
+// MockStoreBatchCall wrap *gomock.Call
+type MockStoreBatchCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockStoreBatchCall) Return(arg0 error) *MockStoreBatchCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockStoreBatchCall) Do(f func([]Write) error) *MockStoreBatchCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockStoreBatchCall) DoAndReturn(f func([]Write) error) *MockStoreBatchCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// BulkWrite mocks base method.
+func (m *MockStore) BulkWrite(next func() (Write, bool), done func(Write, error)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BulkWrite", next, done)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BulkWrite indicates an expected call of BulkWrite.
+func (mr *MockStoreMockRecorder) BulkWrite(next, done any) *MockStoreBulkWriteCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BulkWrite", reflect.TypeOf((*MockStore)(nil).BulkWrite), next, done)
+	return &MockStoreBulkWriteCall{Call: call}
+}
+
+// MockStoreBulkWriteCall wrap *gomock.Call
+type MockStoreBulkWriteCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockStoreBulkWriteCall) Return(arg0 error) *MockStoreBulkWriteCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockStoreBulkWriteCall) Do(f func(func() (Write, bool), func(Write, error)) error) *MockStoreBulkWriteCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockStoreBulkWriteCall) DoAndReturn(f func(func() (Write, bool), func(Write, error)) error) *MockStoreBulkWriteCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // Close mocks base method.
 func (m *MockStore) Close() error {
 	m.ctrl.T.Helper()
@@ -116,6 +232,44 @@ func (c *MockStoreCollectionsCall) DoAndReturn(f func(query.Input) ([]any, error
 	return c
 }
 
+// Copy mocks base method.
+func (m *MockStore) Copy(source, destination string, recursive bool, conflict ConflictPolicy, done func(Write, error)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Copy", source, destination, recursive, conflict, done)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Copy indicates an expected call of Copy.
+func (mr *MockStoreMockRecorder) Copy(source, destination, recursive, conflict, done any) *MockStoreCopyCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Copy", reflect.TypeOf((*MockStore)(nil).Copy), source, destination, recursive, conflict, done)
+	return &MockStoreCopyCall{Call: call}
+}
+
+// MockStoreCopyCall wrap *gomock.Call
+type MockStoreCopyCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockStoreCopyCall) Return(arg0 error) *MockStoreCopyCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockStoreCopyCall) Do(f func(string, string, bool, ConflictPolicy, func(Write, error)) error) *MockStoreCopyCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockStoreCopyCall) DoAndReturn(f func(string, string, bool, ConflictPolicy, func(Write, error)) error) *MockStoreCopyCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // Create mocks base method.
 func (m *MockStore) Create(path string, fields map[string]any) error {
 	m.ctrl.T.Helper()
@@ -154,18 +308,95 @@ func (c *MockStoreCreateCall) DoAndReturn(f func(string, map[string]any) error)
 	return c
 }
 
+// Credentials mocks base method.
+func (m *MockStore) Credentials() Credentials {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Credentials")
+	ret0, _ := ret[0].(Credentials)
+	return ret0
+}
+
+// Credentials indicates an expected call of Credentials.
+func (mr *MockStoreMockRecorder) Credentials() *MockStoreCredentialsCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Credentials", reflect.TypeOf((*MockStore)(nil).Credentials))
+	return &MockStoreCredentialsCall{Call: call}
+}
+
+// MockStoreCredentialsCall wrap *gomock.Call
+type MockStoreCredentialsCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockStoreCredentialsCall) Return(arg0 Credentials) *MockStoreCredentialsCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockStoreCredentialsCall) Do(f func() Credentials) *MockStoreCredentialsCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockStoreCredentialsCall) DoAndReturn(f func() Credentials) *MockStoreCredentialsCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// Decode mocks base method.
+func (m *MockStore) Decode(value any) (any, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Decode", value)
+	ret0, _ := ret[0].(any)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Decode indicates an expected call of Decode.
+func (mr *MockStoreMockRecorder) Decode(value any) *MockStoreDecodeCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Decode", reflect.TypeOf((*MockStore)(nil).Decode), value)
+	return &MockStoreDecodeCall{Call: call}
+}
+
+// MockStoreDecodeCall wrap *gomock.Call
+type MockStoreDecodeCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockStoreDecodeCall) Return(arg0 any, arg1 error) *MockStoreDecodeCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockStoreDecodeCall) Do(f func(any) (any, error)) *MockStoreDecodeCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockStoreDecodeCall) DoAndReturn(f func(any) (any, error)) *MockStoreDecodeCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // Delete mocks base method.
-func (m *MockStore) Delete(path string) error {
+func (m *MockStore) Delete(path, resume string, done func(Write, error), checkpoint func(string)) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete", path)
+	ret := m.ctrl.Call(m, "Delete", path, resume, done, checkpoint)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Delete indicates an expected call of Delete.
-func (mr *MockStoreMockRecorder) Delete(path any) *MockStoreDeleteCall {
+func (mr *MockStoreMockRecorder) Delete(path, resume, done, checkpoint any) *MockStoreDeleteCall {
 	mr.mock.ctrl.T.Helper()
-	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockStore)(nil).Delete), path)
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockStore)(nil).Delete), path, resume, done, checkpoint)
 	return &MockStoreDeleteCall{Call: call}
 }
 
@@ -181,13 +412,13 @@ func (c *MockStoreDeleteCall) Return(arg0 error) *MockStoreDeleteCall {
 }
 
 // Do rewrite *gomock.Call.Do
-func (c *MockStoreDeleteCall) Do(f func(string) error) *MockStoreDeleteCall {
+func (c *MockStoreDeleteCall) Do(f func(string, string, func(Write, error), func(string)) error) *MockStoreDeleteCall {
 	c.Call = c.Call.Do(f)
 	return c
 }
 
 // DoAndReturn rewrite *gomock.Call.DoAndReturn
-func (c *MockStoreDeleteCall) DoAndReturn(f func(string) error) *MockStoreDeleteCall {
+func (c *MockStoreDeleteCall) DoAndReturn(f func(string, string, func(Write, error), func(string)) error) *MockStoreDeleteCall {
 	c.Call = c.Call.DoAndReturn(f)
 	return c
 }
@@ -230,6 +461,44 @@ func (c *MockStoreDeleteFieldCall) DoAndReturn(f func(string, string) error) *Mo
 	return c
 }
 
+// Export mocks base method.
+func (m *MockStore) Export(input query.Input, recursive bool, visit func(map[string]any) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Export", input, recursive, visit)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Export indicates an expected call of Export.
+func (mr *MockStoreMockRecorder) Export(input, recursive, visit any) *MockStoreExportCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Export", reflect.TypeOf((*MockStore)(nil).Export), input, recursive, visit)
+	return &MockStoreExportCall{Call: call}
+}
+
+// MockStoreExportCall wrap *gomock.Call
+type MockStoreExportCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockStoreExportCall) Return(arg0 error) *MockStoreExportCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockStoreExportCall) Do(f func(query.Input, bool, func(map[string]any) error) error) *MockStoreExportCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockStoreExportCall) DoAndReturn(f func(query.Input, bool, func(map[string]any) error) error) *MockStoreExportCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // Get mocks base method.
 func (m *MockStore) Get(input query.Input) (map[string]any, error) {
 	m.ctrl.T.Helper()
@@ -345,19 +614,57 @@ func (c *MockStoreIsPathToDocumentCall) DoAndReturn(f func(string) bool) *MockSt
 	return c
 }
 
-// Query mocks base method.
-func (m *MockStore) Query(input query.Input) ([]map[string]any, error) {
+// Preview mocks base method.
+func (m *MockStore) Preview(w Write) (Plan, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Query", input)
-	ret0, _ := ret[0].([]map[string]any)
+	ret := m.ctrl.Call(m, "Preview", w)
+	ret0, _ := ret[0].(Plan)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
+// Preview indicates an expected call of Preview.
+func (mr *MockStoreMockRecorder) Preview(w any) *MockStorePreviewCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Preview", reflect.TypeOf((*MockStore)(nil).Preview), w)
+	return &MockStorePreviewCall{Call: call}
+}
+
+// MockStorePreviewCall wrap *gomock.Call
+type MockStorePreviewCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockStorePreviewCall) Return(arg0 Plan, arg1 error) *MockStorePreviewCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockStorePreviewCall) Do(f func(Write) (Plan, error)) *MockStorePreviewCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockStorePreviewCall) DoAndReturn(f func(Write) (Plan, error)) *MockStorePreviewCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// Query mocks base method.
+func (m *MockStore) Query(input query.Input, visit func(map[string]any) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Query", input, visit)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
 // Query indicates an expected call of Query.
-func (mr *MockStoreMockRecorder) Query(input any) *MockStoreQueryCall {
+func (mr *MockStoreMockRecorder) Query(input, visit any) *MockStoreQueryCall {
 	mr.mock.ctrl.T.Helper()
-	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*MockStore)(nil).Query), input)
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*MockStore)(nil).Query), input, visit)
 	return &MockStoreQueryCall{Call: call}
 }
 
@@ -367,19 +674,59 @@ type MockStoreQueryCall struct {
 }
 
 // Return rewrite *gomock.Call.Return
-func (c *MockStoreQueryCall) Return(arg0 []map[string]any, arg1 error) *MockStoreQueryCall {
-	c.Call = c.Call.Return(arg0, arg1)
+func (c *MockStoreQueryCall) Return(arg0 error) *MockStoreQueryCall {
+	c.Call = c.Call.Return(arg0)
 	return c
 }
 
 // Do rewrite *gomock.Call.Do
-func (c *MockStoreQueryCall) Do(f func(query.Input) ([]map[string]any, error)) *MockStoreQueryCall {
+func (c *MockStoreQueryCall) Do(f func(query.Input, func(map[string]any) error) error) *MockStoreQueryCall {
 	c.Call = c.Call.Do(f)
 	return c
 }
 
 // DoAndReturn rewrite *gomock.Call.DoAndReturn
-func (c *MockStoreQueryCall) DoAndReturn(f func(query.Input) ([]map[string]any, error)) *MockStoreQueryCall {
+func (c *MockStoreQueryCall) DoAndReturn(f func(query.Input, func(map[string]any) error) error) *MockStoreQueryCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// QueryPage mocks base method.
+func (m *MockStore) QueryPage(input query.Input) ([]map[string]any, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueryPage", input)
+	ret0, _ := ret[0].([]map[string]any)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// QueryPage indicates an expected call of QueryPage.
+func (mr *MockStoreMockRecorder) QueryPage(input any) *MockStoreQueryPageCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryPage", reflect.TypeOf((*MockStore)(nil).QueryPage), input)
+	return &MockStoreQueryPageCall{Call: call}
+}
+
+// MockStoreQueryPageCall wrap *gomock.Call
+type MockStoreQueryPageCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockStoreQueryPageCall) Return(arg0 []map[string]any, arg1 string, arg2 error) *MockStoreQueryPageCall {
+	c.Call = c.Call.Return(arg0, arg1, arg2)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockStoreQueryPageCall) Do(f func(query.Input) ([]map[string]any, string, error)) *MockStoreQueryPageCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockStoreQueryPageCall) DoAndReturn(f func(query.Input) ([]map[string]any, string, error)) *MockStoreQueryPageCall {
 	c.Call = c.Call.DoAndReturn(f)
 	return c
 }
@@ -422,6 +769,44 @@ func (c *MockStoreSetCall) DoAndReturn(f func(string, map[string]any) error) *Mo
 	return c
 }
 
+// Transact mocks base method.
+func (m *MockStore) Transact(maxAttempts int, fn func(Tx) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Transact", maxAttempts, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Transact indicates an expected call of Transact.
+func (mr *MockStoreMockRecorder) Transact(maxAttempts, fn any) *MockStoreTransactCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Transact", reflect.TypeOf((*MockStore)(nil).Transact), maxAttempts, fn)
+	return &MockStoreTransactCall{Call: call}
+}
+
+// MockStoreTransactCall wrap *gomock.Call
+type MockStoreTransactCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockStoreTransactCall) Return(arg0 error) *MockStoreTransactCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockStoreTransactCall) Do(f func(int, func(Tx) error) error) *MockStoreTransactCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockStoreTransactCall) DoAndReturn(f func(int, func(Tx) error) error) *MockStoreTransactCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // Update mocks base method.
 func (m *MockStore) Update(path string, fields map[string]any) error {
 	m.ctrl.T.Helper()
@@ -459,3 +844,141 @@ func (c *MockStoreUpdateCall) DoAndReturn(f func(string, map[string]any) error)
 	c.Call = c.Call.DoAndReturn(f)
 	return c
 }
+
+// Watch mocks base method.
+func (m *MockStore) Watch(ctx context.Context, input query.Input, visit func(Change) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Watch", ctx, input, visit)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Watch indicates an expected call of Watch.
+func (mr *MockStoreMockRecorder) Watch(ctx, input, visit any) *MockStoreWatchCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Watch", reflect.TypeOf((*MockStore)(nil).Watch), ctx, input, visit)
+	return &MockStoreWatchCall{Call: call}
+}
+
+// MockStoreWatchCall wrap *gomock.Call
+type MockStoreWatchCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockStoreWatchCall) Return(arg0 error) *MockStoreWatchCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockStoreWatchCall) Do(f func(context.Context, query.Input, func(Change) error) error) *MockStoreWatchCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockStoreWatchCall) DoAndReturn(f func(context.Context, query.Input, func(Change) error) error) *MockStoreWatchCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// MockTx is a mock of Tx interface.
+type MockTx struct {
+	ctrl     *gomock.Controller
+	recorder *MockTxMockRecorder
+}
+
+// MockTxMockRecorder is the mock recorder for MockTx.
+type MockTxMockRecorder struct {
+	mock *MockTx
+}
+
+// NewMockTx creates a new mock instance.
+func NewMockTx(ctrl *gomock.Controller) *MockTx {
+	mock := &MockTx{ctrl: ctrl}
+	mock.recorder = &MockTxMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTx) EXPECT() *MockTxMockRecorder {
+	return m.recorder
+}
+
+// Get mocks base method.
+func (m *MockTx) Get(path string) (map[string]any, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", path)
+	ret0, _ := ret[0].(map[string]any)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockTxMockRecorder) Get(path any) *MockTxGetCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockTx)(nil).Get), path)
+	return &MockTxGetCall{Call: call}
+}
+
+// MockTxGetCall wrap *gomock.Call
+type MockTxGetCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockTxGetCall) Return(arg0 map[string]any, arg1 error) *MockTxGetCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockTxGetCall) Do(f func(string) (map[string]any, error)) *MockTxGetCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockTxGetCall) DoAndReturn(f func(string) (map[string]any, error)) *MockTxGetCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
+// Write mocks base method.
+func (m *MockTx) Write(w Write) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Write", w)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Write indicates an expected call of Write.
+func (mr *MockTxMockRecorder) Write(w any) *MockTxWriteCall {
+	mr.mock.ctrl.T.Helper()
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Write", reflect.TypeOf((*MockTx)(nil).Write), w)
+	return &MockTxWriteCall{Call: call}
+}
+
+// MockTxWriteCall wrap *gomock.Call
+type MockTxWriteCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockTxWriteCall) Return(arg0 error) *MockTxWriteCall {
+	c.Call = c.Call.Return(arg0)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockTxWriteCall) Do(f func(Write) error) *MockTxWriteCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockTxWriteCall) DoAndReturn(f func(Write) error) *MockTxWriteCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"fmt"
+)
+
+// DefaultMaxAttempts is how many times a transaction is tried before giving up, when it keeps conflicting with other
+// writes.
+const DefaultMaxAttempts = firestore.DefaultTransactionMaxAttempts
+
+type firestoreTx struct {
+	f  *firestoreClientManager
+	tx *firestore.Transaction
+}
+
+// Transact runs fn inside a transaction, committing its writes together once it returns. When the transaction
+// conflicts with other writes, it's rolled back and fn is run again, up to maxAttempts times in all, so fn must not
+// have side effects of its own. As with any Firestore transaction, every read must come before the first write.
+func (f *firestoreClientManager) Transact(maxAttempts int, fn func(tx Tx) error) error {
+	return f.client.RunTransaction(f.ctx, func(_ context.Context, tx *firestore.Transaction) error {
+		return fn(&firestoreTx{f: f, tx: tx})
+	}, firestore.MaxAttempts(maxAttempts))
+}
+
+// Get reads a document in the transaction, returning nil if it doesn't exist.
+func (t *firestoreTx) Get(path string) (map[string]any, error) {
+	dr := t.f.client.Doc(path)
+	if dr == nil {
+		return nil, fmt.Errorf("invalid document path, %s", path)
+	}
+
+	ds, err := t.tx.Get(dr)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading document, %s", err)
+	}
+	return ds.Data(), nil
+}
+
+// Write adds a write to the transaction, made when it commits.
+func (t *firestoreTx) Write(w Write) error {
+	c, err := t.f.writeCall(w)
+	if err != nil {
+		return err
+	}
+
+	switch c.mode {
+	case WriteModeCreate:
+		return t.tx.Create(c.ref, c.data)
+	case WriteModeSet:
+		return t.tx.Set(c.ref, c.data, c.options...)
+	case WriteModeUpdate:
+		return t.tx.Update(c.ref, c.updates)
+	default:
+		return t.tx.Delete(c.ref)
+	}
+}
+
+// Batch commits writes atomically in a single batch: either all of them are made, or none are. Nothing is read, so
+// unlike a transaction there's nothing to conflict with and nothing to retry.
+func (f *firestoreClientManager) Batch(writes []Write) error {
+	if len(writes) > bulkBatchSize {
+		return fmt.Errorf("a batch can hold at most %d writes, not %d", bulkBatchSize, len(writes))
+	}
+
+	// write batches are deprecated in favor of transactions, but they commit in one request, with nothing to retry
+	b := f.client.Batch()
+	for _, w := range writes {
+		c, err := f.writeCall(w)
+		if err != nil {
+			return fmt.Errorf("%s: %s", w.Path, err)
+		}
+
+		switch c.mode {
+		case WriteModeCreate:
+			b.Create(c.ref, c.data)
+		case WriteModeSet:
+			b.Set(c.ref, c.data, c.options...)
+		case WriteModeUpdate:
+			b.Update(c.ref, c.updates)
+		default:
+			b.Delete(c.ref)
+		}
+	}
+
+	if _, err := b.Commit(f.ctx); err != nil {
+		return fmt.Errorf("error committing batch, %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package client
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"reflect"
+	"time"
+)
+
+const (
+	watchMinBackoff = time.Second
+	watchMaxBackoff = 30 * time.Second
+)
+
+type ChangeType string
+
+const (
+	ChangeAdded    ChangeType = "added"
+	ChangeModified ChangeType = "modified"
+	ChangeRemoved  ChangeType = "removed"
+)
+
+// Change is a single document change seen by Watch. Before is nil for added documents and After is nil for removed
+// ones.
+type Change struct {
+	Type   ChangeType
+	Path   string
+	Time   time.Time
+	Before map[string]any
+	After  map[string]any
+}
+
+// Watch listens to a document, or the documents of a collection matching the input filter, and calls visit for every
+// change until ctx is cancelled. The current state is reported first, as added documents. The listener reconnects
+// after transient errors; on reconnect, only documents that changed while disconnected are reported.
+func (f *firestoreClientManager) Watch(ctx context.Context, input query.Input, visit func(change Change) error) error {
+	w := &watcher{f: f, input: input, visit: visit, documents: make(map[string]map[string]any)}
+
+	listen := w.listenQuery
+	if !input.CollectionGroup && f.IsPathToDocument(input.Path) {
+		listen = w.listenDocument
+	} else if _, err := f.query(input); err != nil {
+		return err
+	}
+
+	backoff := watchMinBackoff
+	for {
+		connected, err := listen(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if !isTransient(err) {
+			return err
+		}
+
+		if connected {
+			backoff = watchMinBackoff
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, watchMaxBackoff)
+	}
+}
+
+type watcher struct {
+	f         *firestoreClientManager
+	input     query.Input
+	visit     func(change Change) error
+	documents map[string]map[string]any
+}
+
+// listenDocument runs a single document listener until it fails, reporting whether a snapshot was received.
+func (w *watcher) listenDocument(ctx context.Context) (bool, error) {
+	dr := w.f.client.Doc(w.input.Path)
+	if dr == nil {
+		return false, fmt.Errorf("invalid document path, %s", w.input.Path)
+	}
+
+	iter := dr.Snapshots(ctx)
+	defer iter.Stop()
+
+	connected := false
+	for {
+		ds, err := iter.Next()
+		if err != nil {
+			return connected, fmt.Errorf("error watching documents, %w", err)
+		}
+		connected = true
+
+		var after map[string]any
+		if ds.Exists() {
+			after = w.f.document(ds, w.input.Fields)
+		}
+		if err = w.apply(w.input.Path, ds.ReadTime, after); err != nil {
+			return connected, err
+		}
+	}
+}
+
+// listenQuery runs a single query listener until it fails, reporting whether a snapshot was received.
+func (w *watcher) listenQuery(ctx context.Context) (bool, error) {
+	q, err := w.f.query(w.input)
+	if err != nil {
+		return false, err
+	}
+
+	iter := q.Snapshots(ctx)
+	defer iter.Stop()
+
+	connected := false
+	for {
+		qs, err := iter.Next()
+		if err != nil {
+			return connected, fmt.Errorf("error watching documents, %w", err)
+		}
+
+		// the first snapshot of a connection lists every matching document as added, so compare it with what was
+		// seen before the connection was lost, and report anything missing from it as removed
+		seen := make(map[string]bool)
+		for _, c := range qs.Changes {
+			path := codec.Path(c.Doc.Ref)
+			seen[path] = true
+
+			var after map[string]any
+			if c.Kind != firestore.DocumentRemoved {
+				after = w.f.document(c.Doc, w.input.Fields)
+			}
+			if err = w.apply(path, qs.ReadTime, after); err != nil {
+				return connected, err
+			}
+		}
+
+		if !connected {
+			for path := range w.documents {
+				if seen[path] {
+					continue
+				}
+				if err = w.apply(path, qs.ReadTime, nil); err != nil {
+					return connected, err
+				}
+			}
+		}
+		connected = true
+	}
+}
+
+// apply records the new state of a document and reports the change, if there is one.
+func (w *watcher) apply(path string, readTime time.Time, after map[string]any) error {
+	before, existed := w.documents[path]
+
+	change := Change{Path: path, Time: readTime, Before: before, After: after}
+	switch {
+	case after == nil && !existed:
+		return nil
+	case after == nil:
+		change.Type = ChangeRemoved
+		delete(w.documents, path)
+	case !existed:
+		change.Type = ChangeAdded
+		w.documents[path] = after
+	case reflect.DeepEqual(before, after):
+		return nil
+	default:
+		change.Type = ChangeModified
+		w.documents[path] = after
+	}
+
+	return w.visit(change)
+}
+
+// isTransient reports whether a listener error is worth reconnecting after.
+func isTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Internal, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
@@ -1,16 +1,85 @@
 package config
 
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"slices"
+)
+
 type Config struct {
-	ServiceAccount string       `yaml:"service-account"`
-	ProjectID      string       `yaml:"project-id"`
-	PrettyPrint    bool         `yaml:"pretty-print"`
-	RawPrint       bool         `yaml:"raw"`
-	PrettySpacing  int          `yaml:"spacing"`
-	Backup         BackupConfig `yaml:"backup"`
-	Flatten        bool         `yaml:"flatten"`
+	ServiceAccount  string               `yaml:"service-account"`
+	ProjectID       string               `yaml:"project-id"`
+	DatabaseID      string               `yaml:"database-id"`
+	AccessTokenFile string               `yaml:"access-token-file"`
+	EmulatorHost    string               `yaml:"emulator-host"`
+	PrettyPrint     bool                 `yaml:"pretty-print"`
+	RawPrint        bool                 `yaml:"raw"`
+	PrettySpacing   int                  `yaml:"spacing"`
+	Format          string               `yaml:"format"`
+	Backup          BackupConfig         `yaml:"backup"`
+	Flatten         bool                 `yaml:"flatten"`
+	DefaultProfile  string               `yaml:"default-profile"`
+	Profiles        map[string]yaml.Node `yaml:"profiles"`
+	Profile         string               `yaml:"-"`
+	EmulatorFromEnv bool                 `yaml:"-"`
+
+	base *Config
 }
 
 type BackupConfig struct {
+	Sink       string   `yaml:"sink"`
 	Collection string   `yaml:"collection"`
+	Directory  string   `yaml:"directory"`
+	MaxSizeMB  int      `yaml:"max-size-mb"`
+	MaxFiles   int      `yaml:"max-files"`
+	Profile    string   `yaml:"profile"`
+	ProjectID  string   `yaml:"project-id"`
+	DatabaseID string   `yaml:"database-id"`
 	Commands   []string `yaml:"commands"`
 }
+
+// WithProfile returns a copy of the config with the keys of the named profile applied on top of it. A profile may
+// contain any top-level config key; keys it doesn't mention keep their top-level values.
+func (c Config) WithProfile(name string) (Config, error) {
+	base := c
+	if c.base != nil {
+		base = *c.base
+	}
+
+	node, ok := base.Profiles[name]
+	if !ok {
+		return c, fmt.Errorf("unknown profile %s", name)
+	}
+
+	profiled := base
+	if err := node.Decode(&profiled); err != nil {
+		return c, fmt.Errorf("error reading profile %s, %s", name, err)
+	}
+
+	profiled.DefaultProfile = base.DefaultProfile
+	profiled.Profiles = base.Profiles
+	profiled.Profile = name
+	profiled.base = &base
+
+	return profiled, nil
+}
+
+// Derived returns a copy of the config to build the config of another store from, such as the destination of a
+// transfer. An emulator host taken from the environment only applies to the command's own store, so it is dropped.
+func (c Config) Derived() Config {
+	if c.EmulatorFromEnv {
+		c.EmulatorHost = ""
+		c.EmulatorFromEnv = false
+	}
+	return c
+}
+
+// ProfileNames returns the names of all configured profiles, sorted.
+func (c Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
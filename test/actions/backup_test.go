@@ -0,0 +1,99 @@
+package actions
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"jhight.com/firestore-cli/pkg/api/actions"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"jhight.com/firestore-cli/pkg/config"
+	"testing"
+	"time"
+)
+
+func TestBackupListAction(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	created := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	records := []map[string]any{
+		{"$id": "1717243200000", "created_at": created, "path": "users/user-1", "project": "p", "database": "(default)", "before": map[string]any{"age": int64(30)}, "after": nil},
+		{"$id": "1717243100000", "created_at": created.Add(-time.Minute), "path": "users/user-2", "project": "p", "database": "(default)", "before": nil, "after": map[string]any{"age": int64(40)}},
+	}
+
+	input := query.Input{Path: "backup", OrderBy: []query.OrderBy{{Field: "__name__", Direction: query.Descending}}}
+	mockStore.EXPECT().Export(input, false, gomock.Any()).DoAndReturn(func(_ query.Input, _ bool, visit func(map[string]any) error) error {
+		for _, r := range records {
+			if err := visit(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Backup(root))
+	root.SetArgs([]string{"backup", "list", "--limit", "1"})
+
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Equal(t, `[{"change":"delete","created_at":{"$timestamp":"2024-06-01T12:00:00Z"},"database":"(default)","id":"1717243200000","path":"users/user-1","project":"p"}]
+`, output)
+}
+
+func TestRestoreAction(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	before := map[string]any{"name": "John", "age": int64(30), "note": "$delete()"}
+	after := map[string]any{"name": "John", "age": int64(31)}
+
+	mockStore.EXPECT().Get(query.Input{Path: "backup/1717243200000"}).Return(map[string]any{
+		"path": "users/user-1", "project": "p", "database": "(default)", "before": before, "after": after,
+	}, nil)
+	mockStore.EXPECT().IsPathToCollection("users/user-1").Return(false)
+	mockStore.EXPECT().Get(query.Input{Path: "users/user-1"}).Return(after, nil)
+
+	// the document is written raw, so the string that looks like a function is restored as a string
+	written := make([]client.Write, 0)
+	mockStore.EXPECT().BulkWrite(gomock.Any(), gomock.Any()).DoAndReturn(func(next func() (client.Write, bool), done func(client.Write, error)) error {
+		for w, ok := next(); ok; w, ok = next() {
+			written = append(written, w)
+			done(w, nil)
+		}
+		return nil
+	})
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{ProjectID: "p"}, mockStore))
+	root.Add(actions.Restore(root))
+	root.SetArgs([]string{"restore", "1717243200000", "--yes"})
+
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Equal(t, `~ users/user-1
+    ~ age: 31 → 30
+    + note: "$$delete()"
+users/user-1 restored from backup 1717243200000
+`, output)
+	assert.Equal(t, []client.Write{{
+		Mode:   client.WriteModeSet,
+		Path:   "users/user-1",
+		Fields: map[string]any{"name": "John", "age": int64(30), "note": "$$delete()"},
+		Raw:    true,
+	}}, written)
+}
+
+func TestRestoreActionWithoutState(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	// deleting a collection backs up its path, which has no document to restore
+	mockStore.EXPECT().Get(query.Input{Path: "backup/1717243200000"}).Return(map[string]any{
+		"path": "users/user-1", "project": "p", "database": "(default)", "before": nil, "after": nil,
+	}, nil)
+	mockStore.EXPECT().IsPathToCollection("users/user-1").Return(false)
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{ProjectID: "p"}, mockStore))
+	root.Add(actions.Restore(root))
+	root.SetArgs([]string{"restore", "1717243200000", "--yes"})
+
+	assert.EqualError(t, root.Execute(), "backup 1717243200000 holds no state of users/user-1, so it can't be restored")
+}
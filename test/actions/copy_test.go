@@ -0,0 +1,59 @@
+package actions
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"jhight.com/firestore-cli/pkg/api/actions"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/config"
+	"testing"
+)
+
+func TestMoveAction(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	mockStore.EXPECT().Copy("users", "archive/2024/users", true, client.ConflictOverwrite, gomock.Any()).
+		DoAndReturn(func(_ string, _ string, _ bool, _ client.ConflictPolicy, done func(client.Write, error)) error {
+			done(client.Write{Mode: client.WriteModeSet, Path: "archive/2024/users/1"}, nil)
+			done(client.Write{Mode: client.WriteModeSet, Path: "archive/2024/users/1/orders/2"}, nil)
+			return nil
+		})
+
+	deleted := make([]client.Write, 0)
+	mockStore.EXPECT().BulkWrite(gomock.Any(), gomock.Any()).DoAndReturn(func(next func() (client.Write, bool), done func(client.Write, error)) error {
+		for w, ok := next(); ok; w, ok = next() {
+			deleted = append(deleted, w)
+			done(w, nil)
+		}
+		return nil
+	})
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Move(root))
+	root.SetArgs([]string{"move", "users", "archive/2024/users/", "-r", "--conflict", "overwrite"})
+
+	assert.Nil(t, root.Execute())
+	assert.Equal(t, []client.Write{
+		{Mode: client.WriteModeDelete, Path: "users/1"},
+		{Mode: client.WriteModeDelete, Path: "users/1/orders/2"},
+	}, deleted)
+}
+
+func TestMoveActionKeepsSourceAfterSkip(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	mockStore.EXPECT().Copy("users/1", "users/2", false, client.ConflictSkip, gomock.Any()).
+		DoAndReturn(func(_ string, _ string, _ bool, _ client.ConflictPolicy, done func(client.Write, error)) error {
+			done(client.Write{Mode: client.WriteModeCreate, Path: "users/2"}, client.ErrExists)
+			return nil
+		})
+	mockStore.EXPECT().BulkWrite(gomock.Any(), gomock.Any()).Times(0)
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Move(root))
+	root.SetArgs([]string{"move", "users/1", "users/2", "--conflict", "skip"})
+
+	assert.NotNil(t, root.Execute())
+}
@@ -0,0 +1,317 @@
+package actions
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"jhight.com/firestore-cli/pkg/api/actions"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/backup"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"jhight.com/firestore-cli/pkg/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// expectMatches expects a command to count and read the documents at paths matching input, then write each of them.
+// It returns the writes made.
+func expectMatches(mockStore *client.MockStore, input query.Input, recursive bool, paths ...string) *[]client.Write {
+	expectCount(mockStore, input, len(paths))
+	expectExport(mockStore, input, recursive, paths...)
+	return expectWrites(mockStore, nil)
+}
+
+// expectCount expects a command to count the documents matching input.
+func expectCount(mockStore *client.MockStore, input query.Input, count int) {
+	counted := input
+	counted.Count = true
+
+	mockStore.EXPECT().IsPathToCollection(input.Path).Return(true)
+	mockStore.EXPECT().Aggregate(counted).Return(map[string]any{"$count": int64(count)}, nil)
+}
+
+// expectExport expects a command to read the documents at paths matching input.
+func expectExport(mockStore *client.MockStore, input query.Input, recursive bool, paths ...string) {
+	mockStore.EXPECT().Export(input, recursive, gomock.Any()).DoAndReturn(func(_ query.Input, _ bool, visit func(map[string]any) error) error {
+		for _, path := range paths {
+			if err := visit(map[string]any{"$path": path, "active": false, "nickname": "J"}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// expectWrites expects a command to write documents through the bulk writer, failing the writes to the paths in
+// failures. It returns the writes made.
+func expectWrites(mockStore *client.MockStore, failures map[string]error) *[]client.Write {
+	written := make([]client.Write, 0)
+	mockStore.EXPECT().BulkWrite(gomock.Any(), gomock.Any()).DoAndReturn(func(next func() (client.Write, bool), done func(client.Write, error)) error {
+		for w, ok := next(); ok; w, ok = next() {
+			written = append(written, w)
+			done(w, failures[w.Path])
+		}
+		return nil
+	})
+	return &written
+}
+
+func TestDeleteActionWithFilter(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	input := query.Input{Path: "users", Filter: map[string]any{"active": false}, Limit: 2}
+	written := expectMatches(mockStore, input, true, "users/user-1", "users/user-1/projects/project-1", "users/user-2")
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Delete(root))
+	root.SetArgs([]string{"delete", "users", "--filter", `{"active":false}`, "--limit", "2", "--subcollections", "--yes"})
+
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Equal(t, "3 documents deleted, 0 failed\n", output)
+	assert.Equal(t, []client.Write{
+		{Mode: client.WriteModeDelete, Path: "users/user-1"},
+		{Mode: client.WriteModeDelete, Path: "users/user-1/projects/project-1"},
+		{Mode: client.WriteModeDelete, Path: "users/user-2"},
+	}, *written)
+}
+
+func TestDeleteActionFieldsWithFilter(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	input := query.Input{Path: "users", Filter: map[string]any{"active": false}}
+	written := expectMatches(mockStore, input, false, "users/user-1", "users/user-2")
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Delete(root))
+	root.SetArgs([]string{"delete", "users", "--filter", `{"active":false}`, "nickname,active", "--yes"})
+
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Equal(t, "2 documents updated, 0 failed\n", output)
+
+	fields := map[string]any{"nickname": "$delete()", "active": "$delete()"}
+	assert.Equal(t, []client.Write{
+		{Mode: client.WriteModeUpdate, Path: "users/user-1", Fields: fields},
+		{Mode: client.WriteModeUpdate, Path: "users/user-2", Fields: fields},
+	}, *written)
+}
+
+func TestDeleteActionFieldsNeedFilter(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	mockStore.EXPECT().IsPathToCollection("users").Return(true)
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Delete(root))
+	root.SetArgs([]string{"delete", "users", "nickname", "--yes"})
+	assert.EqualError(t, root.Execute(), "users is a collection; use --filter or --limit to choose documents, or --all to delete fields from every document in it")
+
+	// --all deletes the fields from every document
+	written := expectMatches(mockStore, query.Input{Path: "users"}, false, "users/user-1", "users/user-2")
+	root.SetArgs([]string{"delete", "users", "nickname", "--all", "--yes"})
+
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Equal(t, "2 documents updated, 0 failed\n", output)
+
+	fields := map[string]any{"nickname": "$delete()"}
+	assert.Equal(t, []client.Write{
+		{Mode: client.WriteModeUpdate, Path: "users/user-1", Fields: fields},
+		{Mode: client.WriteModeUpdate, Path: "users/user-2", Fields: fields},
+	}, *written)
+}
+
+func TestDeleteCollectionResumesFromCheckpoint(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	checkpoints := filepath.Join(home, ".firestore-cli", "checkpoints")
+
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	mockStore.EXPECT().IsPathToCollection("users").Return(true).Times(3)
+	mockStore.EXPECT().Delete("users", "", gomock.Any(), gomock.Any()).DoAndReturn(func(_ string, _ string, done func(client.Write, error), checkpoint func(string)) error {
+		done(client.Write{Mode: client.WriteModeDelete, Path: "users/user-1"}, nil)
+		checkpoint("user-1")
+		done(client.Write{Mode: client.WriteModeDelete, Path: "users/user-2"}, errors.New("unavailable"))
+		return nil
+	})
+	mockStore.EXPECT().Delete("users", "user-1", gomock.Any(), gomock.Any()).DoAndReturn(func(_ string, _ string, done func(client.Write, error), checkpoint func(string)) error {
+		done(client.Write{Mode: client.WriteModeDelete, Path: "users/user-2"}, nil)
+		checkpoint("user-2")
+		done(client.Write{Mode: client.WriteModeDelete, Path: "users/user-3"}, errors.New("unavailable"))
+		return nil
+	})
+	mockStore.EXPECT().Delete("users", "user-2", gomock.Any(), gomock.Any()).DoAndReturn(func(_ string, _ string, done func(client.Write, error), checkpoint func(string)) error {
+		done(client.Write{Mode: client.WriteModeDelete, Path: "users/user-3"}, nil)
+		checkpoint("user-3")
+		return nil
+	})
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Delete(root))
+
+	root.SetArgs([]string{"delete", "users", "--yes"})
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Regexp(t, `^users partially deleted: 1 documents deleted, 1 failed in .+; run the delete again to retry\n$`, output)
+
+	entries, err := os.ReadDir(checkpoints)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+
+	// the second delete picks up after user-1, and counts it as deleted even though it fails again
+	root.SetArgs([]string{"delete", "users", "--yes"})
+	output = captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Regexp(t, `^users partially deleted: 2 documents deleted, 1 failed in .+; run the delete again to retry\n$`, output)
+
+	// the third picks up after user-2
+	root.SetArgs([]string{"delete", "users", "--yes"})
+	output = captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Regexp(t, `^users successfully deleted: 3 documents in .+\n$`, output)
+
+	entries, err = os.ReadDir(checkpoints)
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDeleteActionWithFilterBacksUp(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	input := query.Input{Path: "users", Filter: map[string]any{"active": false}}
+	expectMatches(mockStore, input, false, "users/user-1", "users/user-2", "users/user-3")
+
+	dir := t.TempDir()
+	cfg := config.Config{ProjectID: "test", Backup: config.BackupConfig{Sink: backup.SinkDirectory, Directory: dir, Commands: []string{"delete"}}}
+	root := actions.Root(actions.DefaultsInitializer(cfg, mockStore))
+	root.Add(actions.Delete(root))
+	root.SetArgs([]string{"delete", "users", "--filter", `{"active":false}`, "--yes"})
+
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Equal(t, "3 documents deleted, 0 failed\n", output)
+
+	paths := make([]string, 0)
+	sink := backup.NewDirectorySink(dir, func(v any) (any, error) { return v, nil })
+	assert.Nil(t, sink.List(backup.Filter{}, func(r backup.Record) error {
+		paths = append(paths, r.Path)
+		assert.Equal(t, map[string]any{"active": false, "nickname": "J"}, r.Before)
+		assert.Nil(t, r.After)
+		return nil
+	}))
+	assert.ElementsMatch(t, []string{"users/user-1", "users/user-2", "users/user-3"}, paths)
+}
+
+func TestDeleteCollectionSkipsEmptyBackup(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	mockStore.EXPECT().IsPathToCollection("users").Return(true).AnyTimes()
+	mockStore.EXPECT().Get(query.Input{Path: "users"}).Return(nil, errors.New("invalid document path, users"))
+	mockStore.EXPECT().Delete("users", "", gomock.Any(), gomock.Any()).Return(nil)
+
+	dir := t.TempDir()
+	cfg := config.Config{ProjectID: "test", Backup: config.BackupConfig{Sink: backup.SinkDirectory, Directory: dir, Commands: []string{"delete"}}}
+	root := actions.Root(actions.DefaultsInitializer(cfg, mockStore))
+	root.Add(actions.Delete(root))
+	root.SetArgs([]string{"delete", "users", "--yes"})
+	captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+
+	// a collection has no state of its own, so no record is kept that would read as creating it
+	records := 0
+	sink := backup.NewDirectorySink(dir, func(v any) (any, error) { return v, nil })
+	assert.Nil(t, sink.List(backup.Filter{}, func(backup.Record) error {
+		records++
+		return nil
+	}))
+	assert.Zero(t, records)
+}
+
+func TestDeleteCollectionAsksBeforeResuming(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	checkpoints := filepath.Join(home, ".firestore-cli", "checkpoints")
+
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	interrupted := func(_ string, _ string, done func(client.Write, error), checkpoint func(string)) error {
+		done(client.Write{Mode: client.WriteModeDelete, Path: "users/user-1"}, nil)
+		checkpoint("user-1")
+		done(client.Write{Mode: client.WriteModeDelete, Path: "users/user-2"}, errors.New("unavailable"))
+		return nil
+	}
+
+	mockStore.EXPECT().IsPathToCollection("users").Return(true).AnyTimes()
+	gomock.InOrder(
+		mockStore.EXPECT().Delete("users", "", gomock.Any(), gomock.Any()).DoAndReturn(interrupted),
+		// declining to resume starts over
+		mockStore.EXPECT().Delete("users", "", gomock.Any(), gomock.Any()).DoAndReturn(interrupted),
+		// a checkpoint more than a day old is ignored, even with --yes
+		mockStore.EXPECT().Delete("users", "", gomock.Any(), gomock.Any()).Return(nil),
+	)
+
+	// flags keep their values between runs of a command, so each run gets its own
+	run := func(answers string, args ...string) string {
+		root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+		root.Add(actions.Delete(root))
+		root.Command().SetIn(strings.NewReader(answers))
+		root.SetArgs(args)
+		return captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	}
+
+	run("", "delete", "users", "--yes")
+
+	output := run("y\nn\n", "delete", "users")
+	assert.Regexp(t, `Resume the delete of users after user-1, where an earlier delete left off .+ ago\? \(y/N\): `, output)
+
+	entries, err := os.ReadDir(checkpoints)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+
+	file := filepath.Join(checkpoints, entries[0].Name())
+	data, err := os.ReadFile(file)
+	assert.Nil(t, err)
+	var stale map[string]any
+	assert.Nil(t, json.Unmarshal(data, &stale))
+	stale["updatedAt"] = time.Now().Add(-25 * time.Hour)
+	data, err = json.Marshal(stale)
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(file, data, 0600))
+
+	assert.Regexp(t, `^users successfully deleted: 0 documents in .+\n$`, run("", "delete", "users", "--yes"))
+}
+
+func TestDeleteCollectionNeedsYesWithPipedInput(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Delete(root))
+	root.SetArgs([]string{"delete", "users"})
+
+	// piped input isn't read as the answer, so nothing is deleted without --yes
+	withStdin(t, "y\n", func() {
+		assert.EqualError(t, root.Execute(), `can't ask "Delete collection users?", because stdin isn't a terminal; use --yes to go ahead`)
+	})
+}
+
+func withStdin(t *testing.T, input string, f func()) {
+	r, w, err := os.Pipe()
+	assert.Nil(t, err)
+	_, err = w.WriteString(input)
+	assert.Nil(t, err)
+	_ = w.Close()
+
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+
+	f()
+}
@@ -0,0 +1,128 @@
+package actions
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"jhight.com/firestore-cli/pkg/api/actions"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"jhight.com/firestore-cli/pkg/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiffAction(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	file := filepath.Join(t.TempDir(), "user.json")
+	assert.Nil(t, os.WriteFile(file, []byte(`{"name":"John","age":31,"score":10.0,"tags":["a","b"]}`), 0600))
+
+	mockStore.EXPECT().IsPathToCollection("users/user-1").Return(false).AnyTimes()
+	mockStore.EXPECT().Get(query.Input{Path: "users/user-1"}).Return(map[string]any{"name": "John", "age": int64(30), "score": int64(10), "tags": []any{"a"}}, nil).AnyTimes()
+	mockStore.EXPECT().Decode(gomock.Any()).DoAndReturn(func(value any) (any, error) {
+		return codec.Decode(value, nil)
+	}).AnyTimes()
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Diff(root))
+	root.SetArgs([]string{"diff", "users/user-1", file})
+
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Equal(t, `~ age: 30 → 31
+~ score: 10 → 10.0
++ tags[1]: "b"
+`, output)
+
+	root.SetArgs([]string{"diff", "users/user-1", file, "--patch"})
+	output = captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Equal(t, `[{"op":"replace","path":"/age","value":31},{"op":"replace","path":"/score","value":10.0},{"op":"add","path":"/tags/1","value":"b"}]
+`, output)
+}
+
+func TestDiffActionPatchFunctionLikeStrings(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	// $$ in a file is a literal $, so the note holds the string $delete()
+	file := filepath.Join(t.TempDir(), "user.json")
+	assert.Nil(t, os.WriteFile(file, []byte(`{"name":"John","note":"$$delete()"}`), 0600))
+
+	mockStore.EXPECT().IsPathToCollection("users/user-1").Return(false).AnyTimes()
+	mockStore.EXPECT().Get(query.Input{Path: "users/user-1"}).Return(map[string]any{"name": "John"}, nil)
+	mockStore.EXPECT().Decode(gomock.Any()).DoAndReturn(func(value any) (any, error) {
+		return codec.Decode(value, nil)
+	}).AnyTimes()
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Diff(root))
+	root.SetArgs([]string{"diff", "users/user-1", file, "--patch"})
+
+	// escaped once, so applying the patch writes the string back
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Equal(t, `[{"op":"add","path":"/note","value":"$$delete()"}]
+`, output)
+}
+
+func TestDiffActionCollectionsNamedLikeFiles(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	// a bare name that isn't a file is a collection ID, even with a JSON extension
+	exports := func(path string, records ...map[string]any) {
+		mockStore.EXPECT().Export(query.Input{Path: path}, false, gomock.Any()).DoAndReturn(func(_ query.Input, _ bool, visit func(map[string]any) error) error {
+			for _, r := range records {
+				if err := visit(r); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).AnyTimes()
+	}
+	mockStore.EXPECT().IsPathToCollection("config.json").Return(true).AnyTimes()
+	mockStore.EXPECT().IsPathToCollection("config.ndjson").Return(true).AnyTimes()
+	exports("config.json", map[string]any{"$id": "a", "$path": "config.json/a", "on": true})
+	exports("config.ndjson", map[string]any{"$id": "a", "$path": "config.ndjson/a", "on": false})
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Diff(root))
+	root.SetArgs([]string{"diff", "config.json", "config.ndjson"})
+
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Equal(t, "~ config.json/a\n    ~ on: true → false\n", output)
+
+	// a path to a file that doesn't exist is still taken as a file
+	root.SetArgs([]string{"diff", "config.json", filepath.Join(t.TempDir(), "missing.json")})
+	captureStdout(t, func() { assert.ErrorContains(t, root.Execute(), "missing.json") })
+}
+
+func TestDiffActionFileNamedLikeTag(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	dir := t.TempDir()
+	tagged := filepath.Join(dir, "tagged.json")
+	assert.Nil(t, os.WriteFile(tagged, []byte(`{"$timestamp":"2024-06-01T12:00:00Z"}`), 0600))
+	escaped := filepath.Join(dir, "escaped.json")
+	assert.Nil(t, os.WriteFile(escaped, []byte(`{"$$timestamp":"later"}`), 0600))
+
+	mockStore.EXPECT().IsPathToCollection("events/1").Return(false).AnyTimes()
+	mockStore.EXPECT().Get(query.Input{Path: "events/1"}).Return(map[string]any{"$timestamp": "now"}, nil).AnyTimes()
+	mockStore.EXPECT().Decode(gomock.Any()).DoAndReturn(func(value any) (any, error) {
+		return codec.Decode(value, nil)
+	}).AnyTimes()
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Diff(root))
+
+	// a file holding a single tagged value isn't a document, rather than an empty one
+	root.SetArgs([]string{"diff", "events/1", tagged})
+	assert.EqualError(t, root.Execute(), tagged+" holds a single tagged value rather than a JSON object; put another $ in front of a field named like a tag")
+
+	// escaped, it's a document with a field named like the tag
+	root.SetArgs([]string{"diff", "events/1", escaped})
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Equal(t, "~ $timestamp: \"now\" → \"later\"\n", output)
+}
@@ -0,0 +1,62 @@
+package actions
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"jhight.com/firestore-cli/pkg/api/actions"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"jhight.com/firestore-cli/pkg/config"
+	"testing"
+)
+
+func TestDryRunUpdate(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	mockStore.EXPECT().IsPathToCollection("users/user-1").Return(false)
+
+	w := client.Write{Mode: client.WriteModeUpdate, Path: "users/user-1", Fields: map[string]any{"age": int64(31)}}
+	mockStore.EXPECT().Preview(gomock.Any()).DoAndReturn(func(got client.Write) (client.Plan, error) {
+		assert.Equal(t, w.Mode, got.Mode)
+		assert.Equal(t, w.Path, got.Path)
+		return client.Plan{Write: got, Before: map[string]any{"name": "John", "age": int64(30)}, After: map[string]any{"name": "John", "age": int64(31)}}, nil
+	})
+
+	// Update isn't expected, so the mock fails the test if it's called
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Update(root))
+	root.SetArgs([]string{"update", "users/user-1", `{"age":31}`, "--dry-run"})
+
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Equal(t, `~ users/user-1
+    ~ age: 30 → 31
+`, output)
+}
+
+func TestDryRunDeleteCollection(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	mockStore.EXPECT().Export(query.Input{Path: "users"}, true, gomock.Any()).DoAndReturn(func(_ query.Input, _ bool, visit func(map[string]any) error) error {
+		for _, path := range []string{"users/user-1", "users/user-1/orders/order-1", "users/user-1/orders/order-2", "users/user-2"} {
+			if err := visit(map[string]any{"$path": path, "name": "x"}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Delete(root))
+	root.SetArgs([]string{"delete", "users", "--dry-run"})
+
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Equal(t, `- users (collection)
+- users/user-1
+- users/user-1/orders (collection)
+- users/user-1/orders/order-1
+- users/user-1/orders/order-2
+- users/user-2
+`, output)
+}
@@ -1,12 +1,16 @@
 package actions
 
 import (
+	"encoding/json"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
+	"io"
 	"jhight.com/firestore-cli/pkg/api/actions"
 	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/query"
 	"jhight.com/firestore-cli/pkg/config"
+	"os"
 	"testing"
 )
 
@@ -24,3 +28,93 @@ func TestGetAction(t *testing.T) {
 	err := root.Execute()
 	assert.Nil(t, err)
 }
+
+func TestGetActionAggregates(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Get(root))
+	root.SetArgs([]string{"get", "orders", "--count", "--sum", "price, tax"})
+
+	mockStore.EXPECT().IsPathToCollection("orders").Return(true)
+	mockStore.EXPECT().Aggregate(query.Input{
+		Path:   "orders",
+		Fields: []string{},
+		Count:  true,
+		Sum:    []string{"price", "tax"},
+	}).Return(map[string]any{"$count": int64(2)}, nil)
+
+	err := root.Execute()
+	assert.Nil(t, err)
+}
+
+func TestGetActionAggregatesOfDocument(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Get(root))
+	root.SetArgs([]string{"get", "orders/1", "--count"})
+
+	mockStore.EXPECT().IsPathToCollection("orders/1").Return(false).AnyTimes()
+	mockStore.EXPECT().IsPathToDocument("orders/1").Return(true)
+
+	// nothing is read, rather than printing the document as if the flag weren't given
+	err := root.Execute()
+	assert.EqualError(t, err, "--count, --sum and --avg aggregate the documents of a collection, and orders/1 is a document")
+}
+
+func TestGetActionStreamsDocuments(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	docs := []map[string]any{{"name": "John", "age": int64(30)}, {}, {"name": "Jane", "tags": []any{"a"}}}
+
+	mockStore.EXPECT().IsPathToCollection("users").Return(true).AnyTimes()
+	mockStore.EXPECT().Query(gomock.Any(), gomock.Any()).DoAndReturn(func(_ query.Input, visit func(map[string]any) error) error {
+		for _, doc := range docs {
+			if err := visit(doc); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Times(2)
+
+	// the streamed array matches what marshalling the whole array at once produces, minus empty documents
+	expected, _ := json.MarshalIndent([]map[string]any{docs[0], docs[2]}, "", "  ")
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{PrettyPrint: true, PrettySpacing: 2}, mockStore))
+	root.Add(actions.Get(root))
+	root.SetArgs([]string{"get", "users"})
+	assert.Equal(t, string(expected)+"\n", captureStdout(t, func() { assert.Nil(t, root.Execute()) }))
+
+	root = actions.Root(actions.DefaultsInitializer(config.Config{Format: "ndjson"}, mockStore))
+	root.Add(actions.Get(root))
+	root.SetArgs([]string{"get", "users"})
+	assert.Equal(t, "{\"age\":30,\"name\":\"John\"}\n{\"name\":\"Jane\",\"tags\":[\"a\"]}\n", captureStdout(t, func() { assert.Nil(t, root.Execute()) }))
+}
+
+func captureStdout(t *testing.T, f func()) string {
+	return capture(t, &os.Stdout, f)
+}
+
+func captureStderr(t *testing.T, f func()) string {
+	return capture(t, &os.Stderr, f)
+}
+
+func capture(t *testing.T, file **os.File, f func()) string {
+	r, w, err := os.Pipe()
+	assert.Nil(t, err)
+
+	original := *file
+	*file = w
+	defer func() { *file = original }()
+
+	f()
+
+	_ = w.Close()
+	out, err := io.ReadAll(r)
+	assert.Nil(t, err)
+	return string(out)
+}
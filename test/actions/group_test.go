@@ -0,0 +1,54 @@
+package actions
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"jhight.com/firestore-cli/pkg/api/actions"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"jhight.com/firestore-cli/pkg/config"
+	"testing"
+)
+
+func TestGetActionCollectionGroup(t *testing.T) {
+	input := query.Input{
+		Path:            "orders",
+		CollectionGroup: true,
+		Fields:          []string{query.SelectionDocumentPath, "price"},
+		Filter:          map[string]any{"price": map[string]any{">": float64(100)}},
+	}
+
+	// --group is a switch, and the fields are still the argument after the collection ID; **/ does the same
+	for _, args := range [][]string{
+		{"get", "orders", "$path,price", "--group", "--filter", `{"price":{">":100}}`},
+		{"get", "--group", "orders", "$path,price", "--filter", `{"price":{">":100}}`},
+		{"get", "**/orders", "$path,price", "--filter", `{"price":{">":100}}`},
+	} {
+		gc := gomock.NewController(t)
+		mockStore := client.NewMockStore(gc)
+		mockStore.EXPECT().Query(input, gomock.Any()).DoAndReturn(func(_ query.Input, visit func(map[string]any) error) error {
+			return visit(map[string]any{"$path": "users/1/orders/a", "price": int64(150)})
+		})
+
+		root := actions.Root(actions.DefaultsInitializer(config.Config{Format: "ndjson"}, mockStore))
+		root.Add(actions.Get(root))
+		root.SetArgs(args)
+
+		output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+		assert.Equal(t, "{\"$path\":\"users/1/orders/a\",\"price\":150}\n", output, args)
+	}
+}
+
+func TestGetActionCollectionGroupAggregates(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	mockStore.EXPECT().Aggregate(query.Input{Path: "orders", CollectionGroup: true, Fields: []string{}, Count: true}).Return(map[string]any{"$count": int64(3)}, nil)
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Get(root))
+	root.SetArgs([]string{"get", "orders", "--group", "--count"})
+
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Equal(t, "{\"$count\":3}\n", output)
+}
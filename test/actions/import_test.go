@@ -0,0 +1,102 @@
+package actions
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"jhight.com/firestore-cli/pkg/api/actions"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/config"
+	"jhight.com/firestore-cli/test/fake"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImportAction(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "users.ndjson")
+	err := os.WriteFile(input, []byte(`{"$path":"users/1","name":"John"}
+{"$id":"2","name":"Jane","$$id":"legacy-2"}
+not json
+{"name":"Nobody"}
+`), 0600)
+	assert.Nil(t, err)
+
+	written := make([]client.Write, 0)
+	mockStore.EXPECT().BulkWrite(gomock.Any(), gomock.Any()).DoAndReturn(func(next func() (client.Write, bool), done func(client.Write, error)) error {
+		for w, ok := next(); ok; w, ok = next() {
+			written = append(written, w)
+			done(w, nil)
+		}
+		return nil
+	})
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Import(root))
+	root.SetArgs([]string{"import", "users", "--input", input, "--mode", "merge"})
+
+	err = root.Execute()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []client.Write{
+		{Mode: client.WriteModeMerge, Path: "users/1", Fields: map[string]any{"name": "John"}},
+		{Mode: client.WriteModeMerge, Path: "users/2", Fields: map[string]any{"name": "Jane", "$id": "legacy-2"}},
+	}, written)
+
+	rejects, err := os.ReadFile(input + ".rejects")
+	assert.Nil(t, err)
+	assert.Equal(t, "not json\n{\"name\":\"Nobody\"}\n", string(rejects))
+}
+
+func TestImportActionUnreadableInput(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	// the array is cut off part way through its second record
+	input := filepath.Join(t.TempDir(), "users.json")
+	assert.Nil(t, os.WriteFile(input, []byte(`[{"$path":"users/1","name":"John"},{"$path":"us`), 0600))
+
+	written := make([]client.Write, 0)
+	mockStore.EXPECT().BulkWrite(gomock.Any(), gomock.Any()).DoAndReturn(func(next func() (client.Write, bool), done func(client.Write, error)) error {
+		for w, ok := next(); ok; w, ok = next() {
+			written = append(written, w)
+			done(w, nil)
+		}
+		return nil
+	})
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Import(root))
+	root.SetArgs([]string{"import", "--input", input})
+
+	var err error
+	output := captureStdout(t, func() { err = root.Execute() })
+	assert.EqualError(t, err, "import stopped at record 2, error reading JSON array, unexpected EOF")
+	assert.Equal(t, "1 documents written, 1 failed\n", output)
+	assert.Equal(t, []client.Write{{Mode: client.WriteModeSet, Path: "users/1", Fields: map[string]any{"name": "John"}}}, written)
+}
+
+func TestImportActionFieldsNamedLikeTags(t *testing.T) {
+	server := fake.NewServer(t)
+	store, err := client.New(context.Background(), config.Config{EmulatorHost: server.Addr, ProjectID: fake.ProjectID})
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	// the only field of the record is named like a tag
+	input := filepath.Join(t.TempDir(), "users.ndjson")
+	assert.Nil(t, os.WriteFile(input, []byte(`{"$path":"users/1","$ref":"users/2"}
+`), 0600))
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, store))
+	root.Add(actions.Import(root))
+	root.SetArgs([]string{"import", "--input", input})
+
+	output := captureStdout(t, func() { err = root.Execute() })
+	assert.Nil(t, err)
+	assert.Equal(t, "1 documents written, 0 failed\n", output)
+	assert.Equal(t, map[string]any{"$ref": "users/2"}, server.Document("users/1"))
+}
@@ -0,0 +1,82 @@
+package actions
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"jhight.com/firestore-cli/pkg/api/actions"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"jhight.com/firestore-cli/pkg/config"
+	"testing"
+)
+
+func TestGetActionCursors(t *testing.T) {
+	tests := []struct {
+		flag   string
+		value  string
+		cursor query.Cursor
+	}{
+		{"--start-at", `100`, query.Cursor{Values: []any{json.Number("100")}}},
+		{"--start-after", `[100,"Chicago"]`, query.Cursor{Values: []any{json.Number("100"), "Chicago"}}},
+		{"--end-at", `"Chicago"`, query.Cursor{Values: []any{"Chicago"}}},
+		{"--end-before", `Chicago`, query.Cursor{Values: []any{"Chicago"}}},
+		{"--start-after", `users/1234`, query.Cursor{DocumentPath: "users/1234"}},
+		{"--start-at", `users/1234/orders`, query.Cursor{Values: []any{"users/1234/orders"}}},
+	}
+
+	for _, test := range tests {
+		gc := gomock.NewController(t)
+		mockStore := client.NewMockStore(gc)
+
+		var input query.Input
+		mockStore.EXPECT().IsPathToCollection("users").Return(true).AnyTimes()
+		mockStore.EXPECT().Query(gomock.Any(), gomock.Any()).DoAndReturn(func(i query.Input, _ func(map[string]any) error) error {
+			input = i
+			return nil
+		})
+
+		root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+		root.Add(actions.Get(root))
+		root.SetArgs([]string{"get", "users", "--order", "age", test.flag, test.value})
+
+		captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+
+		cursors := map[string]*query.Cursor{
+			"--start-at":    input.StartAt,
+			"--start-after": input.StartAfter,
+			"--end-at":      input.EndAt,
+			"--end-before":  input.EndBefore,
+		}
+		for flag, cursor := range cursors {
+			if flag == test.flag {
+				assert.Equal(t, &test.cursor, cursor, test.value)
+			} else {
+				assert.Nil(t, cursor, flag)
+			}
+		}
+	}
+}
+
+func TestGetActionPageToken(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	mockStore.EXPECT().IsPathToCollection("users").Return(true).AnyTimes()
+	mockStore.EXPECT().QueryPage(query.Input{Path: "users", Fields: []string{}, Limit: 2, PageToken: "abc"}).
+		Return([]map[string]any{{"name": "A"}, {"name": "B"}}, "def", nil)
+	mockStore.EXPECT().QueryPage(query.Input{Path: "users", Fields: []string{}, Limit: 2}).
+		Return([]map[string]any{{"name": "C"}}, "", nil)
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Get(root))
+
+	root.SetArgs([]string{"get", "users", "--limit", "2", "--page-token", "abc"})
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Equal(t, `{"$documents":[{"name":"A"},{"name":"B"}],"$nextPageToken":"def"}`+"\n", output)
+
+	// an empty token asks for the first page, and the last page has no next token
+	root.SetArgs([]string{"get", "users", "--limit", "2", "--page-token", ""})
+	output = captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Equal(t, `{"$documents":[{"name":"C"}],"$nextPageToken":""}`+"\n", output)
+}
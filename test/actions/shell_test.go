@@ -0,0 +1,118 @@
+package actions
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"jhight.com/firestore-cli/pkg/api/actions"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"jhight.com/firestore-cli/pkg/config"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestShellAction(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	mockStore.EXPECT().IsPathToDocument("users").Return(false).AnyTimes()
+	mockStore.EXPECT().IsPathToCollection("users").Return(true).AnyTimes()
+	mockStore.EXPECT().IsPathToDocument("users/user-1").Return(true).AnyTimes()
+	mockStore.EXPECT().IsPathToCollection("users/user-1").Return(false).AnyTimes()
+
+	mockStore.EXPECT().Query(query.Input{Path: "users", Fields: []string{query.SelectionDocumentID}}, gomock.Any()).
+		DoAndReturn(func(_ query.Input, visit func(map[string]any) error) error {
+			return visit(map[string]any{query.SelectionDocumentID: "user-1"})
+		})
+	mockStore.EXPECT().Get(query.Input{Path: "users/user-1", Fields: []string{"name"}}).Return(map[string]any{"name": "John"}, nil)
+	mockStore.EXPECT().Update("users/user-1", map[string]any{"age": json.Number("31")}).Return(nil)
+	mockStore.EXPECT().Collections(query.Input{Path: "users/user-1"}).Return([]any{"orders"}, nil)
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{ProjectID: "demo", Format: "ndjson"}, mockStore))
+	root.Add(actions.Get(root), actions.Update(root), actions.Shell(root))
+	root.Command().SetIn(strings.NewReader(`cd users
+ls
+get user-1 name
+cd user-1
+update . '{
+  "age": 31
+}'
+ls
+cd ../..
+pwd
+exit
+`))
+	root.SetArgs([]string{"shell"})
+
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Equal(t, "user-1\n{\"name\":\"John\"}\nusers/user-1 successfully updated\norders\n/\n", output)
+}
+
+func TestShellActionFlagsAndErrors(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	mockStore.EXPECT().IsPathToCollection(gomock.Any()).Return(false).AnyTimes()
+	mockStore.EXPECT().IsPathToDocument(gomock.Any()).Return(true).AnyTimes()
+	mockStore.EXPECT().Get(query.Input{Path: "users/user-1", Fields: []string{"name"}}).Return(map[string]any{"name": "John"}, nil).Times(2)
+	mockStore.EXPECT().Get(query.Input{Path: "users/user-2", Fields: []string{"name"}}).Return(nil, errors.New("not found"))
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{ProjectID: "demo", PrettyPrint: true, PrettySpacing: 2}, mockStore))
+	root.Add(actions.Get(root), actions.Shell(root))
+	root.Command().SetIn(strings.NewReader(`get users/user-1 name --raw
+get users/user-1 name
+get users/user-2 name
+get users/user-1 name --project-id other
+exit
+`))
+	root.SetArgs([]string{"shell"})
+
+	var output string
+	stderr := captureStderr(t, func() {
+		output = captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	})
+
+	// --raw applies to its own command alone, and errors are printed once without ending the shell
+	assert.Equal(t, "{\"name\":\"John\"}\n{\n  \"name\": \"John\"\n}\n", output)
+	assert.Equal(t, "Error: not found\nError: --project-id can't be changed within a shell; start a new shell instead\n", stderr)
+}
+
+func TestShellActionExplicitPaths(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	mockStore.EXPECT().IsPathToCollection(gomock.Any()).Return(false).AnyTimes()
+	mockStore.EXPECT().IsPathToDocument(gomock.Any()).Return(true).AnyTimes()
+	mockStore.EXPECT().Get(query.Input{Path: "users/1234", Fields: []string{}}).Return(map[string]any{"name": "John"}, nil)
+	mockStore.EXPECT().Update("users/1234", map[string]any{"age": json.Number("31")}).Return(nil)
+
+	// a local file named like a document doesn't keep its ID from being resolved
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	assert.Nil(t, os.WriteFile("1234", []byte("{}"), 0o600))
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{ProjectID: "demo", Format: "ndjson"}, mockStore))
+	root.Add(actions.Get(root), actions.Update(root), actions.Delete(root), actions.Shell(root))
+	root.Command().SetIn(strings.NewReader(`cd users
+update 1234 '{"age": 31}'
+cd 1234
+get
+delete
+exit
+`))
+	root.SetArgs([]string{"shell"})
+
+	var output string
+	stderr := captureStderr(t, func() {
+		output = captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	})
+
+	// reads run on the current location, but a delete needs a path
+	assert.Equal(t, "users/1234 successfully updated\n{\"name\":\"John\"}\n", output)
+	assert.Contains(t, stderr, "Error: requires at least 1 arg(s), only received 0")
+}
@@ -0,0 +1,159 @@
+package actions
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/api/option"
+	"jhight.com/firestore-cli/pkg/api/actions"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"jhight.com/firestore-cli/pkg/config"
+	"testing"
+	"time"
+)
+
+func TestTransferAction(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	refs := newRefClient(t)
+	created := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+	records := []map[string]any{
+		{"$id": "1", "$path": "users/1", "name": "John", "address": map[string]any{"city": "Chicago", "street": "1 Main St"}, "created": created},
+		{"$id": "2", "$path": "users/2", "name": "Jane", "manager": refs.Doc("users/1")},
+	}
+
+	mockStore.EXPECT().IsPathToCollection("users").Return(true)
+	mockStore.EXPECT().IsPathToCollection("people").Return(true)
+	mockStore.EXPECT().Export(query.Input{Path: "users", Filter: map[string]any{"active": true}, Limit: 2}, false, gomock.Any()).
+		DoAndReturn(func(_ query.Input, _ bool, visit func(map[string]any) error) error {
+			for _, r := range records {
+				if err := visit(r); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	written := make([]client.Write, 0)
+	mockStore.EXPECT().BulkWrite(gomock.Any(), gomock.Any()).DoAndReturn(func(next func() (client.Write, bool), done func(client.Write, error)) error {
+		for w, ok := next(); ok; w, ok = next() {
+			written = append(written, w)
+			done(w, nil)
+		}
+		return nil
+	})
+	mockStore.EXPECT().Close().Return(nil)
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{ProjectID: "prod"}, mockStore))
+	root.Add(actions.Transfer(root))
+	root.SetArgs([]string{"transfer", "users", "people", "--to-project-id", "staging", "--filter", `{"active":true}`, "--limit", "2",
+		"--fields", "name,address.city,created,manager", "--rewrite-ids", "hash", "--id-salt", "salt"})
+
+	assert.Nil(t, root.Execute())
+	assert.Len(t, written, 2)
+
+	// IDs are rewritten consistently, including in references
+	john := written[0].Path
+	assert.Regexp(t, "^people/[0-9a-f]{20}$", john)
+	assert.NotEqual(t, "people/1", john)
+	assert.Equal(t, client.Write{Mode: client.WriteModeSet, Path: john, Fields: map[string]any{
+		"name":    "John",
+		"address": map[string]any{"city": "Chicago"},
+		"created": map[string]any{"$timestamp": "2024-04-01T12:00:00Z"},
+	}, Raw: true}, written[0])
+	assert.Equal(t, map[string]any{"$ref": john}, written[1].Fields["manager"])
+}
+
+func TestTransferActionDocument(t *testing.T) {
+	refs := newRefClient(t)
+	for _, test := range []struct {
+		args []string
+		path string
+	}{
+		{[]string{"transfer", "users/1", "--to-project-id", "staging"}, "^users/1$"},
+		{[]string{"transfer", "users/1", "people/2", "--to-project-id", "staging"}, "^people/2$"},
+		// a rewritten ID replaces the ID of the destination path too
+		{[]string{"transfer", "users/1", "--rewrite-ids", "hash"}, "^users/[0-9a-f]{20}$"},
+		{[]string{"transfer", "users/1", "people/2", "--rewrite-ids", "random"}, "^people/[A-Za-z0-9]{20}$"},
+	} {
+		gc := gomock.NewController(t)
+		mockStore := client.NewMockStore(gc)
+
+		mockStore.EXPECT().IsPathToCollection(gomock.Any()).Return(false).AnyTimes()
+		mockStore.EXPECT().Export(query.Input{Path: "users/1"}, false, gomock.Any()).
+			DoAndReturn(func(_ query.Input, _ bool, visit func(map[string]any) error) error {
+				return visit(map[string]any{"$id": "1", "$path": "users/1", "note": "$delete()", "self": refs.Doc("users/1"), "link": map[string]any{"$ref": "users/1"}})
+			})
+
+		written := make([]client.Write, 0)
+		mockStore.EXPECT().BulkWrite(gomock.Any(), gomock.Any()).DoAndReturn(func(next func() (client.Write, bool), done func(client.Write, error)) error {
+			for w, ok := next(); ok; w, ok = next() {
+				written = append(written, w)
+				done(w, nil)
+			}
+			return nil
+		})
+		mockStore.EXPECT().Close().Return(nil)
+
+		root := actions.Root(actions.DefaultsInitializer(config.Config{ProjectID: "prod"}, mockStore))
+		root.Add(actions.Transfer(root))
+		root.SetArgs(test.args)
+
+		captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+		if assert.Len(t, written, 1) {
+			w := written[0]
+			assert.Regexp(t, test.path, w.Path, test.args)
+
+			// the function-like string and the map shaped like a reference are escaped and written raw, so they're copied
+			// as they are, while the reference follows the document
+			assert.True(t, w.Raw)
+			assert.Equal(t, map[string]any{"note": "$$delete()", "self": map[string]any{"$ref": w.Path}, "link": map[string]any{"$$ref": "users/1"}}, w.Fields)
+		}
+	}
+}
+
+// newRefClient returns a client to build document references with; no connection is made.
+func newRefClient(t *testing.T) *firestore.Client {
+	c, err := firestore.NewClient(context.Background(), "test-project", option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestTransferActionWriterStops(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	mockStore.EXPECT().IsPathToCollection("users").Return(true)
+	mockStore.EXPECT().IsPathToCollection("users").Return(true)
+	read := make(chan error, 1)
+	mockStore.EXPECT().Export(query.Input{Path: "users"}, false, gomock.Any()).
+		DoAndReturn(func(_ query.Input, _ bool, visit func(map[string]any) error) error {
+			var err error
+			for i := 0; i < 3 && err == nil; i++ {
+				err = visit(map[string]any{"$id": "1", "$path": "users/1", "name": "John"})
+			}
+			read <- err
+			return err
+		})
+
+	// the writer gives up after the first write, before the reader is done
+	mockStore.EXPECT().BulkWrite(gomock.Any(), gomock.Any()).DoAndReturn(func(next func() (client.Write, bool), _ func(client.Write, error)) error {
+		next()
+		return context.Canceled
+	})
+	mockStore.EXPECT().Close().Return(nil)
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{ProjectID: "prod"}, mockStore))
+	root.Add(actions.Transfer(root))
+	root.SetArgs([]string{"transfer", "users", "--to-project-id", "staging"})
+
+	assert.Equal(t, context.Canceled, root.Execute())
+	// the reader was stopped rather than left waiting to hand over its next write
+	assert.NotNil(t, <-read)
+}
@@ -0,0 +1,129 @@
+package actions
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"jhight.com/firestore-cli/pkg/api/actions"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const transferScript = `steps:
+  - get: accounts/alice
+    as: from
+  - check: {from.balance: {">=": 100}, from.currency: "USD"}
+  - update: accounts/alice
+    data: {balance: "$increment(-100)"}
+  - create: transfers/${from.$id}-bob
+    data: {from: "${from.$path}", amount: 100, memo: "from ${from.name}"}
+`
+
+func writeScript(t *testing.T, script string) string {
+	path := filepath.Join(t.TempDir(), "script.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte(script), 0600))
+	return path
+}
+
+func TestTxAction(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+	mockTx := client.NewMockTx(gc)
+
+	mockStore.EXPECT().Decode(gomock.Any()).DoAndReturn(func(v any) (any, error) { return v, nil }).AnyTimes()
+	mockStore.EXPECT().Transact(client.DefaultMaxAttempts, gomock.Any()).DoAndReturn(func(_ int, fn func(client.Tx) error) error {
+		return fn(mockTx)
+	})
+	mockTx.EXPECT().Get("accounts/alice").Return(map[string]any{"name": "Alice", "balance": int64(250), "currency": "USD"}, nil)
+	gomock.InOrder(
+		mockTx.EXPECT().Write(client.Write{Mode: client.WriteModeUpdate, Path: "accounts/alice", Fields: map[string]any{"balance": "$increment(-100)"}}),
+		mockTx.EXPECT().Write(client.Write{Mode: client.WriteModeCreate, Path: "transfers/alice-bob", Fields: map[string]any{"from": "accounts/alice", "amount": 100, "memo": "from Alice"}}),
+	)
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Tx(root))
+	root.SetArgs([]string{"tx", writeScript(t, transferScript)})
+
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Contains(t, output, "Transaction committed: 2 writes\n")
+}
+
+func TestTxActionCheckFails(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+	mockTx := client.NewMockTx(gc)
+
+	mockStore.EXPECT().Decode(gomock.Any()).DoAndReturn(func(v any) (any, error) { return v, nil }).AnyTimes()
+	mockStore.EXPECT().Transact(client.DefaultMaxAttempts, gomock.Any()).DoAndReturn(func(_ int, fn func(client.Tx) error) error {
+		return fn(mockTx)
+	})
+	mockTx.EXPECT().Get("accounts/alice").Return(map[string]any{"name": "Alice", "balance": int64(50), "currency": "USD"}, nil)
+
+	// Write isn't expected, so the mock fails the test if the script writes anything
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Tx(root))
+	root.SetArgs([]string{"tx", writeScript(t, transferScript)})
+
+	err := root.Execute()
+	assert.EqualError(t, err, "transaction failed, step 2: check failed: from.balance is 50, expected >= 100")
+}
+
+func TestTxActionAtomic(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	mockStore.EXPECT().Batch([]client.Write{
+		{Mode: client.WriteModeCreate, Path: "users/1", Fields: map[string]any{"name": "A"}},
+		{Mode: client.WriteModeDelete, Path: "users/2"},
+	}).Return(nil)
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Tx(root))
+	root.SetArgs([]string{"tx", "--atomic", writeScript(t, `[{"create":"users/1","data":{"name":"A"}},{"delete":"users/2"}]`)})
+
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Equal(t, "Batch committed: 2 writes\n", output)
+
+	// a read can't be part of a batch
+	root.SetArgs([]string{"tx", "--atomic", writeScript(t, `[{"get":"users/1"},{"delete":"users/2"}]`)})
+	assert.EqualError(t, root.Execute(), "step 1: --atomic scripts can only write, not get")
+}
+
+func TestTxActionReadValues(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+	mockTx := client.NewMockTx(gc)
+
+	mockStore.EXPECT().Decode(gomock.Any()).DoAndReturn(func(v any) (any, error) { return v, nil }).AnyTimes()
+	mockStore.EXPECT().Transact(client.DefaultMaxAttempts, gomock.Any()).DoAndReturn(func(_ int, fn func(client.Tx) error) error {
+		return fn(mockTx)
+	})
+	mockTx.EXPECT().Get("notes/a").Return(map[string]any{"$id": "custom", "note": "$delete()", "tags": []any{"$serverTimestamp()"}, "n": int64(2)}, nil)
+
+	// values read are escaped, so they're written as strings rather than called, and the document's own $id field is
+	// kept apart from the ID it was read by
+	mockTx.EXPECT().Write(client.Write{Mode: client.WriteModeSet, Path: "copies/a", Fields: map[string]any{
+		"id":   "custom",
+		"note": "$$delete()",
+		"text": "note $delete()",
+		"tags": []any{"$$serverTimestamp()"},
+		"n":    "$increment(2)",
+	}})
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Tx(root))
+	root.SetArgs([]string{"tx", writeScript(t, `steps:
+  - get: notes/a
+    as: a
+  - check: {a.$id: a, a.$$id: custom}
+  - set: copies/${a.$id}
+    data: {id: "${a.$$id}", note: "${a.note}", text: "note ${a.note}", tags: "${a.tags}", n: "$increment(${a.n})"}
+`)})
+
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Contains(t, output, `"$$id":"custom"`)
+	assert.Contains(t, output, `"$id":"a"`)
+	assert.Contains(t, output, "Transaction committed: 1 writes\n")
+}
@@ -0,0 +1,127 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"jhight.com/firestore-cli/pkg/api/actions"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/backup"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"jhight.com/firestore-cli/pkg/config"
+	"testing"
+)
+
+func TestUpdateActionWithFilter(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	input := query.Input{Path: "users", Filter: map[string]any{"city": "Chicago"}}
+	expectCount(mockStore, input, 2)
+	expectExport(mockStore, input, false, "users/user-1", "users/user-2")
+	written := expectWrites(mockStore, map[string]error{"users/user-2": errors.New("no document to update")})
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Update(root))
+	root.SetArgs([]string{"update", "users", "--filter", `{"city":"Chicago"}`, `{"state":"IL"}`, "--yes"})
+
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Equal(t, "1 documents updated, 1 failed\n", output)
+	assert.Equal(t, []client.Write{
+		{Mode: client.WriteModeUpdate, Path: "users/user-1", Fields: map[string]any{"state": "IL"}},
+		{Mode: client.WriteModeUpdate, Path: "users/user-2", Fields: map[string]any{"state": "IL"}},
+	}, *written)
+}
+
+func TestUpdateActionWithFilterBacksUp(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	paths := []string{"users/user-1", "users/user-2", "users/user-3"}
+	expectMatches(mockStore, query.Input{Path: "users", Filter: map[string]any{"city": "Chicago"}}, false, paths...)
+	mockStore.EXPECT().Get(gomock.Any()).DoAndReturn(func(input query.Input) (map[string]any, error) {
+		return map[string]any{"active": false, "nickname": "J", "state": "IL"}, nil
+	}).Times(len(paths))
+
+	dir := t.TempDir()
+	cfg := config.Config{ProjectID: "test", Backup: config.BackupConfig{Sink: backup.SinkDirectory, Directory: dir, Commands: []string{"update"}}}
+	root := actions.Root(actions.DefaultsInitializer(cfg, mockStore))
+	root.Add(actions.Update(root))
+	root.SetArgs([]string{"update", "users", "--filter", `{"city":"Chicago"}`, `{"state":"IL"}`, "--yes"})
+
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Equal(t, "3 documents updated, 0 failed\n", output)
+
+	records := make([]backup.Record, 0)
+	sink := backup.NewDirectorySink(dir, func(v any) (any, error) { return v, nil })
+	assert.Nil(t, sink.List(backup.Filter{}, func(r backup.Record) error {
+		records = append(records, r)
+		return nil
+	}))
+	assert.Len(t, records, len(paths))
+	for _, r := range records {
+		assert.Equal(t, map[string]any{"active": false, "nickname": "J"}, r.Before)
+		assert.Equal(t, map[string]any{"active": false, "nickname": "J", "state": "IL"}, r.After)
+	}
+}
+
+func TestUpdateActionWithFilterStopsReading(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	input := query.Input{Path: "users", Filter: map[string]any{"city": "Chicago"}}
+	expectCount(mockStore, input, 1000)
+
+	// the reader has far more documents to hand over than the bulk writer takes before failing
+	stopped := make(chan error, 1)
+	mockStore.EXPECT().Export(input, false, gomock.Any()).DoAndReturn(func(_ query.Input, _ bool, visit func(map[string]any) error) error {
+		for i := 0; i < 1000; i++ {
+			if err := visit(map[string]any{"$path": fmt.Sprintf("users/user-%d", i)}); err != nil {
+				stopped <- err
+				return err
+			}
+		}
+		stopped <- nil
+		return nil
+	})
+	mockStore.EXPECT().BulkWrite(gomock.Any(), gomock.Any()).DoAndReturn(func(next func() (client.Write, bool), done func(client.Write, error)) error {
+		next()
+		return errors.New("unavailable")
+	})
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Update(root))
+	root.SetArgs([]string{"update", "users", "--filter", `{"city":"Chicago"}`, `{"state":"IL"}`, "--yes"})
+
+	assert.EqualError(t, root.Execute(), "unavailable")
+	select {
+	case err := <-stopped:
+		assert.NotNil(t, err)
+	default:
+		t.Fatal("the reader is still running")
+	}
+}
+
+func TestUpdateActionCollectionNeedsFilter(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	mockStore.EXPECT().IsPathToCollection("users").Return(true)
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{}, mockStore))
+	root.Add(actions.Update(root))
+	root.SetArgs([]string{"update", "users", `{"state":"IL"}`, "--yes"})
+	assert.EqualError(t, root.Execute(), "users is a collection; use --filter or --limit to choose documents, or --all to update every document in it")
+
+	// --all updates every document
+	written := expectMatches(mockStore, query.Input{Path: "users"}, false, "users/user-1", "users/user-2")
+	root.SetArgs([]string{"update", "users", `{"state":"IL"}`, "--all", "--yes"})
+
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Equal(t, "2 documents updated, 0 failed\n", output)
+	assert.Equal(t, []client.Write{
+		{Mode: client.WriteModeUpdate, Path: "users/user-1", Fields: map[string]any{"state": "IL"}},
+		{Mode: client.WriteModeUpdate, Path: "users/user-2", Fields: map[string]any{"state": "IL"}},
+	}, *written)
+}
@@ -0,0 +1,47 @@
+package actions
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"jhight.com/firestore-cli/pkg/api/actions"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"jhight.com/firestore-cli/pkg/config"
+	"testing"
+	"time"
+)
+
+func TestWatchAction(t *testing.T) {
+	gc := gomock.NewController(t)
+	mockStore := client.NewMockStore(gc)
+
+	at := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+	changes := []client.Change{
+		{Type: client.ChangeAdded, Path: "users/1", Time: at, After: map[string]any{"age": int64(30)}},
+		{Type: client.ChangeModified, Path: "users/1", Time: at, Before: map[string]any{"age": int64(30)}, After: map[string]any{"age": int64(31)}},
+		{Type: client.ChangeRemoved, Path: "users/1", Time: at, Before: map[string]any{"age": int64(31)}},
+	}
+
+	mockStore.EXPECT().IsPathToDocument("users").Return(false).AnyTimes()
+	mockStore.EXPECT().IsPathToCollection("users").Return(true).AnyTimes()
+	mockStore.EXPECT().Watch(gomock.Any(), query.Input{Path: "users", Fields: []string{"age"}, Filter: map[string]any{"active": true}}, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ query.Input, visit func(client.Change) error) error {
+			for _, c := range changes {
+				if err := visit(c); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	root := actions.Root(actions.DefaultsInitializer(config.Config{Format: "ndjson"}, mockStore))
+	root.Add(actions.Watch(root))
+	root.SetArgs([]string{"watch", "users", "age", "--filter", `{"active":true}`})
+
+	output := captureStdout(t, func() { assert.Nil(t, root.Execute()) })
+	assert.Equal(t, `{"after":{"age":30},"before":null,"path":"users/1","time":{"$timestamp":"2024-04-01T12:00:00Z"},"type":"added"}
+{"after":{"age":31},"before":{"age":30},"path":"users/1","time":{"$timestamp":"2024-04-01T12:00:00Z"},"type":"modified"}
+{"after":null,"before":{"age":31},"path":"users/1","time":{"$timestamp":"2024-04-01T12:00:00Z"},"type":"removed"}
+`, output)
+}
@@ -0,0 +1,155 @@
+package backup
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/backup"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func decode(value any) (any, error) {
+	return codec.Decode(value, nil)
+}
+
+func records() []backup.Record {
+	at := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	return []backup.Record{
+		{ID: backup.NewID(at), CreatedAt: at, Path: "users/user-1", Project: "p", Database: "(default)", Before: nil, After: map[string]any{"age": int64(30)}},
+		{ID: backup.NewID(at.Add(time.Hour)), CreatedAt: at.Add(time.Hour), Path: "users/user-2", Project: "p", Database: "(default)", Before: map[string]any{"at": at, "note": "$delete()"}, After: nil},
+		{ID: backup.NewID(at.Add(2 * time.Hour)), CreatedAt: at.Add(2 * time.Hour), Path: "users/user-1", Project: "p", Database: "(default)", Before: map[string]any{"age": int64(30)}, After: map[string]any{"age": int64(31)}},
+	}
+}
+
+func list(t *testing.T, sink backup.Sink, filter backup.Filter) []string {
+	ids := make([]string, 0)
+	assert.Nil(t, sink.List(filter, func(r backup.Record) error {
+		ids = append(ids, r.ID)
+		return nil
+	}))
+	return ids
+}
+
+// backupStore mocks a store that keeps the documents written to it in memory and reads them back as Firestore would,
+// expecting to be closed when it's owned by the sink.
+func backupStore(t *testing.T, owned bool) client.Store {
+	gc := gomock.NewController(t)
+	store := client.NewMockStore(gc)
+	documents := make(map[string]map[string]any)
+
+	store.EXPECT().Batch(gomock.Any()).DoAndReturn(func(writes []client.Write) error {
+		for _, w := range writes {
+			// written raw, so nothing that looks like a function is evaluated
+			assert.Equal(t, client.WriteModeCreate, w.Mode)
+			assert.True(t, w.Raw)
+
+			decoded, err := codec.Decode(w.Fields, nil)
+			if err != nil {
+				return err
+			}
+			documents[w.Path] = decoded.(map[string]any)
+		}
+		return nil
+	}).AnyTimes()
+
+	store.EXPECT().Get(gomock.Any()).DoAndReturn(func(input query.Input) (map[string]any, error) {
+		if document, ok := documents[input.Path]; ok {
+			return document, nil
+		}
+		return nil, status.Error(codes.NotFound, "not found")
+	}).AnyTimes()
+
+	store.EXPECT().Export(gomock.Any(), false, gomock.Any()).DoAndReturn(func(input query.Input, _ bool, visit func(map[string]any) error) error {
+		// documents come in order of their IDs, which are ordered by time
+		paths := make([]string, 0, len(documents))
+		for p := range documents {
+			paths = append(paths, p)
+		}
+		slices.Sort(paths)
+		if len(input.OrderBy) > 0 && input.OrderBy[0].Direction == query.Descending {
+			slices.Reverse(paths)
+		}
+
+		for _, p := range paths {
+			document := documents[p]
+			if path, ok := input.Filter["path"]; ok && document["path"] != path {
+				continue
+			}
+			if err := visit(query.NewRecord(strings.TrimPrefix(p, input.Path+"/"), p, document)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).AnyTimes()
+
+	if owned {
+		store.EXPECT().Close().Return(nil)
+	}
+
+	return store
+}
+
+func TestSinks(t *testing.T) {
+	sinks := map[string]backup.Sink{
+		"directory": backup.NewDirectorySink(filepath.Join(t.TempDir(), "backups"), decode),
+		"archive":   backup.NewArchiveSink(t.TempDir(), 1<<20, 10, decode),
+		"firestore": backup.NewFirestoreSink(backupStore(t, false), "backup"),
+		"project":   backup.NewProjectSink(backupStore(t, true), "backup", decode),
+	}
+
+	for name, sink := range sinks {
+		t.Run(name, func(t *testing.T) {
+			rs := records()
+			for _, r := range rs {
+				assert.Nil(t, sink.Write(r))
+			}
+
+			assert.Equal(t, []string{rs[2].ID, rs[1].ID, rs[0].ID}, list(t, sink, backup.Filter{}))
+			assert.Equal(t, []string{rs[2].ID, rs[0].ID}, list(t, sink, backup.Filter{Path: "users/user-1"}))
+			assert.Equal(t, []string{rs[2].ID}, list(t, sink, backup.Filter{Path: "users/user-1", Limit: 1}))
+			assert.Equal(t, []string{rs[1].ID}, list(t, sink, backup.Filter{Since: rs[1].CreatedAt, Until: rs[2].CreatedAt}))
+
+			r, err := sink.Get(rs[1].ID)
+			assert.Nil(t, err)
+			assert.Equal(t, "delete", r.Change())
+			assert.True(t, rs[0].CreatedAt.Equal(r.Before["at"].(time.Time)))
+			assert.Equal(t, "$delete()", r.Before["note"])
+
+			_, err = sink.Get("1")
+			assert.ErrorIs(t, err, backup.ErrNotFound)
+
+			assert.Nil(t, sink.Close())
+		})
+	}
+}
+
+func TestArchiveSinkRotates(t *testing.T) {
+	directory := t.TempDir()
+	sink := backup.NewArchiveSink(directory, 1, 1, decode)
+
+	rs := records()
+	for _, r := range rs {
+		assert.Nil(t, sink.Write(r))
+	}
+
+	// every write after the first rotates, and only one rotated archive is kept
+	entries, err := os.ReadDir(directory)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, []string{rs[2].ID, rs[1].ID}, list(t, sink, backup.Filter{}))
+
+	// rotating doesn't take up record IDs, so the next record's ID still comes from its own time
+	id, err := strconv.ParseInt(backup.NewID(rs[2].CreatedAt.Add(time.Hour)), 10, 64)
+	assert.Nil(t, err)
+	assert.Equal(t, rs[2].CreatedAt.Add(time.Hour).UnixMilli(), id)
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"jhight.com/firestore-cli/pkg/config"
+	"jhight.com/firestore-cli/test/fake"
+	"os"
+	"testing"
+)
+
+func TestWithEmulatorEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		cfg     config.Config
+		host    string
+		derived string
+	}{
+		{name: "none", cfg: config.Config{ProjectID: "p"}},
+		{name: "environment", env: "localhost:8080", cfg: config.Config{ProjectID: "p"}, host: "localhost:8080"},
+		{name: "config over environment", env: "localhost:8080", cfg: config.Config{EmulatorHost: "localhost:9090"}, host: "localhost:9090", derived: "localhost:9090"},
+		{name: "config alone", cfg: config.Config{EmulatorHost: "localhost:9090"}, host: "localhost:9090", derived: "localhost:9090"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(client.EmulatorHostEnv, test.env)
+
+			cfg := client.WithEmulatorEnv(test.cfg)
+			assert.Equal(t, test.host, cfg.EmulatorHost)
+
+			// the config of another store only keeps an emulator host it was given
+			assert.Equal(t, test.derived, cfg.Derived().EmulatorHost)
+		})
+	}
+}
+
+func TestNewUsesOwnEmulatorHost(t *testing.T) {
+	server := fake.NewServer(t)
+	server.Put("users/1", map[string]any{"name": "A"})
+
+	// the environment points elsewhere, but the config's own emulator host is used, and the variable is put back
+	t.Setenv(client.EmulatorHostEnv, "localhost:1")
+	store, err := client.New(context.Background(), config.Config{EmulatorHost: server.Addr, ProjectID: fake.ProjectID})
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	assert.Equal(t, "localhost:1", os.Getenv(client.EmulatorHostEnv))
+
+	document, err := store.Get(query.Input{Path: "users/1"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]any{"name": "A"}, document)
+}
+
+func TestNewEmulatorProject(t *testing.T) {
+	server := fake.NewServer(t)
+
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		project string
+		backend string
+	}{
+		{name: "placeholder project", cfg: config.Config{EmulatorHost: server.Addr}, project: "demo-firestore-cli", backend: "emulator " + server.Addr},
+		{name: "configured project", cfg: config.Config{EmulatorHost: server.Addr, ProjectID: "dev"}, project: "dev", backend: "emulator " + server.Addr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// the emulator skips the credential chain, so a service account that doesn't exist is never read
+			test.cfg.ServiceAccount = "/nonexistent.json"
+
+			store, err := client.New(context.Background(), test.cfg)
+			if !assert.Nil(t, err) {
+				return
+			}
+			t.Cleanup(func() { _ = store.Close() })
+
+			creds := store.Credentials()
+			assert.Equal(t, client.CredentialSourceEmulator, creds.Source)
+			assert.Equal(t, server.Addr, creds.Path)
+			assert.Equal(t, test.project, creds.ProjectID)
+			assert.Equal(t, test.backend, client.Backend(test.cfg))
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeServiceAccount writes a service account key file for the project, with a freshly generated private key.
+func writeServiceAccount(t *testing.T, path string, project string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(map[string]any{
+		"type":           "service_account",
+		"project_id":     project,
+		"private_key_id": "1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		"client_email":   "cli@" + project + ".iam.gserviceaccount.com",
+		"client_id":      "1",
+		"token_uri":      "https://oauth2.googleapis.com/token",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, path, string(data))
+}
+
+func writeFile(t *testing.T, path string, data string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolveCredentials(t *testing.T) {
+	dir := t.TempDir()
+	serviceAccount := filepath.Join(dir, "service-account.json")
+	environment := filepath.Join(dir, "environment.json")
+	tokenFile := filepath.Join(dir, "token")
+	writeServiceAccount(t, serviceAccount, "sa-project")
+	writeServiceAccount(t, environment, "env-project")
+	writeFile(t, tokenFile, "file-token\n")
+
+	// application default credentials are found in the gcloud config under HOME
+	adcHome := filepath.Join(dir, "adc")
+	writeFile(t, filepath.Join(adcHome, ".config", "gcloud", "application_default_credentials.json"),
+		`{"type":"authorized_user","client_id":"id","client_secret":"secret","refresh_token":"refresh","quota_project_id":"adc-project"}`)
+
+	tests := []struct {
+		name      string
+		cfg       config.Config
+		env       string
+		adc       bool
+		token     string
+		source    client.CredentialSource
+		path      string
+		principal string
+		project   string
+		err       string
+	}{
+		{
+			name:   "emulator first",
+			cfg:    config.Config{EmulatorHost: "localhost:8080", ServiceAccount: serviceAccount},
+			env:    environment,
+			adc:    true,
+			source: client.CredentialSourceEmulator, path: "localhost:8080", principal: "(unauthenticated)",
+		},
+		{
+			name:   "service account before the environment",
+			cfg:    config.Config{ServiceAccount: serviceAccount, AccessTokenFile: tokenFile},
+			env:    environment,
+			adc:    true,
+			token:  "env-token",
+			source: client.CredentialSourceServiceAccount, path: serviceAccount, principal: "cli@sa-project.iam.gserviceaccount.com", project: "sa-project",
+		},
+		{
+			name:   "environment before application default credentials",
+			cfg:    config.Config{AccessTokenFile: tokenFile},
+			env:    environment,
+			adc:    true,
+			token:  "env-token",
+			source: client.CredentialSourceEnvironment, path: environment, principal: "cli@env-project.iam.gserviceaccount.com", project: "env-project",
+		},
+		{
+			name:   "application default credentials before an access token",
+			cfg:    config.Config{AccessTokenFile: tokenFile},
+			adc:    true,
+			token:  "env-token",
+			source: client.CredentialSourceApplicationDefault,
+		},
+		{
+			name:   "access token variable before the file",
+			cfg:    config.Config{AccessTokenFile: tokenFile},
+			token:  "env-token",
+			source: client.CredentialSourceAccessToken,
+		},
+		{
+			name:   "access token file",
+			cfg:    config.Config{AccessTokenFile: tokenFile},
+			source: client.CredentialSourceAccessToken, path: tokenFile,
+		},
+		{
+			name: "missing service account",
+			cfg:  config.Config{ServiceAccount: filepath.Join(dir, "missing.json")},
+			env:  environment,
+			err:  "error reading credentials file",
+		},
+		{
+			name: "nothing",
+			err:  "no credentials found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			home := t.TempDir()
+			if test.adc {
+				home = adcHome
+			}
+			t.Setenv("HOME", home)
+			t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", test.env)
+			t.Setenv(client.AccessTokenEnv, test.token)
+
+			creds, err := client.ResolveCredentials(context.Background(), test.cfg)
+			if len(test.err) > 0 {
+				assert.ErrorContains(t, err, test.err)
+				return
+			}
+			if !assert.Nil(t, err) {
+				return
+			}
+
+			assert.Equal(t, test.source, creds.Source)
+			assert.Equal(t, test.path, creds.Path)
+			assert.Equal(t, test.principal, creds.Principal)
+			assert.Equal(t, test.project, creds.ProjectID)
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/config"
+	"jhight.com/firestore-cli/test/fake"
+	"testing"
+)
+
+func TestDatabaseID(t *testing.T) {
+	server := fake.NewServer(t)
+
+	tests := []struct {
+		name     string
+		database string
+		id       string
+		backend  string
+		path     string
+	}{
+		{name: "default", id: firestore.DefaultDatabaseID, backend: "project test", path: "users/1"},
+		{name: "named", database: "orders-db", id: "orders-db", backend: "project test, database orders-db", path: "projects/test/databases/orders-db/documents/users/1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := config.Config{ProjectID: fake.ProjectID, DatabaseID: test.database}
+			assert.Equal(t, test.id, client.DatabaseID(cfg))
+			assert.Equal(t, test.backend, client.Backend(cfg))
+
+			// the store writes to the database it was given
+			cfg.EmulatorHost = server.Addr
+			store, err := client.New(context.Background(), cfg)
+			if !assert.Nil(t, err) {
+				return
+			}
+			t.Cleanup(func() { _ = store.Close() })
+
+			assert.Nil(t, store.Set("users/1", map[string]any{"name": test.name}))
+			assert.Contains(t, server.Paths(), test.path)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/test/fake"
+	"testing"
+)
+
+func putUsers(server *fake.Server) {
+	server.Put("users/1", map[string]any{"name": "A"})
+	// users/2 doesn't exist, but has a subcollection
+	server.Put("users/2/orders/a", map[string]any{"total": 1})
+	server.Put("users/3", map[string]any{"name": "C"})
+	server.Put("users/3/orders/b", map[string]any{"total": 2})
+	server.Put("users/4", map[string]any{"name": "D"})
+}
+
+func deleteUsers(t *testing.T, store client.Store, resume string) (map[string]error, []string) {
+	results := make(map[string]error)
+	checkpoints := make([]string, 0)
+	assert.Nil(t, store.Delete("users", resume, func(w client.Write, err error) {
+		results[w.Path] = err
+	}, func(resume string) {
+		checkpoints = append(checkpoints, resume)
+	}))
+	return results, checkpoints
+}
+
+func TestDeleteCollection(t *testing.T) {
+	server, store := newFakeStore(t)
+	putUsers(server)
+
+	results, checkpoints := deleteUsers(t, store, "")
+	assert.Empty(t, server.Paths())
+	assert.Len(t, results, 6)
+
+	// the checkpoint only moves forward, and ends on the last document
+	assert.IsIncreasing(t, checkpoints)
+	assert.Equal(t, "4", checkpoints[len(checkpoints)-1])
+}
+
+func TestDeleteCollectionResumes(t *testing.T) {
+	server, store := newFakeStore(t)
+	putUsers(server)
+
+	results, checkpoints := deleteUsers(t, store, "2")
+	assert.Equal(t, []string{"users/1", "users/2/orders/a"}, server.Paths())
+	assert.Equal(t, map[string]error{"users/3/orders/b": nil, "users/3": nil, "users/4": nil}, results)
+	assert.Equal(t, "4", checkpoints[len(checkpoints)-1])
+}
+
+func TestDeleteCollectionStalls(t *testing.T) {
+	server, store := newFakeStore(t)
+	putUsers(server)
+	server.Fail("users/2/orders/a", codes.PermissionDenied)
+
+	results, checkpoints := deleteUsers(t, store, "")
+
+	// the checkpoint doesn't move past a document with a failed write under it
+	assert.Contains(t, server.Paths(), "users/2/orders/a")
+	assert.Equal(t, codes.PermissionDenied, status.Code(results["users/2/orders/a"]))
+	for _, c := range checkpoints {
+		assert.Equal(t, "1", c)
+	}
+}
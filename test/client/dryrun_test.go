@@ -0,0 +1,52 @@
+package client
+
+import (
+	"github.com/stretchr/testify/assert"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"testing"
+)
+
+func TestDryRunDeleteMissingParents(t *testing.T) {
+	server, store := newFakeStore(t)
+
+	server.Put("users/1", map[string]any{"name": "A"})
+	// users/2 doesn't exist, but has a subcollection
+	server.Put("users/2/orders/b", map[string]any{"total": 2})
+	server.Put("users/2/orders/b/items/x", map[string]any{"sku": "x"})
+
+	plans := make([]string, 0)
+	dryRun := client.DryRun(store, func(p client.Plan) {
+		plans = append(plans, p.Write.Path)
+	})
+
+	deleted := make([]string, 0)
+	assert.Nil(t, dryRun.Delete("users", "", func(w client.Write, err error) {
+		assert.Nil(t, err)
+		deleted = append(deleted, w.Path)
+	}, nil))
+
+	// the documents under a missing parent are reported with the collections they empty
+	assert.Equal(t, []string{"users/1", "users/2/orders/b", "users/2/orders/b/items/x"}, deleted)
+	assert.Equal(t, []string{"users", "users/1", "users/2/orders", "users/2/orders/b", "users/2/orders/b/items", "users/2/orders/b/items/x"}, plans)
+
+	// nothing was deleted
+	assert.Equal(t, []string{"users/1", "users/2/orders/b", "users/2/orders/b/items/x"}, server.Paths())
+}
+
+func TestDryRunCopyRaw(t *testing.T) {
+	server, store := newFakeStore(t)
+	server.Put("users/1", map[string]any{"count": "$increment(1)", "note": "$delete()"})
+
+	plans := make([]client.Plan, 0)
+	dryRun := client.DryRun(store, func(p client.Plan) { plans = append(plans, p) })
+
+	assert.Nil(t, dryRun.Copy("users", "copies", false, client.ConflictFail, func(_ client.Write, err error) {
+		assert.Nil(t, err)
+	}))
+
+	// strings that look like functions are copied as they are, like the copy itself does
+	assert.Len(t, plans, 1)
+	assert.True(t, plans[0].Write.Raw)
+	assert.Equal(t, map[string]any{"count": "$increment(1)", "note": "$delete()"}, plans[0].After)
+	assert.Nil(t, server.Document("copies/1"))
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"jhight.com/firestore-cli/pkg/config"
+	"jhight.com/firestore-cli/test/fake"
+	"testing"
+)
+
+// newFakeStore returns a store backed by an in-memory Firestore server.
+func newFakeStore(t *testing.T) (*fake.Server, client.Store) {
+	server := fake.NewServer(t)
+
+	store, err := client.New(context.Background(), config.Config{EmulatorHost: server.Addr, ProjectID: fake.ProjectID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+
+	return server, store
+}
+
+func exportPaths(t *testing.T, store client.Store, input query.Input, recursive bool) []string {
+	paths := make([]string, 0)
+	assert.Nil(t, store.Export(input, recursive, func(record map[string]any) error {
+		paths = append(paths, record[query.SelectionDocumentPath].(string))
+		return nil
+	}))
+	return paths
+}
+
+func TestExportMissingParents(t *testing.T) {
+	server, store := newFakeStore(t)
+
+	server.Put("users/1", map[string]any{"name": "A"})
+	server.Put("users/1/orders/a", map[string]any{"total": 1})
+	// users/2 and users/3/orders/b don't exist, but have subcollections
+	server.Put("users/2/orders/b", map[string]any{"total": 2})
+	server.Put("users/3/orders/b/items/x", map[string]any{"sku": "x"})
+	server.Put("users/4", map[string]any{"name": "B"})
+
+	assert.Equal(t, []string{"users/1", "users/1/orders/a", "users/2/orders/b", "users/3/orders/b/items/x", "users/4"}, exportPaths(t, store, query.Input{Path: "users"}, true))
+	assert.Equal(t, []string{"users/3/orders/b/items/x"}, exportPaths(t, store, query.Input{Path: "users/3"}, true))
+
+	// without descending, only the documents that exist are exported
+	assert.Equal(t, []string{"users/1", "users/4"}, exportPaths(t, store, query.Input{Path: "users"}, false))
+
+	// a filter selects documents that exist, whose subcollections are exported in full
+	input := query.Input{Path: "users", Filter: map[string]any{"name": "A"}}
+	assert.Equal(t, []string{"users/1", "users/1/orders/a"}, exportPaths(t, store, input, true))
+}
+
+func TestExportFieldsNamedLikeMetadata(t *testing.T) {
+	server, store := newFakeStore(t)
+	server.Put("users/1", map[string]any{"$id": "legacy-1", "$path": "old/1", "$$price": 1, "name": "A"})
+
+	records := make([]map[string]any, 0)
+	assert.Nil(t, store.Export(query.Input{Path: "users/1"}, false, func(record map[string]any) error {
+		records = append(records, record)
+		return nil
+	}))
+
+	assert.Equal(t, []map[string]any{{
+		"$id":      "1",
+		"$path":    "users/1",
+		"$$id":     "legacy-1",
+		"$$path":   "old/1",
+		"$$$price": int64(1),
+		"name":     "A",
+	}}, records)
+
+	id, path, data := query.SplitRecord(records[0])
+	assert.Equal(t, "1", id)
+	assert.Equal(t, "users/1", path)
+	assert.Equal(t, map[string]any{"$id": "legacy-1", "$path": "old/1", "$$price": int64(1), "name": "A"}, data)
+}
+
+func TestExportImportTagShapedMaps(t *testing.T) {
+	server, store := newFakeStore(t)
+	server.Put("users/1", map[string]any{"link": map[string]any{"$ref": "users/2"}, "name": "A"})
+	// a document whose only field is named like a tag
+	server.Put("users/2", map[string]any{"$ref": "users/1"})
+
+	// export to NDJSON lines, then import them into another collection
+	lines := make([][]byte, 0)
+	assert.Nil(t, store.Export(query.Input{Path: "users"}, false, func(record map[string]any) error {
+		line, err := json.Marshal(codec.Encode(record))
+		lines = append(lines, line)
+		return err
+	}))
+
+	writes := make([]client.Write, 0)
+	for _, line := range lines {
+		var record map[string]any
+		assert.Nil(t, codec.Unmarshal(line, &record))
+		id, _, data := query.SplitRecord(record)
+		writes = append(writes, client.Write{Mode: client.WriteModeSet, Path: "copies/" + id, Fields: data, Raw: true})
+	}
+	assert.Nil(t, store.Batch(writes))
+
+	assert.Equal(t, server.Document("users/1"), server.Document("copies/1"))
+	assert.Equal(t, server.Document("users/2"), server.Document("copies/2"))
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"github.com/stretchr/testify/assert"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"testing"
+	"time"
+)
+
+func TestFunctionsNested(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	stamp := "$timestamp(2024-01-02T03:04:05Z)"
+
+	tests := []struct {
+		name  string
+		input map[string]any
+		want  map[string]any
+	}{
+		{"top level", map[string]any{"at": stamp}, map[string]any{"at": at}},
+		{"nested map", map[string]any{"audit": map[string]any{"updated": stamp}}, map[string]any{"audit": map[string]any{"updated": at}}},
+		{"array", map[string]any{"dates": []any{"plain", stamp}}, map[string]any{"dates": []any{"plain", at}}},
+		{"map in array in map", map[string]any{"a": []any{map[string]any{"b": []any{stamp}}}}, map[string]any{"a": []any{map[string]any{"b": []any{at}}}}},
+		{"tagged value in array", map[string]any{"owners": []any{map[string]any{"$ref": "users/1"}}}, map[string]any{"owners": []any{"users/1"}}},
+		{"unknown function", map[string]any{"note": "$unknown(1)"}, map[string]any{"note": "$unknown(1)"}},
+		{"escaped function", map[string]any{"notes": []any{map[string]any{"body": "$$now()"}}}, map[string]any{"notes": []any{map[string]any{"body": "$now()"}}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, store := newFakeStore(t)
+			assert.Nil(t, store.Set("docs/1", test.input))
+			assert.Equal(t, test.want, server.Document("docs/1"))
+		})
+	}
+}
+
+func TestFunctionErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input map[string]any
+		want  string
+	}{
+		{"top level", map[string]any{"at": "$timestamp(yesterday)"}, "invalid value at at, invalid timestamp format yesterday"},
+		{"nested map", map[string]any{"audit": map[string]any{"updated": "$timestamp(yesterday)"}}, "invalid value at audit.updated, invalid timestamp format yesterday"},
+		{"array", map[string]any{"dates": []any{"ok", "$timestamp(yesterday)"}}, "invalid value at dates[1], invalid timestamp format yesterday"},
+		{"map in array", map[string]any{"a": []any{map[string]any{"b": "$increment(x)"}}}, "invalid value at a[0].b, $increment requires a single number"},
+		{"array in array", map[string]any{"a": []any{[]any{"$arrayUnion(nope)"}}}, "invalid value at a[0][0], invalid function arguments nope"},
+		{"tagged value", map[string]any{"a": map[string]any{"at": map[string]any{"$timestamp": "yesterday"}}}, "invalid value at a.at, invalid $timestamp yesterday"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, store := newFakeStore(t)
+			assert.ErrorContains(t, store.Set("docs/1", test.input), test.want)
+			assert.Nil(t, server.Document("docs/1"))
+		})
+	}
+}
+
+func TestEscapedFunctionsRoundTrip(t *testing.T) {
+	server, store := newFakeStore(t)
+	server.Put("notes/1", map[string]any{"body": "keep me"})
+
+	// an escaped function is written as the string it escapes, rather than being called
+	assert.Nil(t, store.Update("notes/1", map[string]any{"body": "$$delete()", "list": []any{"$$now()"}, "count": "$increment(2)"}))
+	assert.Equal(t, map[string]any{"body": "$delete()", "list": []any{"$now()"}, "count": int64(2)}, server.Document("notes/1"))
+
+	// reading it back escapes it again, so writing what was read leaves the document as it was
+	document, err := store.Get(query.Input{Path: "notes/1"})
+	assert.Nil(t, err)
+	encoded := codec.Encode(document).(map[string]any)
+	assert.Equal(t, "$$delete()", encoded["body"])
+
+	assert.Nil(t, store.Set("notes/1", encoded))
+	assert.Equal(t, map[string]any{"body": "$delete()", "list": []any{"$now()"}, "count": int64(2)}, server.Document("notes/1"))
+}
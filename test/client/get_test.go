@@ -27,7 +27,14 @@ func TestGetAction(t *testing.T) {
 	}
 
 	mockStore.EXPECT().IsPathToCollection("example-path").Return(true)
-	mockStore.EXPECT().Query(query.Input{Path: "example-path"}).Return(example, nil)
+	mockStore.EXPECT().Query(query.Input{Path: "example-path"}, gomock.Any()).DoAndReturn(func(_ query.Input, visit func(map[string]any) error) error {
+		for _, doc := range example {
+			if err := visit(doc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 
 	err := root.Execute()
 	assert.Nil(t, err)
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"jhight.com/firestore-cli/test/fake"
+	"testing"
+)
+
+func TestQueryCollectionGroup(t *testing.T) {
+	server, store := newFakeStore(t)
+	server.Put("orders/a", map[string]any{"price": 120})
+	server.Put("users/1/orders/b", map[string]any{"price": 150})
+	server.Put("users/1/orders/c", map[string]any{"price": 50})
+	// users/2 doesn't exist, but its orders are still part of the group
+	server.Put("users/2/orders/d", map[string]any{"price": 200})
+	server.Put("shops/1/orders/e", map[string]any{"price": 300})
+	server.Put("shops/1/returns/f", map[string]any{"price": 400})
+
+	input := query.Input{
+		Path:            "orders",
+		CollectionGroup: true,
+		Fields:          []string{query.SelectionDocumentPath, "price"},
+		Filter:          map[string]any{"price": map[string]any{">": 100}},
+		OrderBy:         []query.OrderBy{{Field: "price", Direction: query.Ascending}},
+	}
+
+	// a query gives the full name of each document as its $path
+	root := "projects/" + fake.ProjectID + "/databases/(default)/documents/"
+	documents := make([]map[string]any, 0)
+	assert.Nil(t, store.Query(input, func(document map[string]any) error {
+		documents = append(documents, document)
+		return nil
+	}))
+	assert.Equal(t, []map[string]any{
+		{"$path": root + "orders/a", "price": int64(120)},
+		{"$path": root + "users/1/orders/b", "price": int64(150)},
+		{"$path": root + "users/2/orders/d", "price": int64(200)},
+		{"$path": root + "shops/1/orders/e", "price": int64(300)},
+	}, documents)
+
+	aggregates, err := store.Aggregate(query.Input{Path: "orders", CollectionGroup: true, Count: true, Sum: []string{"price"}})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), aggregates[query.AggregateCount])
+
+	// a group is named by its collection ID alone
+	_, err = store.Aggregate(query.Input{Path: "users/1/orders", CollectionGroup: true, Count: true})
+	assert.EqualError(t, err, "invalid collection group ID, users/1/orders; must be a collection ID such as orders")
+}
+
+func TestWatchCollectionGroupID(t *testing.T) {
+	_, store := newFakeStore(t)
+
+	// a document-shaped ID is rejected as a group, rather than watched as a document
+	err := store.Watch(context.Background(), query.Input{Path: "users/1", CollectionGroup: true}, func(client.Change) error { return nil })
+	assert.EqualError(t, err, "invalid collection group ID, users/1; must be a collection ID such as orders")
+}
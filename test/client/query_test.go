@@ -0,0 +1,77 @@
+package client
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"jhight.com/firestore-cli/pkg/api/client/query"
+	"testing"
+)
+
+func TestQueryPage(t *testing.T) {
+	server, store := newFakeStore(t)
+	for i, city := range []string{"Chicago", "Boston", "Chicago", "Austin", "Boston"} {
+		server.Put(fmt.Sprintf("users/%d", i+1), map[string]any{"city": city})
+	}
+
+	pages := func(input query.Input) [][]string {
+		all := make([][]string, 0)
+		for {
+			documents, next, err := store.QueryPage(input)
+			assert.Nil(t, err)
+
+			ids := make([]string, 0)
+			for _, d := range documents {
+				ids = append(ids, d[query.SelectionDocumentID].(string))
+			}
+			all = append(all, ids)
+
+			if len(next) == 0 {
+				return all
+			}
+			input.PageToken = next
+		}
+	}
+
+	fields := []string{query.SelectionDocumentID}
+	assert.Equal(t, [][]string{{"1", "2"}, {"3", "4"}, {"5"}}, pages(query.Input{Path: "users", Fields: fields, Limit: 2}))
+
+	// a page ending on the last document has no next page
+	assert.Equal(t, [][]string{{"1", "2", "3", "4", "5"}}, pages(query.Input{Path: "users", Fields: fields, Limit: 5}))
+	assert.Equal(t, [][]string{{"1", "2", "3", "4", "5"}}, pages(query.Input{Path: "users", Fields: fields}))
+
+	// repeated order by values are paged through by document ID
+	input := query.Input{Path: "users", Fields: fields, Limit: 2, OrderBy: []query.OrderBy{{Field: "city", Direction: query.Descending}}}
+	assert.Equal(t, [][]string{{"3", "1"}, {"5", "2"}, {"4"}}, pages(input))
+
+	// the offset only skips documents before the first page
+	input = query.Input{Path: "users", Fields: fields, Limit: 2, Offset: 1}
+	assert.Equal(t, [][]string{{"2", "3"}, {"4", "5"}}, pages(input))
+
+	input = query.Input{Path: "users", Fields: fields, Limit: 2, Filter: map[string]any{"city": "Boston"}}
+	assert.Equal(t, [][]string{{"2", "5"}}, pages(input))
+
+	_, _, err := store.QueryPage(query.Input{Path: "users", Limit: 2, PageToken: "not-a-token"})
+	assert.EqualError(t, err, "invalid page token; page tokens are only valid for the query that produced them")
+}
+
+func TestQueryOffset(t *testing.T) {
+	server, store := newFakeStore(t)
+	for i := 1; i <= 4; i++ {
+		server.Put(fmt.Sprintf("users/%d", i), map[string]any{"active": i%2 == 0})
+	}
+
+	ids := func(input query.Input) []string {
+		ids := make([]string, 0)
+		input.Fields = []string{query.SelectionDocumentID}
+		assert.Nil(t, store.Query(input, func(d map[string]any) error {
+			ids = append(ids, d[query.SelectionDocumentID].(string))
+			return nil
+		}))
+		return ids
+	}
+
+	assert.Equal(t, []string{"3", "4"}, ids(query.Input{Path: "users", Offset: 2}))
+
+	// an offset skips documents matching the filter
+	assert.Equal(t, []string{"4"}, ids(query.Input{Path: "users", Offset: 1, Filter: map[string]any{"active": true}}))
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"github.com/stretchr/testify/assert"
+	"jhight.com/firestore-cli/pkg/api/client"
+	"testing"
+)
+
+func TestDeletesInNestedValues(t *testing.T) {
+	existing := map[string]any{"name": "A", "nickname": "a", "address": map[string]any{"city": "Chicago", "zip": "60601"}, "tags": []any{"x"}}
+	input := map[string]any{
+		"nickname": "$delete()",
+		"address":  map[string]any{"city": "Boston", "zip": "$delete()"},
+		"tags":     []any{"y", "$delete()", map[string]any{"z": 1, "gone": "$delete()"}},
+	}
+
+	tests := []struct {
+		name string
+		mode client.WriteMode
+		want map[string]any
+	}{
+		// the whole document is replaced, so a deleted field is left out at any depth
+		{"create", client.WriteModeCreate, map[string]any{"address": map[string]any{"city": "Boston"}, "tags": []any{"y", map[string]any{"z": int64(1)}}}},
+		{"set", client.WriteModeSet, map[string]any{"address": map[string]any{"city": "Boston"}, "tags": []any{"y", map[string]any{"z": int64(1)}}}},
+		// a merge deletes fields at any depth of nested maps, but an array is written whole
+		{"merge", client.WriteModeMerge, map[string]any{"name": "A", "address": map[string]any{"city": "Boston"}, "tags": []any{"y", map[string]any{"z": int64(1)}}}},
+		// an update deletes the fields it names, and replaces nested maps whole
+		{"update", client.WriteModeUpdate, map[string]any{"name": "A", "address": map[string]any{"city": "Boston"}, "tags": []any{"y", map[string]any{"z": int64(1)}}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, store := newFakeStore(t)
+			if test.mode != client.WriteModeCreate {
+				server.Put("users/1", existing)
+			}
+
+			var written error
+			sent := false
+			assert.Nil(t, store.BulkWrite(func() (client.Write, bool) {
+				if sent {
+					return client.Write{}, false
+				}
+				sent = true
+				return client.Write{Mode: test.mode, Path: "users/1", Fields: input}, true
+			}, func(_ client.Write, err error) { written = err }))
+
+			assert.Nil(t, written)
+			assert.Equal(t, test.want, server.Document("users/1"))
+		})
+	}
+}
+
+func TestDeletesInNestedValuesOfSingleWrites(t *testing.T) {
+	server, store := newFakeStore(t)
+	server.Put("users/1", map[string]any{"name": "A", "address": map[string]any{"city": "Chicago", "zip": "60601"}})
+
+	assert.Nil(t, store.Update("users/1", map[string]any{"address": map[string]any{"city": "Boston", "zip": "$delete()"}, "list": []any{"$delete()", 1}}))
+	assert.Equal(t, map[string]any{"name": "A", "address": map[string]any{"city": "Boston"}, "list": []any{int64(1)}}, server.Document("users/1"))
+
+	assert.Nil(t, store.Set("users/2", map[string]any{"list": []any{map[string]any{"a": "$delete()", "b": 2}}}))
+	assert.Equal(t, map[string]any{"list": []any{map[string]any{"b": int64(2)}}}, server.Document("users/2"))
+}
+
+func TestRawWrites(t *testing.T) {
+	server, store := newFakeStore(t)
+	server.Put("users/1", map[string]any{"count": 1})
+
+	fields := map[string]any{
+		"note":    "$$delete()",
+		"count":   "$increment(1)",
+		"manager": map[string]any{"$ref": "users/2"},
+		"tags":    []any{"$serverTimestamp()"},
+	}
+	want := map[string]any{"note": "$delete()", "count": "$increment(1)", "manager": "users/2", "tags": []any{"$serverTimestamp()"}}
+
+	// the fields are decoded, but nothing that looks like a function is evaluated
+	preview, err := store.Preview(client.Write{Mode: client.WriteModeSet, Path: "users/1", Fields: fields, Raw: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "$delete()", preview.After["note"])
+	assert.Equal(t, "$increment(1)", preview.After["count"])
+	assert.Equal(t, []any{"$serverTimestamp()"}, preview.After["tags"])
+
+	var written error
+	sent := false
+	assert.Nil(t, store.BulkWrite(func() (client.Write, bool) {
+		if sent {
+			return client.Write{}, false
+		}
+		sent = true
+		return client.Write{Mode: client.WriteModeSet, Path: "users/1", Fields: fields, Raw: true}, true
+	}, func(_ client.Write, err error) { written = err }))
+	assert.Nil(t, written)
+	assert.Equal(t, want, server.Document("users/1"))
+
+	assert.Nil(t, store.Batch([]client.Write{{Mode: client.WriteModeCreate, Path: "users/3", Fields: fields, Raw: true}}))
+	assert.Equal(t, want, server.Document("users/3"))
+}
+
+func TestWritesOfFieldsNamedLikeTags(t *testing.T) {
+	server, store := newFakeStore(t)
+
+	// the only field is named like a tag, so it is written as a field rather than decoded
+	assert.Nil(t, store.Set("users/1", map[string]any{"$ref": "users/2"}))
+	assert.Equal(t, map[string]any{"$ref": "users/2"}, server.Document("users/1"))
+
+	// its escaped form is written the same way
+	assert.Nil(t, store.Set("users/2", map[string]any{"$$ref": "users/1"}))
+	assert.Equal(t, map[string]any{"$ref": "users/1"}, server.Document("users/2"))
+}
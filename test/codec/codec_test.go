@@ -0,0 +1,112 @@
+package codec
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	// the emulator host keeps the client from looking for credentials; no connection is made
+	t.Setenv("FIRESTORE_EMULATOR_HOST", "localhost:8080")
+	client, err := firestore.NewClient(context.Background(), "test-project")
+	assert.Nil(t, err)
+
+	created := time.Date(2024, 4, 1, 12, 30, 0, 123000000, time.UTC)
+	document := map[string]any{
+		"count":    int64(5),
+		"price":    float64(100),
+		"name":     "John",
+		"created":  created,
+		"owner":    client.Doc("users/user-1234"),
+		"location": &latlng.LatLng{Latitude: 41.88, Longitude: -87.63},
+		"avatar":   []byte("png"),
+		"tags":     []any{"a", map[string]any{"at": created}},
+	}
+
+	encoded, err := json.Marshal(codec.Encode(document))
+	assert.Nil(t, err)
+	assert.Contains(t, string(encoded), `"owner":{"$ref":"users/user-1234"}`)
+	assert.Contains(t, string(encoded), `"price":100.0`)
+	assert.Contains(t, string(encoded), `"location":{"$geopoint":[41.88,-87.63]}`)
+
+	var parsed map[string]any
+	assert.Nil(t, codec.Unmarshal(encoded, &parsed))
+
+	decoded, err := codec.Decode(parsed, client)
+	assert.Nil(t, err)
+
+	m := decoded.(map[string]any)
+	assert.Equal(t, int64(5), m["count"])
+	assert.Equal(t, float64(100), m["price"])
+	assert.Equal(t, "John", m["name"])
+	assert.True(t, created.Equal(m["created"].(time.Time)))
+	assert.Equal(t, "users/user-1234", codec.Path(m["owner"].(*firestore.DocumentRef)))
+	assert.Equal(t, 41.88, m["location"].(*latlng.LatLng).Latitude)
+	assert.Equal(t, []byte("png"), m["avatar"])
+	assert.True(t, created.Equal(m["tags"].([]any)[1].(map[string]any)["at"].(time.Time)))
+}
+
+func TestDecodeInvalidTag(t *testing.T) {
+	_, err := codec.Decode(map[string]any{"at": map[string]any{codec.TagTimestamp: "yesterday"}}, nil)
+	assert.NotNil(t, err)
+}
+
+func TestEscapeFunctionLikeStrings(t *testing.T) {
+	for _, s := range []string{"$delete()", "$$delete()", "$increment(1)", "$unknown(a, b)"} {
+		escaped := codec.Escape(s)
+		assert.Equal(t, "$"+s, escaped)
+
+		unescaped, ok := codec.Unescape(escaped)
+		assert.True(t, ok)
+		assert.Equal(t, s, unescaped)
+	}
+
+	// strings that don't read like functions are left alone
+	for _, s := range []string{"$delete", "$$money", "delete()", "$1(2)", ""} {
+		assert.Equal(t, s, codec.Escape(s))
+		unescaped, ok := codec.Unescape(s)
+		assert.False(t, ok)
+		assert.Equal(t, s, unescaped)
+	}
+
+	document := map[string]any{"note": "$delete()", "tags": []any{"$now()", "plain"}}
+	encoded, err := json.Marshal(codec.Encode(document))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"note":"$$delete()","tags":["$$now()","plain"]}`, string(encoded))
+
+	var parsed map[string]any
+	assert.Nil(t, codec.Unmarshal(encoded, &parsed))
+	decoded, err := codec.Decode(parsed, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, document, decoded)
+}
+
+func TestEscapeTagShapedMaps(t *testing.T) {
+	document := map[string]any{
+		"link":   map[string]any{"$ref": "x"},
+		"nested": map[string]any{"$$timestamp": "now"},
+		"both":   map[string]any{"$ref": "x", "label": "y"},
+	}
+
+	encoded, err := json.Marshal(codec.Encode(document))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"both":{"$ref":"x","label":"y"},"link":{"$$ref":"x"},"nested":{"$$$timestamp":"now"}}`, string(encoded))
+
+	var parsed map[string]any
+	assert.Nil(t, codec.Unmarshal(encoded, &parsed))
+	decoded, err := codec.Decode(parsed, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, document, decoded)
+
+	tag, ok := codec.EscapedTag(map[string]any{"$$ref": "x"})
+	assert.True(t, ok)
+	assert.Equal(t, "$ref", tag)
+	_, ok = codec.EscapedTag(map[string]any{"$ref": "x"})
+	assert.False(t, ok)
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+	"jhight.com/firestore-cli/pkg/config"
+	"testing"
+)
+
+func TestWithProfile(t *testing.T) {
+	var cfg config.Config
+	err := yaml.Unmarshal([]byte(`
+project-id: base
+pretty-print: true
+backup:
+  collection: history
+  commands: [update]
+profiles:
+  dev:
+    project-id: dev
+    pretty-print: false
+  prod:
+    backup:
+      commands: [delete]
+`), &cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"dev", "prod"}, cfg.ProfileNames())
+
+	dev, err := cfg.WithProfile("dev")
+	assert.Nil(t, err)
+	assert.Equal(t, "dev", dev.ProjectID)
+	assert.False(t, dev.PrettyPrint)
+	assert.Equal(t, "dev", dev.Profile)
+
+	// switching profiles starts again from the top-level values
+	prod, err := dev.WithProfile("prod")
+	assert.Nil(t, err)
+	assert.Equal(t, "base", prod.ProjectID)
+	assert.True(t, prod.PrettyPrint)
+	assert.Equal(t, "history", prod.Backup.Collection)
+	assert.Equal(t, []string{"delete"}, prod.Backup.Commands)
+
+	_, err = cfg.WithProfile("missing")
+	assert.NotNil(t, err)
+}
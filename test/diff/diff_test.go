@@ -0,0 +1,62 @@
+package diff
+
+import (
+	"github.com/stretchr/testify/assert"
+	"jhight.com/firestore-cli/pkg/api/client/codec"
+	"jhight.com/firestore-cli/pkg/api/client/diff"
+	"testing"
+	"time"
+)
+
+func TestCompare(t *testing.T) {
+	at := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+
+	a := map[string]any{
+		"name":    "John",
+		"age":     int64(30),
+		"price":   int64(100),
+		"created": at,
+		"address": map[string]any{"city": "Chicago", "zip": "60601"},
+		"tags":    []any{"a", "b", "c"},
+	}
+	b := map[string]any{
+		"name":     "John",
+		"age":      int64(31),
+		"price":    float64(100),
+		"created":  at.In(time.FixedZone("CST", -6*60*60)),
+		"address":  map[string]any{"city": "Boston"},
+		"tags":     []any{"a"},
+		"my.field": true,
+	}
+
+	changes := diff.Compare(a, b)
+
+	assert.Equal(t, []diff.Change{
+		{Op: diff.OpReplace, Path: []any{"address", "city"}, From: "Chicago", To: "Boston"},
+		{Op: diff.OpRemove, Path: []any{"address", "zip"}, From: "60601"},
+		{Op: diff.OpReplace, Path: []any{"age"}, From: int64(30), To: int64(31)},
+		{Op: diff.OpAdd, Path: []any{"my.field"}, To: true},
+		// an integer and a double are different Firestore types, even with the same value
+		{Op: diff.OpReplace, Path: []any{"price"}, From: int64(100), To: float64(100)},
+		{Op: diff.OpRemove, Path: []any{"tags", 2}, From: "c"},
+		{Op: diff.OpRemove, Path: []any{"tags", 1}, From: "b"},
+	}, changes)
+
+	assert.Equal(t, "`my.field`", diff.FieldPath([]any{"my.field"}))
+	assert.Equal(t, "tags[2]", diff.FieldPath([]any{"tags", 2}))
+
+	// values are left for the output to encode, so a function-like string isn't escaped twice
+	patch := diff.Patch(diff.Compare(map[string]any{"a/b": int64(1), "n": int64(1)}, map[string]any{"n": float64(2), "t": at, "s": "$delete()"}))
+	assert.Equal(t, []map[string]any{
+		{"op": "remove", "path": "/a~1b"},
+		{"op": "replace", "path": "/n", "value": float64(2)},
+		{"op": "add", "path": "/s", "value": "$delete()"},
+		{"op": "add", "path": "/t", "value": at},
+	}, patch)
+	assert.Equal(t, []any{
+		map[string]any{"op": "remove", "path": "/a~1b"},
+		map[string]any{"op": "replace", "path": "/n", "value": codec.Double(2)},
+		map[string]any{"op": "add", "path": "/s", "value": "$$delete()"},
+		map[string]any{"op": "add", "path": "/t", "value": map[string]any{"$timestamp": "2024-04-01T12:00:00Z"}},
+	}, codec.Encode(patch))
+}
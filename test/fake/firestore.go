@@ -0,0 +1,1012 @@
+// Package fake is an in-memory Firestore server for tests. It speaks enough of the Firestore gRPC API for the client
+// to read, list, query and write documents the way it would against the emulator, without needing one.
+package fake
+
+import (
+	"bytes"
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
+	"context"
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"math"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ProjectID is the project the helpers of a Server read and write.
+const ProjectID = "test"
+
+const documentRoot = "projects/" + ProjectID + "/databases/(default)/documents"
+const documentIDField = "__name__"
+
+// Server is an in-memory Firestore, listening on Addr.
+type Server struct {
+	firestorepb.UnimplementedFirestoreServer
+
+	Addr string
+
+	mu        sync.Mutex
+	documents map[string]*firestorepb.Document
+	failures  map[string]codes.Code
+	clock     time.Time
+	server    *grpc.Server
+}
+
+// NewServer starts a server on a free local port, stopping it when the test ends.
+func NewServer(t testing.TB) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening, %s", err)
+	}
+
+	s := &Server{
+		Addr:      listener.Addr().String(),
+		documents: make(map[string]*firestorepb.Document),
+		failures:  make(map[string]codes.Code),
+		clock:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		server:    grpc.NewServer(),
+	}
+	firestorepb.RegisterFirestoreServer(s.server, s)
+
+	go func() { _ = s.server.Serve(listener) }()
+	t.Cleanup(s.server.Stop)
+
+	return s
+}
+
+// Put writes a document at a path relative to the database root, replacing any document already there.
+func (s *Server) Put(path string, data map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.tick()
+	name := documentRoot + "/" + path
+	s.documents[name] = &firestorepb.Document{Name: name, Fields: toValue(data).GetMapValue().GetFields(), CreateTime: now, UpdateTime: now}
+}
+
+// Document returns the data of the document at a path relative to the database root, or nil if there's none.
+func (s *Server) Document(path string) map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, ok := s.documents[documentRoot+"/"+path]
+	if !ok {
+		return nil
+	}
+	return fromValue(&firestorepb.Value{ValueType: &firestorepb.Value_MapValue{MapValue: &firestorepb.MapValue{Fields: doc.Fields}}}).(map[string]any)
+}
+
+// Fail makes every batch of writes that includes a write to the document at a path relative to the database root fail
+// with code. The whole request fails, as the client would retry a single failed write.
+func (s *Server) Fail(path string, code codes.Code) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures[documentRoot+"/"+path] = code
+}
+
+// Paths returns the path of every document, relative to the database root, in order.
+func (s *Server) Paths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.documents))
+	for name := range s.documents {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return compareNames(names[i], names[j]) < 0 })
+
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = strings.TrimPrefix(name, documentRoot+"/")
+	}
+	return paths
+}
+
+func (s *Server) tick() *timestamppb.Timestamp {
+	s.clock = s.clock.Add(time.Millisecond)
+	return timestamppb.New(s.clock)
+}
+
+func (s *Server) ListDocuments(_ context.Context, req *firestorepb.ListDocumentsRequest) (*firestorepb.ListDocumentsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefix := req.Parent + "/" + req.CollectionId + "/"
+	ids := make(map[string]bool)
+	for name := range s.documents {
+		rest, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		id, _, descendant := strings.Cut(rest, "/")
+		if !descendant {
+			ids[id] = true
+		} else if req.ShowMissing && !ids[id] {
+			ids[id] = false
+		}
+	}
+
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	slices.Sort(sorted)
+
+	// the page token is the last ID of the page before
+	if len(req.PageToken) > 0 {
+		sorted = sorted[sort.SearchStrings(sorted, req.PageToken+"\x00"):]
+	}
+	next := ""
+	if req.PageSize > 0 && len(sorted) > int(req.PageSize) {
+		sorted = sorted[:req.PageSize]
+		next = sorted[len(sorted)-1]
+	}
+
+	documents := make([]*firestorepb.Document, 0, len(sorted))
+	for _, id := range sorted {
+		name := prefix + id
+		if doc, ok := s.documents[name]; ok {
+			documents = append(documents, mask(doc, req.Mask))
+		} else {
+			documents = append(documents, &firestorepb.Document{Name: name})
+		}
+	}
+
+	return &firestorepb.ListDocumentsResponse{Documents: documents, NextPageToken: next}, nil
+}
+
+func (s *Server) ListCollectionIds(_ context.Context, req *firestorepb.ListCollectionIdsRequest) (*firestorepb.ListCollectionIdsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0)
+	for name := range s.documents {
+		if rest, ok := strings.CutPrefix(name, req.Parent+"/"); ok {
+			id, _, _ := strings.Cut(rest, "/")
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	slices.Sort(ids)
+
+	return &firestorepb.ListCollectionIdsResponse{CollectionIds: ids}, nil
+}
+
+func (s *Server) BatchGetDocuments(req *firestorepb.BatchGetDocumentsRequest, stream firestorepb.Firestore_BatchGetDocumentsServer) error {
+	s.mu.Lock()
+	responses := make([]*firestorepb.BatchGetDocumentsResponse, 0, len(req.Documents))
+	readTime := timestamppb.New(s.clock)
+	for _, name := range req.Documents {
+		if doc, ok := s.documents[name]; ok {
+			responses = append(responses, &firestorepb.BatchGetDocumentsResponse{Result: &firestorepb.BatchGetDocumentsResponse_Found{Found: mask(doc, req.Mask)}, ReadTime: readTime})
+		} else {
+			responses = append(responses, &firestorepb.BatchGetDocumentsResponse{Result: &firestorepb.BatchGetDocumentsResponse_Missing{Missing: name}, ReadTime: readTime})
+		}
+	}
+	s.mu.Unlock()
+
+	for _, response := range responses {
+		if err := stream.Send(response); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) BeginTransaction(context.Context, *firestorepb.BeginTransactionRequest) (*firestorepb.BeginTransactionResponse, error) {
+	return &firestorepb.BeginTransactionResponse{Transaction: []byte("transaction")}, nil
+}
+
+func (s *Server) Rollback(context.Context, *firestorepb.RollbackRequest) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+// Commit applies every write, or none of them if any fails.
+func (s *Server) Commit(_ context.Context, req *firestorepb.CommitRequest) (*firestorepb.CommitResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.tick()
+	staged := make(map[string]*firestorepb.Document, len(s.documents))
+	for name, doc := range s.documents {
+		staged[name] = doc
+	}
+
+	results := make([]*firestorepb.WriteResult, 0, len(req.Writes))
+	for _, w := range req.Writes {
+		result, err := apply(staged, w, now)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	s.documents = staged
+	return &firestorepb.CommitResponse{WriteResults: results, CommitTime: now}, nil
+}
+
+// BatchWrite applies each write on its own, reporting the outcome of each.
+func (s *Server) BatchWrite(_ context.Context, req *firestorepb.BatchWriteRequest) (*firestorepb.BatchWriteResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, w := range req.Writes {
+		if err := s.failure(w); err != nil {
+			return nil, err
+		}
+	}
+
+	now := s.tick()
+	response := &firestorepb.BatchWriteResponse{}
+	for _, w := range req.Writes {
+		result, err := apply(s.documents, w, now)
+		if err != nil {
+			response.WriteResults = append(response.WriteResults, &firestorepb.WriteResult{})
+			response.Status = append(response.Status, grpcstatus.Convert(err).Proto())
+			continue
+		}
+		response.WriteResults = append(response.WriteResults, result)
+		response.Status = append(response.Status, &status.Status{Code: int32(codes.OK)})
+	}
+
+	return response, nil
+}
+
+func (s *Server) failure(w *firestorepb.Write) error {
+	name := w.GetDelete()
+	if len(name) == 0 {
+		name = w.GetUpdate().GetName()
+	}
+	if code, ok := s.failures[name]; ok {
+		return grpcstatus.Errorf(code, "write to %s failed", name)
+	}
+	return nil
+}
+
+func (s *Server) RunQuery(req *firestorepb.RunQueryRequest, stream firestorepb.Firestore_RunQueryServer) error {
+	s.mu.Lock()
+	documents, err := s.query(req.Parent, req.GetStructuredQuery())
+	readTime := timestamppb.New(s.clock)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if len(documents) == 0 {
+		return stream.Send(&firestorepb.RunQueryResponse{ReadTime: readTime})
+	}
+	for _, doc := range documents {
+		if err = stream.Send(&firestorepb.RunQueryResponse{Document: doc, ReadTime: readTime}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) RunAggregationQuery(req *firestorepb.RunAggregationQueryRequest, stream firestorepb.Firestore_RunAggregationQueryServer) error {
+	aq := req.GetStructuredAggregationQuery()
+
+	s.mu.Lock()
+	documents, err := s.query(req.Parent, aq.GetStructuredQuery())
+	readTime := timestamppb.New(s.clock)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	fields := make(map[string]*firestorepb.Value)
+	for _, a := range aq.Aggregations {
+		switch {
+		case a.GetCount() != nil:
+			fields[a.Alias] = &firestorepb.Value{ValueType: &firestorepb.Value_IntegerValue{IntegerValue: int64(len(documents))}}
+		case a.GetSum() != nil:
+			fields[a.Alias] = sum(documents, fieldPath(a.GetSum().Field.FieldPath), false)
+		case a.GetAvg() != nil:
+			fields[a.Alias] = sum(documents, fieldPath(a.GetAvg().Field.FieldPath), true)
+		default:
+			return grpcstatus.Errorf(codes.Unimplemented, "unsupported aggregation %s", a)
+		}
+	}
+
+	return stream.Send(&firestorepb.RunAggregationQueryResponse{Result: &firestorepb.AggregationResult{AggregateFields: fields}, ReadTime: readTime})
+}
+
+// query returns the documents matching a structured query, in its order.
+func (s *Server) query(parent string, q *firestorepb.StructuredQuery) ([]*firestorepb.Document, error) {
+	if q == nil || len(q.From) != 1 {
+		return nil, grpcstatus.Error(codes.InvalidArgument, "a query must select from one collection")
+	}
+	from := q.From[0]
+
+	documents := make([]*firestorepb.Document, 0)
+	for name, doc := range s.documents {
+		rest, ok := strings.CutPrefix(name, parent+"/")
+		if !ok {
+			continue
+		}
+		segments := strings.Split(rest, "/")
+		if len(segments) < 2 || segments[len(segments)-2] != from.CollectionId || (!from.AllDescendants && len(segments) != 2) {
+			continue
+		}
+
+		match, err := matches(doc, q.Where)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			documents = append(documents, doc)
+		}
+	}
+
+	orders := orderBy(q)
+
+	// a document without a field it's ordered by is left out
+	documents = slices.DeleteFunc(documents, func(doc *firestorepb.Document) bool {
+		for _, o := range orders {
+			if _, ok := field(doc, o.field); !ok {
+				return true
+			}
+		}
+		return false
+	})
+
+	sort.SliceStable(documents, func(i, j int) bool {
+		return compareDocuments(documents[i], documents[j], orders) < 0
+	})
+
+	if q.StartAt != nil {
+		documents = slices.DeleteFunc(documents, func(doc *firestorepb.Document) bool {
+			c := compareCursor(doc, q.StartAt, orders)
+			return c < 0 || (c == 0 && !q.StartAt.Before)
+		})
+	}
+	if q.EndAt != nil {
+		documents = slices.DeleteFunc(documents, func(doc *firestorepb.Document) bool {
+			c := compareCursor(doc, q.EndAt, orders)
+			return c > 0 || (c == 0 && q.EndAt.Before)
+		})
+	}
+
+	documents = documents[min(int(q.Offset), len(documents)):]
+	if q.Limit != nil && int(q.Limit.Value) < len(documents) {
+		documents = documents[:q.Limit.Value]
+	}
+
+	if q.Select != nil {
+		projected := make([]*firestorepb.Document, len(documents))
+		paths := make([]string, 0, len(q.Select.Fields))
+		for _, f := range q.Select.Fields {
+			paths = append(paths, f.FieldPath)
+		}
+		for i, doc := range documents {
+			projected[i] = mask(doc, &firestorepb.DocumentMask{FieldPaths: paths})
+		}
+		documents = projected
+	}
+
+	return documents, nil
+}
+
+type order struct {
+	field      []string
+	descending bool
+}
+
+// orderBy is the order of a query's results: its own order, then any field compared by an inequality that isn't
+// already ordered by, then the document name in the direction of the last order.
+func orderBy(q *firestorepb.StructuredQuery) []order {
+	orders := make([]order, 0, len(q.OrderBy)+1)
+	ordered := make(map[string]bool)
+	for _, o := range q.OrderBy {
+		orders = append(orders, order{field: fieldPath(o.Field.FieldPath), descending: o.Direction == firestorepb.StructuredQuery_DESCENDING})
+		ordered[o.Field.FieldPath] = true
+	}
+
+	inequalities := make([]string, 0)
+	collectInequalities(q.Where, &inequalities)
+	slices.Sort(inequalities)
+	for _, f := range inequalities {
+		if !ordered[f] {
+			orders = append(orders, order{field: fieldPath(f)})
+			ordered[f] = true
+		}
+	}
+
+	if !ordered[documentIDField] {
+		descending := len(orders) > 0 && orders[len(orders)-1].descending
+		orders = append(orders, order{field: []string{documentIDField}, descending: descending})
+	}
+	return orders
+}
+
+func collectInequalities(f *firestorepb.StructuredQuery_Filter, fields *[]string) {
+	switch {
+	case f.GetCompositeFilter() != nil:
+		for _, sub := range f.GetCompositeFilter().Filters {
+			collectInequalities(sub, fields)
+		}
+	case f.GetFieldFilter() != nil:
+		ff := f.GetFieldFilter()
+		switch ff.Op {
+		case firestorepb.StructuredQuery_FieldFilter_LESS_THAN, firestorepb.StructuredQuery_FieldFilter_LESS_THAN_OR_EQUAL,
+			firestorepb.StructuredQuery_FieldFilter_GREATER_THAN, firestorepb.StructuredQuery_FieldFilter_GREATER_THAN_OR_EQUAL,
+			firestorepb.StructuredQuery_FieldFilter_NOT_EQUAL, firestorepb.StructuredQuery_FieldFilter_NOT_IN:
+			if !slices.Contains(*fields, ff.Field.FieldPath) {
+				*fields = append(*fields, ff.Field.FieldPath)
+			}
+		}
+	}
+}
+
+func compareDocuments(a, b *firestorepb.Document, orders []order) int {
+	for _, o := range orders {
+		va, _ := field(a, o.field)
+		vb, _ := field(b, o.field)
+		c := compareValues(va, vb)
+		if o.descending {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareCursor compares a document to a cursor's position, where a cursor may give fewer values than there are
+// orders.
+func compareCursor(doc *firestorepb.Document, cursor *firestorepb.Cursor, orders []order) int {
+	for i, value := range cursor.Values {
+		if i >= len(orders) {
+			break
+		}
+		v, _ := field(doc, orders[i].field)
+		c := compareValues(v, value)
+		if orders[i].descending {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func matches(doc *firestorepb.Document, f *firestorepb.StructuredQuery_Filter) (bool, error) {
+	switch {
+	case f == nil:
+		return true, nil
+	case f.GetCompositeFilter() != nil:
+		cf := f.GetCompositeFilter()
+		for _, sub := range cf.Filters {
+			ok, err := matches(doc, sub)
+			if err != nil {
+				return false, err
+			}
+			if cf.Op == firestorepb.StructuredQuery_CompositeFilter_OR && ok {
+				return true, nil
+			}
+			if cf.Op != firestorepb.StructuredQuery_CompositeFilter_OR && !ok {
+				return false, nil
+			}
+		}
+		return cf.Op != firestorepb.StructuredQuery_CompositeFilter_OR, nil
+	case f.GetUnaryFilter() != nil:
+		uf := f.GetUnaryFilter()
+		v, ok := field(doc, fieldPath(uf.GetField().FieldPath))
+		isNull := ok && typeOrder(v) == 0
+		isNaN := ok && isType[*firestorepb.Value_DoubleValue](v) && math.IsNaN(v.GetDoubleValue())
+		switch uf.Op {
+		case firestorepb.StructuredQuery_UnaryFilter_IS_NULL:
+			return isNull, nil
+		case firestorepb.StructuredQuery_UnaryFilter_IS_NOT_NULL:
+			return ok && !isNull, nil
+		case firestorepb.StructuredQuery_UnaryFilter_IS_NAN:
+			return isNaN, nil
+		case firestorepb.StructuredQuery_UnaryFilter_IS_NOT_NAN:
+			return ok && !isNaN && !isNull, nil
+		}
+	case f.GetFieldFilter() != nil:
+		ff := f.GetFieldFilter()
+		v, ok := field(doc, fieldPath(ff.Field.FieldPath))
+		if !ok {
+			return false, nil
+		}
+		switch ff.Op {
+		case firestorepb.StructuredQuery_FieldFilter_EQUAL:
+			return compareValues(v, ff.Value) == 0, nil
+		case firestorepb.StructuredQuery_FieldFilter_NOT_EQUAL:
+			return !isType[*firestorepb.Value_NullValue](v) && compareValues(v, ff.Value) != 0, nil
+		case firestorepb.StructuredQuery_FieldFilter_LESS_THAN:
+			return comparable(v, ff.Value) && compareValues(v, ff.Value) < 0, nil
+		case firestorepb.StructuredQuery_FieldFilter_LESS_THAN_OR_EQUAL:
+			return comparable(v, ff.Value) && compareValues(v, ff.Value) <= 0, nil
+		case firestorepb.StructuredQuery_FieldFilter_GREATER_THAN:
+			return comparable(v, ff.Value) && compareValues(v, ff.Value) > 0, nil
+		case firestorepb.StructuredQuery_FieldFilter_GREATER_THAN_OR_EQUAL:
+			return comparable(v, ff.Value) && compareValues(v, ff.Value) >= 0, nil
+		case firestorepb.StructuredQuery_FieldFilter_IN:
+			return contains(ff.Value.GetArrayValue().GetValues(), v), nil
+		case firestorepb.StructuredQuery_FieldFilter_NOT_IN:
+			return !isType[*firestorepb.Value_NullValue](v) && !contains(ff.Value.GetArrayValue().GetValues(), v), nil
+		case firestorepb.StructuredQuery_FieldFilter_ARRAY_CONTAINS:
+			return contains(v.GetArrayValue().GetValues(), ff.Value), nil
+		case firestorepb.StructuredQuery_FieldFilter_ARRAY_CONTAINS_ANY:
+			for _, want := range ff.Value.GetArrayValue().GetValues() {
+				if contains(v.GetArrayValue().GetValues(), want) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+	}
+	return false, grpcstatus.Errorf(codes.Unimplemented, "unsupported filter %s", f)
+}
+
+func isType[T any](v *firestorepb.Value) bool {
+	_, ok := v.GetValueType().(T)
+	return ok
+}
+
+func contains(values []*firestorepb.Value, v *firestorepb.Value) bool {
+	return slices.ContainsFunc(values, func(e *firestorepb.Value) bool { return compareValues(e, v) == 0 })
+}
+
+// comparable reports whether a range filter applies to a value, which it only does to values of the same type.
+func comparable(a, b *firestorepb.Value) bool {
+	return typeOrder(a) == typeOrder(b)
+}
+
+func sum(documents []*firestorepb.Document, path []string, average bool) *firestorepb.Value {
+	var ints int64
+	var doubles float64
+	count, isDouble := 0, average
+	for _, doc := range documents {
+		v, _ := field(doc, path)
+		switch v.GetValueType().(type) {
+		case *firestorepb.Value_IntegerValue:
+			ints += v.GetIntegerValue()
+		case *firestorepb.Value_DoubleValue:
+			doubles += v.GetDoubleValue()
+			isDouble = true
+		default:
+			continue
+		}
+		count++
+	}
+
+	if average && count == 0 {
+		return &firestorepb.Value{ValueType: &firestorepb.Value_NullValue{}}
+	}
+	if !isDouble {
+		return &firestorepb.Value{ValueType: &firestorepb.Value_IntegerValue{IntegerValue: ints}}
+	}
+	total := float64(ints) + doubles
+	if average {
+		total /= float64(count)
+	}
+	return &firestorepb.Value{ValueType: &firestorepb.Value_DoubleValue{DoubleValue: total}}
+}
+
+// apply makes a write to a set of documents, replacing rather than changing any document it writes.
+func apply(documents map[string]*firestorepb.Document, w *firestorepb.Write, now *timestamppb.Timestamp) (*firestorepb.WriteResult, error) {
+	var name string
+	switch op := w.Operation.(type) {
+	case *firestorepb.Write_Update:
+		name = op.Update.Name
+	case *firestorepb.Write_Delete:
+		name = op.Delete
+	default:
+		return nil, grpcstatus.Errorf(codes.Unimplemented, "unsupported write %s", w)
+	}
+
+	existing, exists := documents[name]
+	if p := w.CurrentDocument; p != nil {
+		if e, ok := p.ConditionType.(*firestorepb.Precondition_Exists); ok {
+			if e.Exists && !exists {
+				return nil, grpcstatus.Errorf(codes.NotFound, "no entity to update: %s", name)
+			}
+			if !e.Exists && exists {
+				return nil, grpcstatus.Errorf(codes.AlreadyExists, "entity already exists: %s", name)
+			}
+		}
+	}
+
+	if _, ok := w.Operation.(*firestorepb.Write_Delete); ok {
+		delete(documents, name)
+		return &firestorepb.WriteResult{UpdateTime: now}, nil
+	}
+
+	doc := &firestorepb.Document{Name: name, Fields: make(map[string]*firestorepb.Value), CreateTime: now, UpdateTime: now}
+	if exists {
+		doc.CreateTime = existing.CreateTime
+	}
+
+	update := w.GetUpdate()
+	if w.UpdateMask == nil {
+		for k, v := range update.Fields {
+			doc.Fields[k] = v
+		}
+	} else {
+		if exists {
+			doc.Fields = clone(existing).Fields
+		}
+		for _, p := range w.UpdateMask.FieldPaths {
+			path := fieldPath(p)
+			if v, ok := getField(update.Fields, path); ok {
+				setField(doc.Fields, path, v)
+			} else {
+				deleteField(doc.Fields, path)
+			}
+		}
+	}
+
+	results := make([]*firestorepb.Value, 0, len(w.UpdateTransforms))
+	for _, t := range w.UpdateTransforms {
+		path := fieldPath(t.FieldPath)
+		current, _ := getField(doc.Fields, path)
+
+		var result *firestorepb.Value
+		switch t := t.TransformType.(type) {
+		case *firestorepb.DocumentTransform_FieldTransform_SetToServerValue:
+			result = &firestorepb.Value{ValueType: &firestorepb.Value_TimestampValue{TimestampValue: now}}
+		case *firestorepb.DocumentTransform_FieldTransform_Increment:
+			result = increment(current, t.Increment)
+		case *firestorepb.DocumentTransform_FieldTransform_Maximum:
+			result = t.Maximum
+			if typeOrder(current) == typeOrder(t.Maximum) && compareValues(current, t.Maximum) > 0 {
+				result = current
+			}
+		case *firestorepb.DocumentTransform_FieldTransform_Minimum:
+			result = t.Minimum
+			if typeOrder(current) == typeOrder(t.Minimum) && compareValues(current, t.Minimum) < 0 {
+				result = current
+			}
+		case *firestorepb.DocumentTransform_FieldTransform_AppendMissingElements:
+			values := slices.Clone(current.GetArrayValue().GetValues())
+			for _, v := range t.AppendMissingElements.Values {
+				if !contains(values, v) {
+					values = append(values, v)
+				}
+			}
+			result = &firestorepb.Value{ValueType: &firestorepb.Value_ArrayValue{ArrayValue: &firestorepb.ArrayValue{Values: values}}}
+		case *firestorepb.DocumentTransform_FieldTransform_RemoveAllFromArray:
+			values := slices.DeleteFunc(slices.Clone(current.GetArrayValue().GetValues()), func(v *firestorepb.Value) bool {
+				return contains(t.RemoveAllFromArray.Values, v)
+			})
+			result = &firestorepb.Value{ValueType: &firestorepb.Value_ArrayValue{ArrayValue: &firestorepb.ArrayValue{Values: values}}}
+		default:
+			return nil, grpcstatus.Errorf(codes.Unimplemented, "unsupported transform %s", t)
+		}
+
+		setField(doc.Fields, path, result)
+		results = append(results, result)
+	}
+
+	documents[name] = doc
+	return &firestorepb.WriteResult{UpdateTime: now, TransformResults: results}, nil
+}
+
+func increment(current *firestorepb.Value, by *firestorepb.Value) *firestorepb.Value {
+	switch c := current.GetValueType().(type) {
+	case *firestorepb.Value_IntegerValue:
+		if b, ok := by.ValueType.(*firestorepb.Value_IntegerValue); ok {
+			return &firestorepb.Value{ValueType: &firestorepb.Value_IntegerValue{IntegerValue: c.IntegerValue + b.IntegerValue}}
+		}
+		return &firestorepb.Value{ValueType: &firestorepb.Value_DoubleValue{DoubleValue: float64(c.IntegerValue) + by.GetDoubleValue()}}
+	case *firestorepb.Value_DoubleValue:
+		return &firestorepb.Value{ValueType: &firestorepb.Value_DoubleValue{DoubleValue: c.DoubleValue + number(by)}}
+	}
+	// anything that isn't a number is replaced by the increment
+	return by
+}
+
+func number(v *firestorepb.Value) float64 {
+	if i, ok := v.GetValueType().(*firestorepb.Value_IntegerValue); ok {
+		return float64(i.IntegerValue)
+	}
+	return v.GetDoubleValue()
+}
+
+// mask returns a copy of a document with only the fields in the mask, or the document itself when there's no mask.
+func mask(doc *firestorepb.Document, m *firestorepb.DocumentMask) *firestorepb.Document {
+	if m == nil {
+		return doc
+	}
+
+	masked := &firestorepb.Document{Name: doc.Name, Fields: make(map[string]*firestorepb.Value), CreateTime: doc.CreateTime, UpdateTime: doc.UpdateTime}
+	for _, p := range m.FieldPaths {
+		path := fieldPath(p)
+		if v, ok := getField(doc.Fields, path); ok {
+			setField(masked.Fields, path, v)
+		}
+	}
+	return masked
+}
+
+func clone(doc *firestorepb.Document) *firestorepb.Document {
+	return proto.Clone(doc).(*firestorepb.Document)
+}
+
+func field(doc *firestorepb.Document, path []string) (*firestorepb.Value, bool) {
+	if len(path) == 1 && path[0] == documentIDField {
+		return &firestorepb.Value{ValueType: &firestorepb.Value_ReferenceValue{ReferenceValue: doc.Name}}, true
+	}
+	return getField(doc.Fields, path)
+}
+
+func getField(fields map[string]*firestorepb.Value, path []string) (*firestorepb.Value, bool) {
+	v, ok := fields[path[0]]
+	if !ok || len(path) == 1 {
+		return v, ok
+	}
+	return getField(v.GetMapValue().GetFields(), path[1:])
+}
+
+func setField(fields map[string]*firestorepb.Value, path []string, value *firestorepb.Value) {
+	if len(path) == 1 {
+		fields[path[0]] = value
+		return
+	}
+
+	nested := fields[path[0]].GetMapValue()
+	if nested == nil {
+		nested = &firestorepb.MapValue{}
+		fields[path[0]] = &firestorepb.Value{ValueType: &firestorepb.Value_MapValue{MapValue: nested}}
+	}
+	if nested.Fields == nil {
+		nested.Fields = make(map[string]*firestorepb.Value)
+	}
+	setField(nested.Fields, path[1:], value)
+}
+
+func deleteField(fields map[string]*firestorepb.Value, path []string) {
+	if len(path) == 1 {
+		delete(fields, path[0])
+		return
+	}
+	if nested := fields[path[0]].GetMapValue(); nested != nil {
+		deleteField(nested.Fields, path[1:])
+	}
+}
+
+// fieldPath splits a field path into its names, unquoting any name in backticks.
+func fieldPath(path string) []string {
+	names := make([]string, 0)
+	var name strings.Builder
+	quoted := false
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case c == '\\' && quoted && i+1 < len(path):
+			i++
+			name.WriteByte(path[i])
+		case c == '`':
+			quoted = !quoted
+		case c == '.' && !quoted:
+			names = append(names, name.String())
+			name.Reset()
+		default:
+			name.WriteByte(c)
+		}
+	}
+	return append(names, name.String())
+}
+
+// typeOrder ranks the types of values the way Firestore orders them.
+func typeOrder(v *firestorepb.Value) int {
+	switch v.GetValueType().(type) {
+	case *firestorepb.Value_NullValue, nil:
+		return 0
+	case *firestorepb.Value_BooleanValue:
+		return 1
+	case *firestorepb.Value_IntegerValue, *firestorepb.Value_DoubleValue:
+		return 2
+	case *firestorepb.Value_TimestampValue:
+		return 3
+	case *firestorepb.Value_StringValue:
+		return 4
+	case *firestorepb.Value_BytesValue:
+		return 5
+	case *firestorepb.Value_ReferenceValue:
+		return 6
+	case *firestorepb.Value_GeoPointValue:
+		return 7
+	case *firestorepb.Value_ArrayValue:
+		return 8
+	default:
+		return 9
+	}
+}
+
+func compareValues(a, b *firestorepb.Value) int {
+	if c := typeOrder(a) - typeOrder(b); c != 0 {
+		return c
+	}
+
+	switch a.GetValueType().(type) {
+	case *firestorepb.Value_BooleanValue:
+		return compareBools(a.GetBooleanValue(), b.GetBooleanValue())
+	case *firestorepb.Value_IntegerValue, *firestorepb.Value_DoubleValue:
+		ai, aInt := a.ValueType.(*firestorepb.Value_IntegerValue)
+		bi, bInt := b.ValueType.(*firestorepb.Value_IntegerValue)
+		if aInt && bInt {
+			return compareOrdered(ai.IntegerValue, bi.IntegerValue)
+		}
+		return compareFloats(number(a), number(b))
+	case *firestorepb.Value_TimestampValue:
+		return a.GetTimestampValue().AsTime().Compare(b.GetTimestampValue().AsTime())
+	case *firestorepb.Value_StringValue:
+		return strings.Compare(a.GetStringValue(), b.GetStringValue())
+	case *firestorepb.Value_BytesValue:
+		return bytes.Compare(a.GetBytesValue(), b.GetBytesValue())
+	case *firestorepb.Value_ReferenceValue:
+		return compareNames(a.GetReferenceValue(), b.GetReferenceValue())
+	case *firestorepb.Value_GeoPointValue:
+		if c := compareFloats(a.GetGeoPointValue().Latitude, b.GetGeoPointValue().Latitude); c != 0 {
+			return c
+		}
+		return compareFloats(a.GetGeoPointValue().Longitude, b.GetGeoPointValue().Longitude)
+	case *firestorepb.Value_ArrayValue:
+		av, bv := a.GetArrayValue().GetValues(), b.GetArrayValue().GetValues()
+		for i := 0; i < len(av) && i < len(bv); i++ {
+			if c := compareValues(av[i], bv[i]); c != 0 {
+				return c
+			}
+		}
+		return len(av) - len(bv)
+	case *firestorepb.Value_MapValue:
+		af, bf := a.GetMapValue().GetFields(), b.GetMapValue().GetFields()
+		ak, bk := sortedKeys(af), sortedKeys(bf)
+		for i := 0; i < len(ak) && i < len(bk); i++ {
+			if c := strings.Compare(ak[i], bk[i]); c != 0 {
+				return c
+			}
+			if c := compareValues(af[ak[i]], bf[bk[i]]); c != 0 {
+				return c
+			}
+		}
+		return len(ak) - len(bk)
+	}
+	return 0
+}
+
+func compareBools(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case b:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func compareOrdered[T int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compareFloats orders NaN before every other number.
+func compareFloats(a, b float64) int {
+	switch {
+	case math.IsNaN(a) && math.IsNaN(b):
+		return 0
+	case math.IsNaN(a):
+		return -1
+	case math.IsNaN(b):
+		return 1
+	}
+	return compareOrdered(a, b)
+}
+
+// compareNames orders document names one path segment at a time.
+func compareNames(a, b string) int {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if c := strings.Compare(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+	return len(as) - len(bs)
+}
+
+func sortedKeys(m map[string]*firestorepb.Value) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// toValue converts the Go values tests use to Firestore values.
+func toValue(v any) *firestorepb.Value {
+	switch v := v.(type) {
+	case nil:
+		return &firestorepb.Value{ValueType: &firestorepb.Value_NullValue{}}
+	case bool:
+		return &firestorepb.Value{ValueType: &firestorepb.Value_BooleanValue{BooleanValue: v}}
+	case int:
+		return &firestorepb.Value{ValueType: &firestorepb.Value_IntegerValue{IntegerValue: int64(v)}}
+	case int64:
+		return &firestorepb.Value{ValueType: &firestorepb.Value_IntegerValue{IntegerValue: v}}
+	case float64:
+		return &firestorepb.Value{ValueType: &firestorepb.Value_DoubleValue{DoubleValue: v}}
+	case string:
+		return &firestorepb.Value{ValueType: &firestorepb.Value_StringValue{StringValue: v}}
+	case []byte:
+		return &firestorepb.Value{ValueType: &firestorepb.Value_BytesValue{BytesValue: v}}
+	case time.Time:
+		return &firestorepb.Value{ValueType: &firestorepb.Value_TimestampValue{TimestampValue: timestamppb.New(v)}}
+	case []any:
+		values := make([]*firestorepb.Value, len(v))
+		for i, e := range v {
+			values[i] = toValue(e)
+		}
+		return &firestorepb.Value{ValueType: &firestorepb.Value_ArrayValue{ArrayValue: &firestorepb.ArrayValue{Values: values}}}
+	case map[string]any:
+		fields := make(map[string]*firestorepb.Value, len(v))
+		for k, e := range v {
+			fields[k] = toValue(e)
+		}
+		return &firestorepb.Value{ValueType: &firestorepb.Value_MapValue{MapValue: &firestorepb.MapValue{Fields: fields}}}
+	}
+	panic(fmt.Sprintf("unsupported value %T", v))
+}
+
+// fromValue converts a Firestore value to the Go values toValue takes, with integers as int64 and references as the
+// path relative to the database root.
+func fromValue(v *firestorepb.Value) any {
+	switch v := v.GetValueType().(type) {
+	case *firestorepb.Value_BooleanValue:
+		return v.BooleanValue
+	case *firestorepb.Value_IntegerValue:
+		return v.IntegerValue
+	case *firestorepb.Value_DoubleValue:
+		return v.DoubleValue
+	case *firestorepb.Value_StringValue:
+		return v.StringValue
+	case *firestorepb.Value_BytesValue:
+		return v.BytesValue
+	case *firestorepb.Value_TimestampValue:
+		return v.TimestampValue.AsTime()
+	case *firestorepb.Value_ReferenceValue:
+		return strings.TrimPrefix(v.ReferenceValue, documentRoot+"/")
+	case *firestorepb.Value_GeoPointValue:
+		return strconv.FormatFloat(v.GeoPointValue.Latitude, 'f', -1, 64) + "," + strconv.FormatFloat(v.GeoPointValue.Longitude, 'f', -1, 64)
+	case *firestorepb.Value_ArrayValue:
+		values := make([]any, len(v.ArrayValue.GetValues()))
+		for i, e := range v.ArrayValue.GetValues() {
+			values[i] = fromValue(e)
+		}
+		return values
+	case *firestorepb.Value_MapValue:
+		fields := make(map[string]any, len(v.MapValue.GetFields()))
+		for k, e := range v.MapValue.GetFields() {
+			fields[k] = fromValue(e)
+		}
+		return fields
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package preview
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"jhight.com/firestore-cli/pkg/api/client/preview"
+	"testing"
+)
+
+// value writes inputs as they are, except "-", which removes the field, and "!", which fails.
+func value(_ any, input any, path string) (any, bool, error) {
+	switch input {
+	case "-":
+		return nil, true, nil
+	case "!":
+		return nil, false, errors.New("invalid value at " + path)
+	default:
+		return input, false, nil
+	}
+}
+
+func TestFields(t *testing.T) {
+	current := map[string]any{"name": "A", "nickname": "a", "address": map[string]any{"city": "Chicago", "zip": "60601"}}
+	fields := map[string]any{"nickname": "-", "address": map[string]any{"city": "Boston"}, "manager": map[string]any{"$ref": "users/1"}}
+
+	// a merge keeps the current fields and merges nested maps, but a tagged value is written whole
+	merged, err := preview.Fields(current, fields, value)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]any{
+		"name":    "A",
+		"address": map[string]any{"city": "Boston", "zip": "60601"},
+		"manager": map[string]any{"$ref": "users/1"},
+	}, merged)
+
+	// the current fields aren't changed
+	assert.Equal(t, "a", current["nickname"])
+	assert.Equal(t, "Chicago", current["address"].(map[string]any)["city"])
+
+	// without current fields, the result is the fields alone
+	written, err := preview.Fields(nil, fields, value)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]any{"address": map[string]any{"city": "Boston"}, "manager": map[string]any{"$ref": "users/1"}}, written)
+
+	_, err = preview.Fields(nil, map[string]any{"address": map[string]any{"city": "!"}}, value)
+	assert.EqualError(t, err, "invalid value at city")
+}
+
+func TestUpdate(t *testing.T) {
+	address := map[string]any{"city": "Chicago", "zip": "60601"}
+	document := map[string]any{"name": "A", "address": address, "age": int64(30)}
+
+	assert.Nil(t, preview.Update(document, []string{"address", "city"}, "Boston", "address.city", value))
+	assert.Nil(t, preview.Update(document, []string{"address", "zip"}, "-", "address.zip", value))
+	assert.Nil(t, preview.Update(document, []string{"stats", "visits"}, int64(1), "stats.visits", value))
+	assert.Nil(t, preview.Update(document, []string{"age"}, "-", "age", value))
+
+	assert.Equal(t, map[string]any{
+		"name":    "A",
+		"address": map[string]any{"city": "Boston"},
+		"stats":   map[string]any{"visits": int64(1)},
+	}, document)
+
+	// parent maps are copied, not changed
+	assert.Equal(t, map[string]any{"city": "Chicago", "zip": "60601"}, address)
+
+	assert.EqualError(t, preview.Update(document, []string{"name"}, "!", "name", value), "invalid value at name")
+}
+
+func TestIncrement(t *testing.T) {
+	tests := []struct {
+		current any
+		n       any
+		want    any
+	}{
+		{int64(1), int64(2), int64(3)},
+		{int64(1), 0.5, 1.5},
+		{1.5, int64(1), 2.5},
+		{1.5, 0.5, 2.0},
+		// anything that isn't a number is replaced
+		{nil, int64(2), int64(2)},
+		{"1", 0.5, 0.5},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, preview.Increment(test.current, test.n), test)
+	}
+}
+
+func TestArrays(t *testing.T) {
+	current := []any{"a", int64(1), map[string]any{"x": int64(1)}}
+
+	assert.Equal(t, []any{"a", int64(1), map[string]any{"x": int64(1)}, "b"}, preview.ArrayUnion(current, []any{"a", "b", "b", map[string]any{"x": int64(1)}}))
+	assert.Equal(t, []any{"b"}, preview.ArrayUnion("not an array", []any{"b"}))
+	assert.Equal(t, []any{"a"}, preview.ArrayRemove(current, []any{int64(1), map[string]any{"x": int64(1)}, "c"}))
+	assert.Equal(t, []any{}, preview.ArrayRemove(nil, []any{"a"}))
+
+	// the current array isn't changed
+	assert.Len(t, current, 3)
+}